@@ -0,0 +1,358 @@
+package main
+
+import (
+	"github.com/cloudogu/confluence-license-checker/license/watcher"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	signalPidFileFlagName = "signal-pid-file"
+	signalPatternFlagName = "signal-process-pattern"
+	signalSequenceFlag    = "signal-sequence"
+	signalEventsFlagName  = "signal-events"
+
+	webhookURLFlagName          = "webhook-url"
+	webhookHeaderFlagName       = "webhook-header"
+	webhookSecretFlagName       = "webhook-secret"
+	webhookFormatFlagName       = "webhook-format"
+	webhookTemplateFileFlagName = "webhook-template-file"
+	webhookTimeoutFlagName      = "webhook-timeout"
+	webhookRetriesFlagName      = "webhook-retries"
+	webhookEventsFlagName       = "webhook-events"
+
+	mailAddressFlagName          = "mail-smtp-address"
+	mailStartTLSFlagName         = "mail-starttls"
+	mailUsernameFlagName         = "mail-username"
+	mailPasswordFlagName         = "mail-password"
+	mailFromFlagName             = "mail-from"
+	mailToFlagName               = "mail-to"
+	mailSubjectTemplateFlagName  = "mail-subject-template"
+	mailTextTemplateFileFlagName = "mail-text-template-file"
+	mailHTMLTemplateFileFlagName = "mail-html-template-file"
+	mailTimeoutFlagName          = "mail-timeout"
+	mailRetriesFlagName          = "mail-retries"
+	mailEventsFlagName           = "mail-events"
+)
+
+// createActionFlags returns the flags of the built-in actions which handle license changes besides the command.
+func createActionFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    signalPidFileFlagName,
+			Usage:   "send signals to the process whose ID is in this file on a license change, e.g. to restart Confluence",
+			EnvVars: []string{"SIGNAL_PID_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    signalPatternFlagName,
+			Usage:   "send signals to the processes whose command line matches this regular expression on a license change",
+			EnvVars: []string{"SIGNAL_PROCESS_PATTERN"},
+		},
+		&cli.StringFlag{
+			Name:    signalSequenceFlag,
+			Usage:   "the signals which are sent in the form signal:wait, separated by commas; the next signal is sent if the processes did not exit within the waiting time",
+			EnvVars: []string{"SIGNAL_SEQUENCE"},
+			Value:   watcher.DefaultSignalSequence,
+		},
+		&cli.StringSliceFlag{
+			Name:    signalEventsFlagName,
+			Usage:   "the license events on which signals are sent; may be repeated (default: all events except license-removed, license-expiring and watch-failed)",
+			EnvVars: []string{"SIGNAL_EVENTS"},
+		},
+		&cli.StringFlag{
+			Name:    webhookURLFlagName,
+			Usage:   "post license events to this URL",
+			EnvVars: []string{"WEBHOOK_URL"},
+		},
+		&cli.StringSliceFlag{
+			Name:    webhookHeaderFlagName,
+			Usage:   "a header in the form 'Name: value' which is added to webhook requests; may be repeated",
+			EnvVars: []string{"WEBHOOK_HEADERS"},
+		},
+		&cli.StringFlag{
+			Name:    webhookSecretFlagName,
+			Usage:   "sign webhook requests with HMAC-SHA256 using this secret",
+			EnvVars: []string{"WEBHOOK_SECRET"},
+		},
+		&cli.StringFlag{
+			Name:    webhookFormatFlagName,
+			Usage:   "the format of webhook requests, one of json, slack or mattermost",
+			EnvVars: []string{"WEBHOOK_FORMAT"},
+			Value:   watcher.WebhookFormatJSON,
+		},
+		&cli.StringFlag{
+			Name:    webhookTemplateFileFlagName,
+			Usage:   "a file with a Go template which renders the body of webhook requests instead of the format",
+			EnvVars: []string{"WEBHOOK_TEMPLATE_FILE"},
+		},
+		&cli.DurationFlag{
+			Name:    webhookTimeoutFlagName,
+			Usage:   "the time a single webhook request may take",
+			EnvVars: []string{"WEBHOOK_TIMEOUT"},
+			Value:   10 * time.Second,
+		},
+		&cli.IntFlag{
+			Name:    webhookRetriesFlagName,
+			Usage:   "the number of retries after a failed webhook request",
+			EnvVars: []string{"WEBHOOK_RETRIES"},
+			Value:   3,
+		},
+		&cli.StringSliceFlag{
+			Name:    webhookEventsFlagName,
+			Usage:   "the license events which are posted to the webhook; may be repeated (default: all events)",
+			EnvVars: []string{"WEBHOOK_EVENTS"},
+		},
+		&cli.StringFlag{
+			Name:    mailAddressFlagName,
+			Usage:   "send mails about license events through the SMTP relay at this address in the form host:port",
+			EnvVars: []string{"MAIL_SMTP_ADDRESS"},
+		},
+		&cli.BoolFlag{
+			Name:    mailStartTLSFlagName,
+			Usage:   "require the SMTP relay to encrypt the connection with STARTTLS",
+			EnvVars: []string{"MAIL_STARTTLS"},
+		},
+		&cli.StringFlag{
+			Name:    mailUsernameFlagName,
+			Usage:   "the user name with which to authenticate at the SMTP relay",
+			EnvVars: []string{"MAIL_USERNAME"},
+		},
+		&cli.StringFlag{
+			Name:    mailPasswordFlagName,
+			Usage:   "the password with which to authenticate at the SMTP relay",
+			EnvVars: []string{"MAIL_PASSWORD"},
+		},
+		&cli.StringFlag{
+			Name:    mailFromFlagName,
+			Usage:   "the sender address of mails",
+			EnvVars: []string{"MAIL_FROM"},
+		},
+		&cli.StringSliceFlag{
+			Name:    mailToFlagName,
+			Usage:   "a recipient address of mails; may be repeated",
+			EnvVars: []string{"MAIL_TO"},
+		},
+		&cli.StringFlag{
+			Name:    mailSubjectTemplateFlagName,
+			Usage:   "a Go template which renders the subject of mails",
+			EnvVars: []string{"MAIL_SUBJECT_TEMPLATE"},
+		},
+		&cli.StringFlag{
+			Name:    mailTextTemplateFileFlagName,
+			Usage:   "a file with a Go template which renders the plain text body of mails",
+			EnvVars: []string{"MAIL_TEXT_TEMPLATE_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    mailHTMLTemplateFileFlagName,
+			Usage:   "a file with a Go template which renders the HTML body of mails",
+			EnvVars: []string{"MAIL_HTML_TEMPLATE_FILE"},
+		},
+		&cli.DurationFlag{
+			Name:    mailTimeoutFlagName,
+			Usage:   "the time the delivery of a mail to the SMTP relay may take",
+			EnvVars: []string{"MAIL_TIMEOUT"},
+			Value:   30 * time.Second,
+		},
+		&cli.IntFlag{
+			Name:    mailRetriesFlagName,
+			Usage:   "the number of retries while the SMTP relay cannot be reached",
+			EnvVars: []string{"MAIL_RETRIES"},
+			Value:   10,
+		},
+		&cli.StringSliceFlag{
+			Name:    mailEventsFlagName,
+			Usage:   "the license events about which mails are sent; may be repeated (default: all events)",
+			EnvVars: []string{"MAIL_EVENTS"},
+		},
+	}
+}
+
+// createActions creates the subscriptions of all configured built-in actions.
+func createActions(c *cli.Context) ([]watcher.Subscription, error) {
+	var subscriptions []watcher.Subscription
+
+	signal, err := createSignalAction(c)
+	if err != nil {
+		return nil, err
+	}
+	if signal != nil {
+		subscriptions = append(subscriptions, *signal)
+	}
+
+	webhook, err := createWebhookAction(c)
+	if err != nil {
+		return nil, err
+	}
+	if webhook != nil {
+		subscriptions = append(subscriptions, *webhook)
+	}
+
+	mail, err := createMailAction(c)
+	if err != nil {
+		return nil, err
+	}
+	if mail != nil {
+		subscriptions = append(subscriptions, *mail)
+	}
+
+	return subscriptions, nil
+}
+
+func createSignalAction(c *cli.Context) (*watcher.Subscription, error) {
+	target := watcher.SignalTarget{
+		PidFile:            c.String(signalPidFileFlagName),
+		CommandLinePattern: c.String(signalPatternFlagName),
+	}
+	if target.PidFile == "" && target.CommandLinePattern == "" {
+		return nil, nil
+	}
+
+	steps, err := watcher.ParseSignalSequence(c.String(signalSequenceFlag))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid value for flag '--%s'", signalSequenceFlag)
+	}
+
+	action, err := watcher.NewSignalAction(target, steps)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := parseActionEvents(c, signalEventsFlagName, watcher.DefaultCommandEventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &watcher.Subscription{Action: action, Events: events}, nil
+}
+
+func createWebhookAction(c *cli.Context) (*watcher.Subscription, error) {
+	url := c.String(webhookURLFlagName)
+	if url == "" {
+		return nil, nil
+	}
+
+	headers, err := parseHeaders(c.StringSlice(webhookHeaderFlagName))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid value for flag '--%s'", webhookHeaderFlagName)
+	}
+
+	bodyTemplate, err := readTemplateFile(c.String(webhookTemplateFileFlagName))
+	if err != nil {
+		return nil, err
+	}
+
+	retries := c.Int(webhookRetriesFlagName)
+	if retries < 0 {
+		return nil, errors.Errorf("value for flag '--%s' must not be negative", webhookRetriesFlagName)
+	}
+
+	action, err := watcher.NewWebhookAction(watcher.WebhookOptions{
+		URL:      url,
+		Headers:  headers,
+		Secret:   c.String(webhookSecretFlagName),
+		Format:   c.String(webhookFormatFlagName),
+		Template: bodyTemplate,
+		Retry:    watcher.RetryPolicy{Timeout: c.Duration(webhookTimeoutFlagName), Retries: retries},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := parseActionEvents(c, webhookEventsFlagName, watcher.AllEventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &watcher.Subscription{Action: action, Events: events}, nil
+}
+
+func createMailAction(c *cli.Context) (*watcher.Subscription, error) {
+	address := c.String(mailAddressFlagName)
+	if address == "" {
+		return nil, nil
+	}
+
+	textTemplate, err := readTemplateFile(c.String(mailTextTemplateFileFlagName))
+	if err != nil {
+		return nil, err
+	}
+	htmlTemplate, err := readTemplateFile(c.String(mailHTMLTemplateFileFlagName))
+	if err != nil {
+		return nil, err
+	}
+
+	retries := c.Int(mailRetriesFlagName)
+	if retries < 0 {
+		return nil, errors.Errorf("value for flag '--%s' must not be negative", mailRetriesFlagName)
+	}
+
+	events, err := parseActionEvents(c, mailEventsFlagName, watcher.AllEventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	action, err := watcher.NewMailAction(watcher.MailOptions{
+		Address:         address,
+		StartTLS:        c.Bool(mailStartTLSFlagName),
+		Username:        c.String(mailUsernameFlagName),
+		Password:        c.String(mailPasswordFlagName),
+		From:            c.String(mailFromFlagName),
+		To:              c.StringSlice(mailToFlagName),
+		SubjectTemplate: c.String(mailSubjectTemplateFlagName),
+		TextTemplate:    textTemplate,
+		HTMLTemplate:    htmlTemplate,
+		Retry: watcher.RetryPolicy{
+			Timeout: c.Duration(mailTimeoutFlagName),
+			Retries: retries,
+			Backoff: watcher.Backoff{Initial: 5 * time.Second, Max: 5 * time.Minute},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &watcher.Subscription{Action: action, Events: events}, nil
+}
+
+// readTemplateFile returns the content of the given template file. It returns an empty template if no file is given.
+func readTemplateFile(templateFile string) (string, error) {
+	if templateFile == "" {
+		return "", nil
+	}
+
+	content, err := os.ReadFile(templateFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read template file '%s'", templateFile)
+	}
+	return string(content), nil
+}
+
+// parseHeaders parses headers in the form 'Name: value'.
+func parseHeaders(values []string) (http.Header, error) {
+	headers := http.Header{}
+	for _, value := range values {
+		name, headerValue, found := strings.Cut(value, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, errors.Errorf("header '%s' must have the form 'Name: value'", value)
+		}
+		headers.Add(name, strings.TrimSpace(headerValue))
+	}
+	return headers, nil
+}
+
+// parseActionEvents parses the events of the given flag. The given default events are used if the flag is empty.
+func parseActionEvents(c *cli.Context, flagName string, defaultEvents []watcher.EventType) ([]watcher.EventType, error) {
+	events, err := watcher.ParseEventTypes(c.StringSlice(flagName))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid value for flag '--%s'", flagName)
+	}
+	if len(events) == 0 {
+		return defaultEvents, nil
+	}
+	return events, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"github.com/cloudogu/confluence-license-checker/license/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"net/http"
+	"testing"
+)
+
+func Test_createActions(t *testing.T) {
+	t.Run("should create no actions without flags", func(t *testing.T) {
+		var actual []watcher.Subscription
+		err := runWithFlags(createActionFlags(), []string{}, func(c *cli.Context) (err error) {
+			actual, err = createActions(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		assert.Empty(t, actual)
+	})
+	t.Run("should create signal action", func(t *testing.T) {
+		var actual []watcher.Subscription
+		args := []string{"--signal-process-pattern", "java .*confluence", "--signal-events", "setup-to-production"}
+		err := runWithFlags(createActionFlags(), args, func(c *cli.Context) (err error) {
+			actual, err = createActions(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		require.Len(t, actual, 1)
+		assert.Equal(t, "signal", actual[0].Action.Name())
+		assert.Equal(t, []watcher.EventType{watcher.EventSetupToProduction}, actual[0].Events)
+	})
+	t.Run("should subscribe signal action to default events", func(t *testing.T) {
+		var actual []watcher.Subscription
+		err := runWithFlags(createActionFlags(), []string{"--signal-pid-file", "confluence.pid"}, func(c *cli.Context) (err error) {
+			actual, err = createActions(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		require.Len(t, actual, 1)
+		assert.Equal(t, watcher.DefaultCommandEventTypes, actual[0].Events)
+	})
+	t.Run("should fail on invalid signal sequence", func(t *testing.T) {
+		args := []string{"--signal-pid-file", "confluence.pid", "--signal-sequence", "SIGTERM"}
+		err := runWithFlags(createActionFlags(), args, func(c *cli.Context) error {
+			_, err := createActions(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid value for flag '--signal-sequence'")
+	})
+	t.Run("should create webhook action", func(t *testing.T) {
+		var actual []watcher.Subscription
+		args := []string{"--webhook-url", "https://chat.example.com/hooks/abc", "--webhook-header", "Authorization: Bearer token"}
+		err := runWithFlags(createActionFlags(), args, func(c *cli.Context) (err error) {
+			actual, err = createActions(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		require.Len(t, actual, 1)
+		assert.Equal(t, "webhook", actual[0].Action.Name())
+		assert.Equal(t, watcher.AllEventTypes, actual[0].Events)
+	})
+	t.Run("should fail on missing webhook template file", func(t *testing.T) {
+		args := []string{"--webhook-url", "https://chat.example.com", "--webhook-template-file", "/does/not/exist.tmpl"}
+		err := runWithFlags(createActionFlags(), args, func(c *cli.Context) error {
+			_, err := createActions(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read template file '/does/not/exist.tmpl'")
+	})
+}
+
+func Test_createMailAction(t *testing.T) {
+	t.Run("should create mail action", func(t *testing.T) {
+		var actual *watcher.Subscription
+		args := []string{"--mail-smtp-address", "mail.example.com:587", "--mail-from", "confluence@example.com",
+			"--mail-to", "ops@example.com", "--mail-events", "license-expiring"}
+		err := runWithFlags(createActionFlags(), args, func(c *cli.Context) (err error) {
+			actual, err = createMailAction(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, actual)
+		assert.Equal(t, "mail", actual.Action.Name())
+		assert.Equal(t, []watcher.EventType{watcher.EventLicenseExpiring}, actual.Events)
+		require.NoError(t, actual.Action.(watcher.Closer).Close(context.Background()))
+	})
+	t.Run("should fail without recipients", func(t *testing.T) {
+		args := []string{"--mail-smtp-address", "mail.example.com:587", "--mail-from", "confluence@example.com"}
+		err := runWithFlags(createActionFlags(), args, func(c *cli.Context) error {
+			_, err := createMailAction(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "at least one recipient address must be given")
+	})
+}
+
+func Test_parseHeaders(t *testing.T) {
+	actual, err := parseHeaders([]string{"Authorization: Bearer a:b", "X-Team:ops"})
+
+	require.NoError(t, err)
+	assert.Equal(t, http.Header{"Authorization": []string{"Bearer a:b"}, "X-Team": []string{"ops"}}, actual)
+
+	_, err = parseHeaders([]string{"no header"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must have the form 'Name: value'")
+}
@@ -1,20 +1,60 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
 	"github.com/cloudogu/confluence-license-checker/license/tester"
 	"github.com/cloudogu/confluence-license-checker/license/watcher"
 	"github.com/op/go-logging"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
 	"os"
+	"strings"
+	"time"
 )
 
 const (
-	watchIntervalFlagName  = "watch-interval"
-	setupLicenseFlagName   = "setup-license"
-	setupLicenseEnvVarName = "SETUP_LICENSE"
-	confluenceConfigFile   = "/var/atlassian/confluence/confluence.cfg.xml"
+	watchIntervalFlagName   = "watch-interval"
+	watchIntervalMaxFlag    = "watch-interval-max"
+	watchJitterFlagName     = "watch-jitter"
+	watchBackendFlagName    = "watch-backend"
+	watchBackendEnvVarName  = "WATCH_BACKEND"
+	gracePeriodFlagName     = "grace-period"
+	gracePeriodEnvVarName   = "WATCH_GRACE_PERIOD"
+	waitForSetupFlagName    = "wait-for-setup"
+	waitForSetupTimeoutFlag = "wait-for-setup-timeout"
+	continuousFlagName      = "continuous"
+	continuousEnvVarName    = "WATCH_CONTINUOUS"
+	commandEventsFlagName   = "command-events"
+	commandEventsEnvVar     = "WATCH_COMMAND_EVENTS"
+	expiryWarningFlagName   = "expiry-warning-days"
+	expiryWarningEnvVar     = "EXPIRY_WARNING_DAYS"
+	setupLicenseFlagName    = "setup-license"
+	setupLicenseEnvVarName  = "SETUP_LICENSE"
+	detectionFlagName       = "setup-detection"
+	detectionEnvVarName     = "SETUP_DETECTION"
+	setupPropertyFlagName   = "setup-license-property"
+	setupPropertyEnvVar     = "SETUP_LICENSE_PROPERTIES"
+	setupFingerprintFlag    = "setup-license-sha256"
+	setupFingerprintEnvVar  = "SETUP_LICENSE_SHA256"
+	setupFingerprintsFlag   = "setup-license-sha256-file"
+	setupFingerprintsEnvVar = "SETUP_LICENSE_SHA256_FILE"
+	confluenceConfigFile    = "/var/atlassian/confluence/confluence.cfg.xml"
+	unsafeShowLicenseFlag   = "unsafe-show-license"
+
+	commandTimeoutFlagName           = "command-timeout"
+	commandRetriesFlagName           = "command-retries"
+	commandBackoffFlagName           = "command-backoff"
+	commandBackoffMaxFlagName        = "command-backoff-max"
+	commandBackoffMultiplierFlagName = "command-backoff-multiplier"
+	successExitCodesFlagName         = "command-success-exit-codes"
+	commandOutputTailLinesFlagName   = "command-output-tail-lines"
+	commandOutputLogFileFlagName     = "command-output-log-file"
+	commandEnvClearFlagName          = "command-env-clear"
+	commandEnvAllowFlagName          = "command-env-allow"
+	commandEnvDenyFlagName           = "command-env-deny"
+	commandEnvPassSecretsFlag        = "command-env-pass-secrets"
 )
 
 var (
@@ -22,18 +62,10 @@ var (
 	Version string
 )
 
-// logging format
-var format = logging.MustStringFormatter(
-	`{time:15:04:05.000} %{shortfunc} ▶ %{level:.4s} %{id:03x} %{message}`,
-)
+var log = logging.MustGetLogger("main")
 
 func createGlobalFlags() []cli.Flag {
-	return []cli.Flag{
-		&cli.StringFlag{
-			Name:  "log-level",
-			Usage: "define log level",
-			Value: "warning",
-		},
+	return append(createLoggingFlags(),
 		&cli.BoolFlag{
 			Name:  "show-stack",
 			Usage: "show stacktrace on errors",
@@ -42,19 +74,26 @@ func createGlobalFlags() []cli.Flag {
 			Name:  "skip-root",
 			Usage: "skip root check",
 		},
-	}
+		&cli.BoolFlag{
+			Name: unsafeShowLicenseFlag,
+			Usage: "show licenses in full in logs, error messages and the output of inspect instead of their fingerprint and " +
+				"first and last characters; the state file, status and /status stay redacted; only meant for debugging",
+		},
+	)
 }
 
-func configureLogging(c *cli.Context) error {
-	backend := logging.NewLogBackend(os.Stdout, "", 0)
-	backendFormatter := logging.NewBackendFormatter(backend, format)
-	logging.SetBackend(backendFormatter)
-	logLevel, err := logging.LogLevel(c.String("log-level"))
+// configureApp configures the logging and the redaction of licenses before any command runs.
+func configureApp(c *cli.Context) error {
+	err := configureLogging(c)
 	if err != nil {
-		fmt.Println("invalid log level specified, please use critical, error, warning, notice, info or debug")
-		return errors.Wrap(err, "failed to configure logging")
+		return err
+	}
+
+	showLicenses := c.Bool(unsafeShowLicenseFlag)
+	atlassian.ShowLicenses(showLicenses)
+	if showLicenses {
+		log.Warningf("Licenses are shown in full in logs, error messages and the output of inspect because of flag '--%s'", unsafeShowLicenseFlag)
 	}
-	logging.SetLevel(logLevel, "")
 	return nil
 }
 
@@ -69,12 +108,20 @@ func isPrintStack() bool {
 
 func checkMainError(err error, ex exiter) {
 	if err != nil {
-		if isPrintStack() {
-			fmt.Printf("%+v\n", err)
-		} else {
-			fmt.Printf("%+s\n", err)
+		exitCode := exitCodeError
+		var exitCoder cli.ExitCoder
+		if errors.As(err, &exitCoder) {
+			exitCode = exitCoder.ExitCode()
 		}
-		ex.exit(1)
+
+		if err.Error() != "" {
+			if isPrintStack() {
+				fmt.Printf("%+v\n", err)
+			} else {
+				fmt.Printf("%+s\n", err)
+			}
+		}
+		ex.exit(exitCode)
 	}
 }
 
@@ -84,10 +131,12 @@ func main() {
 	app.Name = "license-checker"
 	app.Usage = "a tool that checks for a Confluence license"
 	app.Version = Version
-	app.Commands = []*cli.Command{WatchCommand(), TestLicenseCommand()}
+	app.Commands = []*cli.Command{WatchCommand(), TestLicenseCommand(), InspectCommand(), StatusCommand()}
 
 	app.Flags = createGlobalFlags()
-	app.Before = configureLogging
+	app.Before = configureApp
+	// exit codes are handled by checkMainError
+	app.ExitErrHandler = func(*cli.Context, error) {}
 
 	err := app.Run(os.Args)
 	exiter := newExiter()
@@ -96,14 +145,70 @@ func main() {
 
 func WatchCommand() *cli.Command {
 	return &cli.Command{
-		Name:  "watch",
-		Usage: "watch for a Confluence license change and execute a command",
-		Flags: []cli.Flag{
-			&cli.IntFlag{
+		Name:      "watch",
+		Usage:     "watch for a Confluence license change and execute a command or a built-in action",
+		ArgsUsage: " [command [arguments...]]",
+		Description: "Every command argument which contains '{{' is expanded as Go template with the license details, " +
+			"e.g. {{.Event}} or {{.NewExpiry}}, and watch fails to start if such an argument is no valid template. " +
+			"Write {{\"{{\"}} to pass a literal '{{' to the command.",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
 				Name:    watchIntervalFlagName,
 				Aliases: []string{"w"},
-				Usage:   "the watch interval in seconds",
-				Value:   30,
+				Usage:   "the interval in which the polling backend checks the config file, e.g. 500ms or 2m; a plain number is read as seconds",
+				EnvVars: []string{"WATCH_INTERVAL"},
+				Value:   watcher.DefaultWatchInterval.String(),
+			},
+			&cli.DurationFlag{
+				Name:    watchIntervalMaxFlag,
+				Usage:   "slow the polling down up to this interval while the config file does not change (default: no slow down)",
+				EnvVars: []string{"WATCH_INTERVAL_MAX"},
+			},
+			&cli.DurationFlag{
+				Name:    watchJitterFlagName,
+				Usage:   "add a random duration up to this value to each polling interval",
+				EnvVars: []string{"WATCH_JITTER"},
+			},
+			&cli.StringFlag{
+				Name: watchBackendFlagName,
+				Usage: fmt.Sprintf("how changes of the config file are noticed: '%s' reacts to file system events, '%s' "+
+					"checks in the watch interval, '%s' uses inotify if the file system supports it and polling otherwise",
+					watcher.BackendInotify, watcher.BackendPoll, watcher.BackendAuto),
+				EnvVars: []string{watchBackendEnvVarName},
+				Value:   watcher.BackendAuto,
+			},
+			&cli.DurationFlag{
+				Name:    gracePeriodFlagName,
+				Usage:   "the time a running command may take to finish after the watcher received SIGTERM or SIGINT",
+				EnvVars: []string{gracePeriodEnvVarName},
+				Value:   8 * time.Second,
+			},
+			&cli.BoolFlag{
+				Name:    waitForSetupFlagName,
+				Usage:   "wait until the config file exists and the Confluence setup is complete before comparing licenses",
+				EnvVars: []string{"WAIT_FOR_SETUP"},
+			},
+			&cli.DurationFlag{
+				Name:    waitForSetupTimeoutFlag,
+				Usage:   "the time the watcher waits for the Confluence setup to complete; 0 waits until the watcher is stopped",
+				EnvVars: []string{"WAIT_FOR_SETUP_TIMEOUT"},
+				Value:   30 * time.Minute,
+			},
+			&cli.BoolFlag{
+				Name: continuousFlagName,
+				Usage: "keep watching after a license change and execute the command on every further change; " +
+					"without a setup license the currently configured license is watched",
+				EnvVars: []string{continuousEnvVarName},
+			},
+			&cli.StringSliceFlag{
+				Name:    commandEventsFlagName,
+				Usage:   "the license events on which the command is executed; may be repeated (default: all events except license-removed, license-expiring and watch-failed)",
+				EnvVars: []string{commandEventsEnvVar},
+			},
+			&cli.IntSliceFlag{
+				Name:    expiryWarningFlagName,
+				Usage:   "emit the license-expiring event this number of days before the configured license expires; may be repeated",
+				EnvVars: []string{expiryWarningEnvVar},
 			},
 			&cli.StringFlag{
 				Name:    setupLicenseFlagName,
@@ -111,7 +216,13 @@ func WatchCommand() *cli.Command {
 				Usage:   "provides a license to watch instead from a environment variable",
 				EnvVars: []string{setupLicenseEnvVarName},
 			},
-		},
+			createDetectionFlag(),
+			createSetupPropertyFlag(),
+			createSetupFingerprintFlag(),
+			createSetupFingerprintsFlag(),
+			createStateFileFlag(),
+			createHTTPAddrFlag(),
+		}, append(append(createErrorPolicyFlags(), createCommandFlags()...), createActionFlags()...)...),
 		Action: watchExecuteAction,
 	}
 }
@@ -127,37 +238,361 @@ func TestLicenseCommand() *cli.Command {
 				Usage:   "provides a license to watch instead from a environment variable",
 				EnvVars: []string{setupLicenseEnvVarName},
 			},
+			createDetectionFlag(),
+			createSetupPropertyFlag(),
+			createSetupFingerprintFlag(),
+			createSetupFingerprintsFlag(),
+			&cli.StringFlag{
+				Name:    outputFlagName,
+				Aliases: []string{"o"},
+				Usage:   "print a machine-readable result, one of json or env",
+			},
 		},
 		Action: TestLicenseAction,
 	}
 }
 
+func createCommandFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.DurationFlag{
+			Name:    commandTimeoutFlagName,
+			Usage:   "the time a single attempt of the command may take, 0 means no timeout",
+			EnvVars: []string{"COMMAND_TIMEOUT"},
+		},
+		&cli.IntFlag{
+			Name:    commandRetriesFlagName,
+			Usage:   "the number of retries after a failed attempt of the command",
+			EnvVars: []string{"COMMAND_RETRIES"},
+		},
+		&cli.DurationFlag{
+			Name:    commandBackoffFlagName,
+			Usage:   "the waiting time after the first failed attempt of the command",
+			EnvVars: []string{"COMMAND_BACKOFF"},
+			Value:   time.Second,
+		},
+		&cli.DurationFlag{
+			Name:    commandBackoffMaxFlagName,
+			Usage:   "the maximum waiting time between two attempts of the command",
+			EnvVars: []string{"COMMAND_BACKOFF_MAX"},
+			Value:   time.Minute,
+		},
+		&cli.Float64Flag{
+			Name:    commandBackoffMultiplierFlagName,
+			Usage:   "the factor by which the waiting time grows after each failed attempt of the command",
+			EnvVars: []string{"COMMAND_BACKOFF_MULTIPLIER"},
+			Value:   2,
+		},
+		&cli.IntSliceFlag{
+			Name:    successExitCodesFlagName,
+			Usage:   "the exit codes of the command which count as success; may be repeated (default: 0)",
+			EnvVars: []string{"COMMAND_SUCCESS_EXIT_CODES"},
+		},
+		&cli.IntFlag{
+			Name:    commandOutputTailLinesFlagName,
+			Usage:   "the number of last output lines per stream which are attached to the error of a failed command",
+			EnvVars: []string{"COMMAND_OUTPUT_TAIL_LINES"},
+			Value:   watcher.DefaultTailLines,
+		},
+		&cli.StringFlag{
+			Name:    commandOutputLogFileFlagName,
+			Usage:   "a file to which the output of the command is appended",
+			EnvVars: []string{"COMMAND_OUTPUT_LOG_FILE"},
+		},
+		&cli.BoolFlag{
+			Name:    commandEnvClearFlagName,
+			Usage:   "start the command with an empty environment instead of the environment of the watcher",
+			EnvVars: []string{"COMMAND_ENV_CLEAR"},
+		},
+		&cli.StringSliceFlag{
+			Name:    commandEnvAllowFlagName,
+			Usage:   "a pattern of environment variables which are passed to the command, e.g. 'CONFLUENCE_*'; may be repeated (default: all)",
+			EnvVars: []string{"COMMAND_ENV_ALLOW"},
+		},
+		&cli.StringSliceFlag{
+			Name:    commandEnvDenyFlagName,
+			Usage:   "a pattern of environment variables which are not passed to the command in addition to the secrets of the watcher; may be repeated",
+			EnvVars: []string{"COMMAND_ENV_DENY"},
+		},
+		&cli.BoolFlag{
+			Name:    commandEnvPassSecretsFlag,
+			Usage:   "pass the secrets of the watcher, e.g. SETUP_LICENSE or MAIL_PASSWORD, to the command; they are removed by default",
+			EnvVars: []string{"COMMAND_ENV_PASS_SECRETS"},
+		},
+	}
+}
+
+func createRetryPolicy(c *cli.Context) (watcher.RetryPolicy, error) {
+	policy := watcher.RetryPolicy{
+		Timeout: c.Duration(commandTimeoutFlagName),
+		Retries: c.Int(commandRetriesFlagName),
+		Backoff: watcher.Backoff{
+			Initial:    c.Duration(commandBackoffFlagName),
+			Max:        c.Duration(commandBackoffMaxFlagName),
+			Multiplier: c.Float64(commandBackoffMultiplierFlagName),
+		},
+		SuccessExitCodes: c.IntSlice(successExitCodesFlagName),
+	}
+
+	if policy.Timeout < 0 || policy.Retries < 0 {
+		return policy, errors.Errorf("values for flags '--%s' and '--%s' must not be negative",
+			commandTimeoutFlagName, commandRetriesFlagName)
+	}
+	if policy.Backoff.Initial <= 0 || policy.Backoff.Max <= 0 {
+		return policy, errors.Errorf("values for flags '--%s' and '--%s' must be positive",
+			commandBackoffFlagName, commandBackoffMaxFlagName)
+	}
+	if policy.Backoff.Multiplier < 1 {
+		return policy, errors.Errorf("value for flag '--%s' must be at least 1", commandBackoffMultiplierFlagName)
+	}
+
+	return policy, nil
+}
+
+func createOutputOptions(c *cli.Context) (watcher.OutputOptions, error) {
+	options := watcher.OutputOptions{
+		TailLines: c.Int(commandOutputTailLinesFlagName),
+		LogFile:   c.String(commandOutputLogFileFlagName),
+	}
+
+	if options.TailLines < 1 {
+		return options, errors.Errorf("value for flag '--%s' must be at least 1", commandOutputTailLinesFlagName)
+	}
+
+	return options, nil
+}
+
+func createEnvironmentPolicy(c *cli.Context) (watcher.EnvironmentPolicy, error) {
+	policy := watcher.EnvironmentPolicy{
+		Clear:       c.Bool(commandEnvClearFlagName),
+		Allow:       c.StringSlice(commandEnvAllowFlagName),
+		Deny:        c.StringSlice(commandEnvDenyFlagName),
+		PassSecrets: c.Bool(commandEnvPassSecretsFlag),
+	}
+	return policy, policy.Validate()
+}
+
+func createSchedule(c *cli.Context) (watcher.Schedule, error) {
+	interval, err := watcher.ParseInterval(c.String(watchIntervalFlagName))
+	if err != nil {
+		return watcher.Schedule{}, errors.Wrapf(err, "invalid value for flag '--%s'", watchIntervalFlagName)
+	}
+
+	schedule := watcher.Schedule{
+		Interval:    interval,
+		MaxInterval: c.Duration(watchIntervalMaxFlag),
+		Jitter:      c.Duration(watchJitterFlagName),
+	}
+	return schedule, schedule.Validate()
+}
+
+func createDetectionFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name: detectionFlagName,
+		Usage: fmt.Sprintf("how a setup license is detected: '%s' compares with the given setup license, "+
+			"'%s' additionally treats expired licenses and licenses matching all setup license properties as setup license",
+			tester.DetectionModeExact, tester.DetectionModeDecoded),
+		EnvVars: []string{detectionEnvVarName},
+		Value:   tester.DetectionModeExact,
+	}
+}
+
+func createSetupPropertyFlag() cli.Flag {
+	return &cli.StringSliceFlag{
+		Name:    setupPropertyFlagName,
+		Usage:   "a license property in the form key=value which a setup license must have, e.g. conf.LicenseTypeName=EVALUATION; may be repeated",
+		EnvVars: []string{setupPropertyEnvVar},
+	}
+}
+
+func createSetupFingerprintFlag() cli.Flag {
+	return &cli.StringSliceFlag{
+		Name:    setupFingerprintFlag,
+		Usage:   "the SHA-256 fingerprint of a setup license as printed by inspect; replaces the setup license and may be repeated",
+		EnvVars: []string{setupFingerprintEnvVar},
+	}
+}
+
+func createSetupFingerprintsFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    setupFingerprintsFlag,
+		Usage:   "a file with one SHA-256 fingerprint of a setup license per line, e.g. the output of sha256sum",
+		EnvVars: []string{setupFingerprintsEnvVar},
+	}
+}
+
+func createDetection(c *cli.Context) (tester.Detection, error) {
+	properties, err := tester.ParseSetupProperties(c.StringSlice(setupPropertyFlagName))
+	if err != nil {
+		return tester.Detection{}, err
+	}
+
+	fingerprints, err := readSetupFingerprints(c)
+	if err != nil {
+		return tester.Detection{}, err
+	}
+
+	detection := tester.Detection{Mode: c.String(detectionFlagName), SetupProperties: properties, SetupFingerprints: fingerprints}
+	if len(properties) == 0 {
+		detection.SetupProperties = nil
+	}
+
+	return detection, detection.Validate()
+}
+
+// readSetupFingerprints collects the fingerprints given by flag and those listed in the fingerprint file. Empty lines
+// and lines starting with '#' are skipped, and only the first field of a line is read, so that the output of
+// sha256sum can be used as is.
+func readSetupFingerprints(c *cli.Context) ([]string, error) {
+	values := c.StringSlice(setupFingerprintFlag)
+
+	if path := c.String(setupFingerprintsFlag); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read setup license fingerprints from '%s'", path)
+		}
+
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			values = append(values, fields[0])
+		}
+	}
+
+	return tester.ParseSetupFingerprints(values)
+}
+
+func checkSetupLicense(license string, detection tester.Detection) error {
+	if license == "" && detection.RequiresSetupLicense() {
+		return errors.Errorf("a start license must be provided either by flag '--%s' or by environment variable '${%s}', "+
+			"or its fingerprint by flag '--%s'", setupLicenseFlagName, setupLicenseEnvVarName, setupFingerprintFlag)
+	}
+	return nil
+}
+
 func watchExecuteAction(c *cli.Context) error {
-	watchInterval := c.Int(watchIntervalFlagName)
-	if watchInterval < 1 {
-		return errors.Errorf("cannot start license watcher: value for flag '--%s' must be greater than zero", watchIntervalFlagName)
+	schedule, err := createSchedule(c)
+	if err != nil {
+		return errors.Wrap(err, "cannot start license watcher")
 	}
 
-	if c.NArg() == 0 {
-		err := cli.ShowAppHelp(c)
-		return errors.Wrap(err, "cannot start license watcher: a shell command must be provided")
+	watchBackend := c.String(watchBackendFlagName)
+	err = watcher.ValidateBackend(watchBackend)
+	if err != nil {
+		return errors.Wrap(err, "cannot start license watcher")
+	}
+
+	err = watcher.ValidateCommandArgs(c.Args().Slice())
+	if err != nil {
+		return errors.Wrap(err, "cannot start license watcher")
+	}
+
+	detection, err := createDetection(c)
+	if err != nil {
+		return errors.Wrap(err, "cannot start license watcher")
+	}
+
+	commandEvents, err := watcher.ParseEventTypes(c.StringSlice(commandEventsFlagName))
+	if err != nil {
+		return errors.Wrap(err, "cannot start license watcher")
+	}
+
+	retryPolicy, err := createRetryPolicy(c)
+	if err != nil {
+		return errors.Wrap(err, "cannot start license watcher")
+	}
+
+	outputOptions, err := createOutputOptions(c)
+	if err != nil {
+		return errors.Wrap(err, "cannot start license watcher")
+	}
+
+	environmentPolicy, err := createEnvironmentPolicy(c)
+	if err != nil {
+		return errors.Wrap(err, "cannot start license watcher")
+	}
+
+	errorPolicy, err := createErrorPolicy(c)
+	if err != nil {
+		return errors.Wrap(err, "cannot start license watcher")
+	}
+
+	expiryWarningDays := c.IntSlice(expiryWarningFlagName)
+	for _, days := range expiryWarningDays {
+		if days < 1 {
+			return errors.Errorf("cannot start license watcher: values for flag '--%s' must be greater than zero", expiryWarningFlagName)
+		}
+	}
+
+	readiness := watcher.Readiness{Wait: c.Bool(waitForSetupFlagName), Timeout: c.Duration(waitForSetupTimeoutFlag)}
+	if readiness.Timeout < 0 {
+		return errors.Errorf("cannot start license watcher: value for flag '--%s' must not be negative", waitForSetupTimeoutFlag)
 	}
 
+	continuous := c.Bool(continuousFlagName)
 	license := c.String(setupLicenseFlagName)
-	if license == "" {
-		return errors.Errorf("cannot start license watcher: a start license must be provided either by flag '--%s' or by environment variable '${%s}'",
-			setupLicenseFlagName, setupLicenseEnvVarName)
+	if !continuous {
+		err = checkSetupLicense(license, detection)
+		if err != nil {
+			return errors.Wrap(err, "cannot start license watcher")
+		}
+	}
+
+	var monitor *watcher.Monitor
+	var metrics *watcher.Metrics
+	if addr := c.String(httpAddrFlagName); addr != "" {
+		monitor = watcher.NewMonitor(schedule)
+		metrics = watcher.NewMetrics()
+		server, err := startHTTPServer(addr, createHTTPHandler(monitor, metrics))
+		if err != nil {
+			return errors.Wrap(err, "cannot start license watcher")
+		}
+		defer server.Stop()
+	}
+
+	// the actions are created last because the mail action starts sending in the background, which only the watcher
+	// stops again
+	actions, err := createActions(c)
+	if err != nil {
+		return errors.Wrap(err, "cannot start license watcher")
+	}
+
+	if c.NArg() == 0 && len(actions) == 0 {
+		err := cli.ShowAppHelp(c)
+		return errors.Wrap(err, "cannot start license watcher: a shell command or a built-in action must be provided")
 	}
 
 	args := &watcher.ProcessArgs{
 		CommandArgs:          c.Args().Slice(),
-		WatchIntervalInSecs:  watchInterval,
+		Schedule:             schedule,
+		WatchBackend:         watchBackend,
+		GracePeriod:          c.Duration(gracePeriodFlagName),
 		ConfluenceConfigFile: confluenceConfigFile,
 		SetupLicense:         license,
+		Detection:            detection,
+		Continuous:           continuous,
+		CommandEvents:        commandEvents,
+		RetryPolicy:          retryPolicy,
+		CommandOutput:        outputOptions,
+		CommandEnvironment:   environmentPolicy,
+		Actions:              actions,
+		ExpiryWarningDays:    expiryWarningDays,
+		ErrorPolicy:          errorPolicy,
+		Readiness:            readiness,
+		StateFile:            c.String(stateFileFlagName),
+		Monitor:              monitor,
+		Metrics:              metrics,
 	}
 
+	ctx, notifier := notifyOnSignals(c.Context, stopSignals...)
+	defer notifier.Stop()
+
 	ex := watcher.New(args)
-	err := ex.Watch()
+	err = ex.Watch(ctx)
+	if sig := notifier.Received(); sig != nil && errors.Is(err, context.Canceled) {
+		return signalExit(sig)
+	}
 	if err != nil {
 		return errors.Wrap(err, "license watcher failed with an error")
 	}
@@ -167,24 +602,39 @@ func watchExecuteAction(c *cli.Context) error {
 }
 
 func TestLicenseAction(c *cli.Context) error {
+	detection, err := createDetection(c)
+	if err != nil {
+		return errors.Wrap(err, "cannot start license watcher")
+	}
+
 	license := c.String(setupLicenseFlagName)
-	if license == "" {
-		return errors.Errorf("cannot start license watcher: a start license must be provided either by flag '--%s' or by environment variable '${%s}'",
-			setupLicenseFlagName, setupLicenseEnvVarName)
+	err = checkSetupLicense(license, detection)
+	if err != nil {
+		return errors.Wrap(err, "cannot start license watcher")
 	}
 
-	licTester := tester.New()
-	hasSetupLic, err := licTester.HasSetupLicense(confluenceConfigFile, license)
+	format := c.String(outputFlagName)
+	if !isValidSetupOutputFormat(format) {
+		return errors.Errorf("cannot start license watcher: unsupported output format '%s'", format)
+	}
+
+	licTester := tester.NewWithDetection(detection)
+	testResult, err := licTester.CheckSetupLicense(confluenceConfigFile, license)
 	if err != nil {
 		return errors.Wrap(err, "license watcher failed with an error")
 	}
 
-	if !hasSetupLic {
-		return errors.New("Found a non-setup license. License check must not be started.")
+	result := newSetupResult(testResult)
+	err = printSetupResult(c.App.Writer, result, format)
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("Confluence license watcher quits.")
-	return nil
+	if format == "" && result.ExitCode == exitCodeSetupLicense {
+		fmt.Println(resultMessages[result.State])
+	}
+
+	return setupResultExit(result, format)
 }
 
 type exiter interface {
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"github.com/cloudogu/confluence-license-checker/license/tester"
+	"github.com/cloudogu/confluence-license-checker/license/watcher"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_checkMainError(t *testing.T) {
@@ -24,6 +30,14 @@ func Test_checkMainError(t *testing.T) {
 
 		mockedExiter.AssertExpectations(t)
 	})
+	t.Run("should exit with the code of an exit coder", func(t *testing.T) {
+		mockedExiter := new(mockExiter)
+		mockedExiter.On("exit", 3)
+
+		checkMainError(cli.Exit("", 3), mockedExiter)
+
+		mockedExiter.AssertExpectations(t)
+	})
 	t.Run("should exit 1 with any error and print stacktrace", func(t *testing.T) {
 		os.Args = []string{"--show-stack"}
 		defer func() { os.Args = []string{} }()
@@ -50,14 +64,270 @@ func TestTestLicenseCommand(t *testing.T) {
 }
 
 func Test_createGlobalFlags(t *testing.T) {
-	t.Run("should return three flags", func(t *testing.T) {
+	t.Run("should return logging flags and three further flags", func(t *testing.T) {
 		actual := createGlobalFlags()
 
-		require.Len(t, actual, 3)
+		require.Len(t, actual, len(createLoggingFlags())+3)
+	})
+}
+
+func Test_createDetection(t *testing.T) {
+	flags := []cli.Flag{createDetectionFlag(), createSetupPropertyFlag(), createSetupFingerprintFlag(), createSetupFingerprintsFlag()}
+
+	t.Run("should default to exact detection", func(t *testing.T) {
+		var actual tester.Detection
+		err := runWithFlags(flags, []string{}, func(c *cli.Context) (err error) {
+			actual, err = createDetection(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, tester.Detection{Mode: tester.DetectionModeExact}, actual)
+	})
+	t.Run("should create decoded detection with properties", func(t *testing.T) {
+		var actual tester.Detection
+		args := []string{"--setup-detection", "decoded", "--setup-license-property", "Organisation=Cloudogu", "--setup-license-property", "conf.LicenseTypeName=EVALUATION"}
+		err := runWithFlags(flags, args, func(c *cli.Context) (err error) {
+			actual, err = createDetection(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		expected := tester.Detection{
+			Mode:            tester.DetectionModeDecoded,
+			SetupProperties: map[string]string{"Organisation": "Cloudogu", "conf.LicenseTypeName": "EVALUATION"},
+		}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should fail on properties in exact mode", func(t *testing.T) {
+		err := runWithFlags(flags, []string{"--setup-license-property", "Organisation=Cloudogu"}, func(c *cli.Context) error {
+			_, err := createDetection(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "only supported in detection mode 'decoded'")
+	})
+	t.Run("should read fingerprints from flag and file", func(t *testing.T) {
+		first := strings.Repeat("a", 64)
+		second := strings.Repeat("b", 64)
+		third := strings.Repeat("c", 64)
+		file := filepath.Join(t.TempDir(), "setup-licenses.sha256")
+		content := "# setup licenses\n\n" + second + "  setup.txt\nsha256:" + strings.ToUpper(third) + "\n"
+		require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+
+		var actual tester.Detection
+		args := []string{"--setup-license-sha256", first, "--setup-license-sha256-file", file}
+		err := runWithFlags(flags, args, func(c *cli.Context) (err error) {
+			actual, err = createDetection(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		expected := tester.Detection{Mode: tester.DetectionModeExact, SetupFingerprints: []string{first, second, third}}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should fail on invalid fingerprint", func(t *testing.T) {
+		err := runWithFlags(flags, []string{"--setup-license-sha256", "abc"}, func(c *cli.Context) error {
+			_, err := createDetection(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid setup license fingerprint 'abc'")
+	})
+	t.Run("should fail on missing fingerprint file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "missing.sha256")
+		err := runWithFlags(flags, []string{"--setup-license-sha256-file", file}, func(c *cli.Context) error {
+			_, err := createDetection(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read setup license fingerprints")
+	})
+}
+
+func Test_createSchedule(t *testing.T) {
+	flags := WatchCommand().Flags
+
+	t.Run("should create schedule with defaults", func(t *testing.T) {
+		var actual watcher.Schedule
+		err := runWithFlags(flags, []string{}, func(c *cli.Context) (err error) {
+			actual, err = createSchedule(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, watcher.Schedule{Interval: 30 * time.Second}, actual)
+	})
+	t.Run("should create schedule from flags", func(t *testing.T) {
+		var actual watcher.Schedule
+		args := []string{"--watch-interval", "500ms", "--watch-interval-max", "2m", "--watch-jitter", "1s"}
+		err := runWithFlags(flags, args, func(c *cli.Context) (err error) {
+			actual, err = createSchedule(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		expected := watcher.Schedule{Interval: 500 * time.Millisecond, MaxInterval: 2 * time.Minute, Jitter: time.Second}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should read a plain number as seconds", func(t *testing.T) {
+		var actual watcher.Schedule
+		err := runWithFlags(flags, []string{"-w", "10"}, func(c *cli.Context) (err error) {
+			actual, err = createSchedule(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 10*time.Second, actual.Interval)
+	})
+	t.Run("should fail on interval of zero", func(t *testing.T) {
+		err := runWithFlags(flags, []string{"--watch-interval", "0"}, func(c *cli.Context) error {
+			_, err := createSchedule(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be greater than zero")
 	})
 }
 
+func Test_createRetryPolicy(t *testing.T) {
+	t.Run("should create policy with defaults", func(t *testing.T) {
+		var actual watcher.RetryPolicy
+		err := runWithFlags(createCommandFlags(), []string{}, func(c *cli.Context) (err error) {
+			actual, err = createRetryPolicy(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		expected := watcher.RetryPolicy{Backoff: watcher.Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2}}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should create policy from flags", func(t *testing.T) {
+		var actual watcher.RetryPolicy
+		args := []string{"--command-timeout", "2m", "--command-retries", "3", "--command-backoff", "500ms",
+			"--command-backoff-max", "10s", "--command-backoff-multiplier", "1.5",
+			"--command-success-exit-codes", "0", "--command-success-exit-codes", "143"}
+		err := runWithFlags(createCommandFlags(), args, func(c *cli.Context) (err error) {
+			actual, err = createRetryPolicy(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		expected := watcher.RetryPolicy{
+			Timeout:          2 * time.Minute,
+			Retries:          3,
+			Backoff:          watcher.Backoff{Initial: 500 * time.Millisecond, Max: 10 * time.Second, Multiplier: 1.5},
+			SuccessExitCodes: []int{0, 143},
+		}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should fail on negative retries", func(t *testing.T) {
+		err := runWithFlags(createCommandFlags(), []string{"--command-retries", "-1"}, func(c *cli.Context) error {
+			_, err := createRetryPolicy(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must not be negative")
+	})
+	t.Run("should fail on zero backoff", func(t *testing.T) {
+		err := runWithFlags(createCommandFlags(), []string{"--command-backoff", "0s"}, func(c *cli.Context) error {
+			_, err := createRetryPolicy(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "values for flags '--command-backoff' and '--command-backoff-max' must be positive")
+	})
+	t.Run("should fail on multiplier below 1", func(t *testing.T) {
+		err := runWithFlags(createCommandFlags(), []string{"--command-backoff-multiplier", "0.5"}, func(c *cli.Context) error {
+			_, err := createRetryPolicy(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be at least 1")
+	})
+}
+
+func Test_createOutputOptions(t *testing.T) {
+	t.Run("should create options from flags", func(t *testing.T) {
+		var actual watcher.OutputOptions
+		args := []string{"--command-output-tail-lines", "5", "--command-output-log-file", "/tmp/restart.log"}
+		err := runWithFlags(createCommandFlags(), args, func(c *cli.Context) (err error) {
+			actual, err = createOutputOptions(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, watcher.OutputOptions{TailLines: 5, LogFile: "/tmp/restart.log"}, actual)
+	})
+	t.Run("should fail on tail lines below 1", func(t *testing.T) {
+		err := runWithFlags(createCommandFlags(), []string{"--command-output-tail-lines", "0"}, func(c *cli.Context) error {
+			_, err := createOutputOptions(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be at least 1")
+	})
+}
+
+func Test_createEnvironmentPolicy(t *testing.T) {
+	t.Run("should deny the secrets of the watcher by default", func(t *testing.T) {
+		var actual watcher.EnvironmentPolicy
+		err := runWithFlags(createCommandFlags(), []string{}, func(c *cli.Context) (err error) {
+			actual, err = createEnvironmentPolicy(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, watcher.EnvironmentPolicy{}, actual)
+	})
+	t.Run("should create policy from flags", func(t *testing.T) {
+		var actual watcher.EnvironmentPolicy
+		args := []string{"--command-env-allow", "PATH", "--command-env-allow", "CONFLUENCE_*", "--command-env-deny", "*_PASSWORD", "--command-env-pass-secrets"}
+		err := runWithFlags(createCommandFlags(), args, func(c *cli.Context) (err error) {
+			actual, err = createEnvironmentPolicy(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		expected := watcher.EnvironmentPolicy{Allow: []string{"PATH", "CONFLUENCE_*"}, Deny: []string{"*_PASSWORD"}, PassSecrets: true}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should fail on invalid pattern", func(t *testing.T) {
+		err := runWithFlags(createCommandFlags(), []string{"--command-env-allow", "CONFLUENCE_["}, func(c *cli.Context) error {
+			_, err := createEnvironmentPolicy(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid environment variable pattern")
+	})
+}
+
+func Test_checkSetupLicense(t *testing.T) {
+	assert.NoError(t, checkSetupLicense("license", tester.Detection{}))
+	assert.NoError(t, checkSetupLicense("", tester.Detection{Mode: tester.DetectionModeDecoded}))
+	assert.NoError(t, checkSetupLicense("", tester.Detection{SetupFingerprints: []string{strings.Repeat("a", 64)}}))
+
+	err := checkSetupLicense("", tester.Detection{Mode: tester.DetectionModeExact})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a start license must be provided")
+}
+
 // test util stuff
+
+func runWithFlags(flags []cli.Flag, args []string, action cli.ActionFunc) error {
+	app := &cli.App{Name: "license-checker", Compiled: time.Now(), Flags: flags, Action: action}
+	return app.Run(append([]string{"license-checker"}, args...))
+}
+
 type mockExiter struct {
 	mock.Mock
 }
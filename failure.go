@@ -0,0 +1,81 @@
+package main
+
+import (
+	"github.com/cloudogu/confluence-license-checker/license/watcher"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"time"
+)
+
+const (
+	checkErrorsToleratedFlagName  = "check-errors-tolerated"
+	checkErrorsGiveUpFlagName     = "check-errors-give-up"
+	checkErrorBackoffFlagName     = "check-error-backoff"
+	checkErrorBackoffMaxFlagName  = "check-error-backoff-max"
+	onErrorCommandFlagName        = "on-error-command"
+	defaultCheckErrorsTolerated   = 3
+	defaultCheckErrorsGiveUpAfter = 20
+)
+
+// createErrorPolicyFlags returns the flags which configure how failed license checks are handled.
+func createErrorPolicyFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:    checkErrorsToleratedFlagName,
+			Usage:   "the number of consecutive failed license checks which are only logged as warnings",
+			EnvVars: []string{"CHECK_ERRORS_TOLERATED"},
+			Value:   defaultCheckErrorsTolerated,
+		},
+		&cli.IntFlag{
+			Name:    checkErrorsGiveUpFlagName,
+			Usage:   "the number of consecutive failed license checks after which the watcher emits the watch-failed event and exits",
+			EnvVars: []string{"CHECK_ERRORS_GIVE_UP"},
+			Value:   defaultCheckErrorsGiveUpAfter,
+		},
+		&cli.DurationFlag{
+			Name:    checkErrorBackoffFlagName,
+			Usage:   "the waiting time until the first failed license check is repeated; it doubles with each further failure",
+			EnvVars: []string{"CHECK_ERROR_BACKOFF"},
+			Value:   time.Second,
+		},
+		&cli.DurationFlag{
+			Name:    checkErrorBackoffMaxFlagName,
+			Usage:   "the maximum waiting time until a failed license check is repeated",
+			EnvVars: []string{"CHECK_ERROR_BACKOFF_MAX"},
+			Value:   time.Minute,
+		},
+		&cli.StringFlag{
+			Name: onErrorCommandFlagName,
+			Usage: "a shell command which is executed with /bin/sh when the watcher gives up; the error is passed in ${LICENSE_WATCH_ERROR}; " +
+				"'{{' starts a Go template with the license details, write {{\"{{\"}} for a literal '{{'",
+			EnvVars: []string{"ON_ERROR_COMMAND"},
+		},
+	}
+}
+
+func createErrorPolicy(c *cli.Context) (watcher.ErrorPolicy, error) {
+	policy := watcher.ErrorPolicy{
+		Tolerated:   c.Int(checkErrorsToleratedFlagName),
+		GiveUpAfter: c.Int(checkErrorsGiveUpFlagName),
+		Backoff: watcher.Backoff{
+			Initial: c.Duration(checkErrorBackoffFlagName),
+			Max:     c.Duration(checkErrorBackoffMaxFlagName),
+		},
+	}
+
+	if policy.Tolerated < 0 {
+		return policy, errors.Errorf("value for flag '--%s' must not be negative", checkErrorsToleratedFlagName)
+	}
+	if policy.GiveUpAfter < 1 {
+		return policy, errors.Errorf("value for flag '--%s' must be at least 1", checkErrorsGiveUpFlagName)
+	}
+	if policy.Backoff.Initial <= 0 || policy.Backoff.Max <= 0 {
+		return policy, errors.Errorf("values for flags '--%s' and '--%s' must be greater than zero",
+			checkErrorBackoffFlagName, checkErrorBackoffMaxFlagName)
+	}
+
+	if command := c.String(onErrorCommandFlagName); command != "" {
+		policy.OnErrorCommand = []string{"/bin/sh", "-c", command}
+	}
+	return policy, watcher.ValidateCommandArgs(policy.OnErrorCommand)
+}
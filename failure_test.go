@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/cloudogu/confluence-license-checker/license/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"testing"
+	"time"
+)
+
+func Test_createErrorPolicy(t *testing.T) {
+	t.Run("should create policy with defaults", func(t *testing.T) {
+		var actual watcher.ErrorPolicy
+		err := runWithFlags(createErrorPolicyFlags(), []string{}, func(c *cli.Context) (err error) {
+			actual, err = createErrorPolicy(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		expected := watcher.ErrorPolicy{Tolerated: 3, GiveUpAfter: 20, Backoff: watcher.Backoff{Initial: time.Second, Max: time.Minute}}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should create policy from flags", func(t *testing.T) {
+		var actual watcher.ErrorPolicy
+		args := []string{"--check-errors-tolerated", "0", "--check-errors-give-up", "5", "--check-error-backoff", "5s",
+			"--check-error-backoff-max", "30s", "--on-error-command", "logger -t license-checker \"$LICENSE_WATCH_ERROR\""}
+		err := runWithFlags(createErrorPolicyFlags(), args, func(c *cli.Context) (err error) {
+			actual, err = createErrorPolicy(c)
+			return err
+		})
+
+		require.NoError(t, err)
+		expected := watcher.ErrorPolicy{
+			GiveUpAfter:    5,
+			Backoff:        watcher.Backoff{Initial: 5 * time.Second, Max: 30 * time.Second},
+			OnErrorCommand: []string{"/bin/sh", "-c", "logger -t license-checker \"$LICENSE_WATCH_ERROR\""},
+		}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should fail on give up threshold below 1", func(t *testing.T) {
+		err := runWithFlags(createErrorPolicyFlags(), []string{"--check-errors-give-up", "0"}, func(c *cli.Context) error {
+			_, err := createErrorPolicy(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be at least 1")
+	})
+	t.Run("should fail on invalid template in on-error command", func(t *testing.T) {
+		err := runWithFlags(createErrorPolicyFlags(), []string{"--on-error-command", "alert {{.Unknown}}"}, func(c *cli.Context) error {
+			_, err := createErrorPolicy(c)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to expand template")
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"github.com/cloudogu/confluence-license-checker/license/watcher"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	httpAddrFlagName   = "http-addr"
+	httpAddrEnvVarName = "HTTP_ADDR"
+
+	// httpShutdownTimeout limits the time running requests may take after the watcher stopped.
+	httpShutdownTimeout = 5 * time.Second
+	// httpReadHeaderTimeout protects the listener against clients which never finish their request.
+	httpReadHeaderTimeout = 10 * time.Second
+)
+
+func createHTTPAddrFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    httpAddrFlagName,
+		Usage:   "the address on which /healthz, /readyz, /status and /metrics are served, e.g. :8080; no HTTP listener is started if empty",
+		EnvVars: []string{httpAddrEnvVarName},
+	}
+}
+
+// createHTTPHandler serves the Prometheus metrics on /metrics and the endpoints of the monitor on all other paths.
+func createHTTPHandler(monitor *watcher.Monitor, metrics *watcher.Metrics) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.Handle("/", monitor.Handler())
+	return mux
+}
+
+// httpServer serves the endpoints of the watcher in the background.
+type httpServer struct {
+	server   *http.Server
+	listener net.Listener
+}
+
+// startHTTPServer listens on the given address and serves the handler until the server is stopped. The listener is
+// opened right away, so that an address which is already in use stops the watcher from starting.
+func startHTTPServer(addr string, handler http.Handler) (*httpServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen on '%s'", addr)
+	}
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: httpReadHeaderTimeout}
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("HTTP listener on '%s' failed: %s", addr, err.Error())
+		}
+	}()
+
+	log.Infof("Serving health, status and metrics endpoints on '%s'", listener.Addr())
+	return &httpServer{server: server, listener: listener}, nil
+}
+
+// Addr returns the address on which the server listens.
+func (s *httpServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Stop stops the server and lets running requests finish for a short time.
+func (s *httpServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		log.Warningf("Failed to stop HTTP listener: %s", err.Error())
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/cloudogu/confluence-license-checker/license/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_createHTTPHandler(t *testing.T) {
+	sut := createHTTPHandler(watcher.NewMonitor(watcher.Schedule{}), watcher.NewMetrics())
+
+	for path, expected := range map[string]string{"/metrics": "license_checker_checks_total", "/healthz": "ok"} {
+		recorder := httptest.NewRecorder()
+		sut.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code, path)
+		assert.Contains(t, recorder.Body.String(), expected, path)
+	}
+}
+
+func Test_startHTTPServer(t *testing.T) {
+	t.Run("should serve handler until stopped", func(t *testing.T) {
+		// given
+		handler := http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+			_, _ = io.WriteString(writer, "ok")
+		})
+
+		// when
+		sut, err := startHTTPServer("127.0.0.1:0", handler)
+
+		// then
+		require.NoError(t, err)
+		url := "http://" + sut.Addr().String() + "/healthz"
+		response, err := http.Get(url)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		assert.Equal(t, "ok", string(body))
+
+		sut.Stop()
+		_, err = http.Get(url)
+		assert.Error(t, err)
+	})
+	t.Run("should fail on address in use", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+
+		_, err = startHTTPServer(listener.Addr().String(), http.NotFoundHandler())
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to listen on")
+	})
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/cloudogu/confluence-license-checker/license/tester"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	licenseFlagName = "license"
+	outputFlagName  = "output"
+
+	outputFormatTable = "table"
+	outputFormatJSON  = "json"
+	outputFormatYAML  = "yaml"
+
+	licenseSourceConfigFile = "config-file"
+	licenseSourceFlag       = "flag"
+	licenseSourceStdin      = "stdin"
+	readFromStdin           = "-"
+
+	reportDateLayout = "2006-01-02"
+)
+
+// licenseReport contains the decoded data of a license in a printable form.
+type licenseReport struct {
+	Source                string            `json:"source" yaml:"source"`
+	License               string            `json:"license" yaml:"license"`
+	Product               string            `json:"product" yaml:"product"`
+	Edition               string            `json:"edition" yaml:"edition"`
+	LicenseType           string            `json:"licenseType" yaml:"licenseType"`
+	ExpiryDate            string            `json:"expiryDate,omitempty" yaml:"expiryDate,omitempty"`
+	MaintenanceExpiryDate string            `json:"maintenanceExpiryDate,omitempty" yaml:"maintenanceExpiryDate,omitempty"`
+	NumberOfUsers         int               `json:"numberOfUsers" yaml:"numberOfUsers"`
+	ServerID              string            `json:"serverId" yaml:"serverId"`
+	SEN                   string            `json:"sen" yaml:"sen"`
+	Organisation          string            `json:"organisation" yaml:"organisation"`
+	SetupLicense          *bool             `json:"setupLicense,omitempty" yaml:"setupLicense,omitempty"`
+	Expired               bool              `json:"expired" yaml:"expired"`
+	DaysLeft              *int              `json:"daysLeft,omitempty" yaml:"daysLeft,omitempty"`
+	Properties            map[string]string `json:"properties" yaml:"properties"`
+}
+
+func InspectCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "inspect",
+		Usage: "print the decoded Confluence license",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  licenseFlagName,
+				Usage: "the license to inspect instead of the configured one; use '-' to read it from stdin",
+			},
+			&cli.StringFlag{
+				Name:    setupLicenseFlagName,
+				Aliases: []string{"l"},
+				Usage:   "provides a setup license to compare with instead from a environment variable",
+				EnvVars: []string{setupLicenseEnvVarName},
+			},
+			createDetectionFlag(),
+			createSetupPropertyFlag(),
+			createSetupFingerprintFlag(),
+			createSetupFingerprintsFlag(),
+			&cli.StringFlag{
+				Name:    outputFlagName,
+				Aliases: []string{"o"},
+				Usage:   "the output format, one of table, json or yaml",
+				Value:   outputFormatTable,
+			},
+		},
+		Action: inspectAction,
+	}
+}
+
+func inspectAction(c *cli.Context) error {
+	format := c.String(outputFlagName)
+	if !isValidOutputFormat(format) {
+		return errors.Errorf("cannot inspect license: unsupported output format '%s'", format)
+	}
+
+	detection, err := createDetection(c)
+	if err != nil {
+		return errors.Wrap(err, "cannot inspect license")
+	}
+
+	license, source, err := readLicenseToInspect(c.String(licenseFlagName), os.Stdin)
+	if err != nil {
+		return errors.Wrap(err, "cannot inspect license")
+	}
+
+	report, err := createLicenseReport(license, source, c.String(setupLicenseFlagName), detection, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "cannot inspect license")
+	}
+
+	return printLicenseReport(c.App.Writer, report, format)
+}
+
+func isValidOutputFormat(format string) bool {
+	return format == outputFormatTable || format == outputFormatJSON || format == outputFormatYAML
+}
+
+func readLicenseToInspect(licenseFlagValue string, stdin io.Reader) (license string, source string, err error) {
+	switch licenseFlagValue {
+	case "":
+		license, err = tester.New().ReadLicense(confluenceConfigFile)
+		return license, licenseSourceConfigFile, err
+	case readFromStdin:
+		input, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", licenseSourceStdin, errors.Wrap(err, "failed to read license from stdin")
+		}
+		return strings.TrimSpace(string(input)), licenseSourceStdin, nil
+	default:
+		return licenseFlagValue, licenseSourceFlag, nil
+	}
+}
+
+func createLicenseReport(license string, source string, setupLicense string, detection tester.Detection, now time.Time) (*licenseReport, error) {
+	decoded, err := atlassian.Decode(license)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &licenseReport{
+		Source:        source,
+		License:       atlassian.Redact(license),
+		Product:       decoded.Product,
+		Edition:       decoded.Edition,
+		LicenseType:   decoded.LicenseType,
+		NumberOfUsers: decoded.NumberOfUsers,
+		ServerID:      decoded.ServerID,
+		SEN:           decoded.SEN,
+		Organisation:  decoded.Organisation,
+		Expired:       decoded.IsExpired(now),
+		Properties:    decoded.Properties,
+	}
+
+	if decoded.HasExpiry() {
+		report.ExpiryDate = decoded.ExpiryDate.Format(reportDateLayout)
+		daysLeft := decoded.DaysLeft(now)
+		report.DaysLeft = &daysLeft
+	}
+	if !decoded.MaintenanceExpiryDate.IsZero() {
+		report.MaintenanceExpiryDate = decoded.MaintenanceExpiryDate.Format(reportDateLayout)
+	}
+	if setupLicense != "" || !detection.RequiresSetupLicense() {
+		isSetupLicense := detection.IsSetupLicense(license, setupLicense, now)
+		report.SetupLicense = &isSetupLicense
+	}
+
+	return report, nil
+}
+
+func printLicenseReport(writer io.Writer, report *licenseReport, format string) error {
+	switch format {
+	case outputFormatJSON:
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return errors.Wrap(encoder.Encode(report), "failed to print license as JSON")
+	case outputFormatYAML:
+		encoder := yaml.NewEncoder(writer)
+		encoder.SetIndent(2)
+		err := encoder.Encode(report)
+		if err != nil {
+			return errors.Wrap(err, "failed to print license as YAML")
+		}
+		return errors.Wrap(encoder.Close(), "failed to print license as YAML")
+	default:
+		return printLicenseTable(writer, report)
+	}
+}
+
+func printLicenseTable(writer io.Writer, report *licenseReport) error {
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+
+	rows := [][2]string{
+		{"Source", report.Source},
+		{"License", report.License},
+		{"Product", report.Product},
+		{"Edition", report.Edition},
+		{"License type", report.LicenseType},
+		{"Expiry date", valueOrDefault(report.ExpiryDate, "never")},
+		{"Maintenance expiry date", valueOrDefault(report.MaintenanceExpiryDate, "-")},
+		{"Number of users", formatNumberOfUsers(report.NumberOfUsers)},
+		{"Server ID", valueOrDefault(report.ServerID, "-")},
+		{"SEN", valueOrDefault(report.SEN, "-")},
+		{"Organisation", valueOrDefault(report.Organisation, "-")},
+		{"Setup license", formatOptionalBool(report.SetupLicense)},
+		{"Expired", fmt.Sprintf("%t", report.Expired)},
+		{"Days left", formatOptionalInt(report.DaysLeft)},
+	}
+	for _, row := range rows {
+		_, _ = fmt.Fprintf(table, "%s:\t%s\n", row[0], row[1])
+	}
+
+	if len(report.Properties) > 0 {
+		_, _ = fmt.Fprintln(table, "Properties:\t")
+		keys := make([]string, 0, len(report.Properties))
+		for key := range report.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			_, _ = fmt.Fprintf(table, "  %s\t%s\n", key, strings.ReplaceAll(report.Properties[key], "\n", `\n`))
+		}
+	}
+
+	return errors.Wrap(table.Flush(), "failed to print license table")
+}
+
+func valueOrDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func formatNumberOfUsers(users int) string {
+	if users == atlassian.UnlimitedUsers {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d", users)
+}
+
+func formatOptionalBool(value *bool) string {
+	if value == nil {
+		return "unknown (no setup license given)"
+	}
+	return fmt.Sprintf("%t", *value)
+}
+
+func formatOptionalInt(value *int) string {
+	if value == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d", *value)
+}
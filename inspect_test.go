@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/cloudogu/confluence-license-checker/license/tester"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func TestInspectCommand(t *testing.T) {
+	actual := InspectCommand()
+
+	require.NotNil(t, actual)
+}
+
+func Test_readLicenseToInspect(t *testing.T) {
+	t.Run("should return license from flag", func(t *testing.T) {
+		actual, source, err := readLicenseToInspect("AAAB", strings.NewReader("ignored"))
+
+		require.NoError(t, err)
+		assert.Equal(t, "AAAB", actual)
+		assert.Equal(t, "flag", source)
+	})
+	t.Run("should read license from stdin", func(t *testing.T) {
+		actual, source, err := readLicenseToInspect("-", strings.NewReader("AAAB\nCDEF\n"))
+
+		require.NoError(t, err)
+		assert.Equal(t, "AAAB\nCDEF", actual)
+		assert.Equal(t, "stdin", source)
+	})
+}
+
+func Test_createLicenseReport(t *testing.T) {
+	t.Run("should create report for production license", func(t *testing.T) {
+		license := encodeTestLicense(t, "2026-12-31")
+
+		// when
+		actual, err := createLicenseReport(license, "flag", "", tester.Detection{}, testNow)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "flag", actual.Source)
+		assert.Equal(t, atlassian.Redact(license), actual.License)
+		assert.NotContains(t, actual.License, license)
+		assert.Equal(t, "confluence", actual.Product)
+		assert.Equal(t, "DATACENTER", actual.Edition)
+		assert.Equal(t, "COMMERCIAL", actual.LicenseType)
+		assert.Equal(t, "2026-12-31", actual.ExpiryDate)
+		assert.Equal(t, 25, actual.NumberOfUsers)
+		assert.Equal(t, "SEN-L1", actual.SEN)
+		assert.False(t, actual.Expired)
+		require.NotNil(t, actual.DaysLeft)
+		assert.Equal(t, 74, *actual.DaysLeft)
+		assert.Nil(t, actual.SetupLicense)
+	})
+	t.Run("should mark expired setup license", func(t *testing.T) {
+		license := encodeTestLicense(t, "2020-07-22")
+
+		// when
+		actual, err := createLicenseReport(license, "config-file", license, tester.Detection{}, testNow)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, actual.Expired)
+		require.NotNil(t, actual.SetupLicense)
+		assert.True(t, *actual.SetupLicense)
+		assert.Less(t, *actual.DaysLeft, 0)
+	})
+	t.Run("should not report expiry of unlimited license", func(t *testing.T) {
+		license := encodeTestLicense(t, "")
+
+		// when
+		actual, err := createLicenseReport(license, "flag", "other", tester.Detection{}, testNow)
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, actual.ExpiryDate)
+		assert.Nil(t, actual.DaysLeft)
+		assert.False(t, actual.Expired)
+		assert.False(t, *actual.SetupLicense)
+	})
+	t.Run("should detect expired license as setup license in decoded mode", func(t *testing.T) {
+		license := encodeTestLicense(t, "2020-07-22")
+
+		// when
+		actual, err := createLicenseReport(license, "flag", "", tester.Detection{Mode: tester.DetectionModeDecoded}, testNow)
+
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, actual.SetupLicense)
+		assert.True(t, *actual.SetupLicense)
+	})
+	t.Run("should fail on undecodable license", func(t *testing.T) {
+		_, err := createLicenseReport("AAAB", "flag", "", tester.Detection{}, testNow)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to decode license")
+	})
+}
+
+func Test_printLicenseReport(t *testing.T) {
+	report, err := createLicenseReport(encodeTestLicense(t, "2026-12-31"), "flag", "", tester.Detection{}, testNow)
+	require.NoError(t, err)
+
+	t.Run("should print table", func(t *testing.T) {
+		var out bytes.Buffer
+
+		err := printLicenseReport(&out, report, "table")
+
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "Product:                  confluence\n")
+		assert.Contains(t, out.String(), "Days left:                74\n")
+		assert.Contains(t, out.String(), "Setup license:            unknown (no setup license given)\n")
+		assert.Contains(t, out.String(), "  conf.NumberOfUsers      25\n")
+	})
+	t.Run("should print json", func(t *testing.T) {
+		var out bytes.Buffer
+
+		err := printLicenseReport(&out, report, "json")
+
+		require.NoError(t, err)
+		actual := &licenseReport{}
+		require.NoError(t, json.Unmarshal(out.Bytes(), actual))
+		assert.Equal(t, report, actual)
+	})
+	t.Run("should print yaml", func(t *testing.T) {
+		var out bytes.Buffer
+
+		err := printLicenseReport(&out, report, "yaml")
+
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "product: confluence\n")
+		actual := &licenseReport{}
+		require.NoError(t, yaml.Unmarshal(out.Bytes(), actual))
+		assert.Equal(t, report, actual)
+	})
+}
+
+func Test_isValidOutputFormat(t *testing.T) {
+	assert.True(t, isValidOutputFormat("table"))
+	assert.True(t, isValidOutputFormat("json"))
+	assert.True(t, isValidOutputFormat("yaml"))
+	assert.False(t, isValidOutputFormat("xml"))
+}
+
+func encodeTestLicense(t *testing.T, expiryDate string) string {
+	t.Helper()
+
+	properties := map[string]string{
+		"conf.active":          "true",
+		"conf.LicenseEdition":  "DATACENTER",
+		"conf.LicenseTypeName": "COMMERCIAL",
+		"conf.NumberOfUsers":   "25",
+		"SEN":                  "SEN-L1",
+		"Organisation":         "Cloudogu GmbH",
+	}
+	if expiryDate != "" {
+		properties["LicenseExpiryDate"] = expiryDate
+	}
+
+	license, err := atlassian.Encode(properties)
+	require.NoError(t, err)
+	return license
+}
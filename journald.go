@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/op/go-logging"
+	"github.com/pkg/errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// journaldSocket is the socket on which journald receives log entries in its native protocol.
+const journaldSocket = "/run/systemd/journal/socket"
+
+// journaldPriorities maps log levels to syslog priorities.
+var journaldPriorities = map[logging.Level]int{
+	logging.CRITICAL: 2,
+	logging.ERROR:    3,
+	logging.WARNING:  4,
+	logging.NOTICE:   5,
+	logging.INFO:     6,
+	logging.DEBUG:    7,
+}
+
+// journaldBackend sends log records to journald with their structured fields as journal fields, e.g. COMPONENT.
+type journaldBackend struct {
+	conn       net.Conn
+	configFile string
+}
+
+func newJournaldBackend(configFile string) (*journaldBackend, error) {
+	conn, err := net.Dial("unixgram", journaldSocket)
+	if err != nil {
+		return nil, err
+	}
+	return &journaldBackend{conn: conn, configFile: configFile}, nil
+}
+
+func (jb *journaldBackend) Log(level logging.Level, _ int, record *logging.Record) error {
+	_, err := jb.conn.Write(journaldEntry(level, record, jb.configFile))
+	return errors.Wrap(err, "failed to send log entry to journald")
+}
+
+// journaldEntry encodes a log record in the native journal protocol.
+func journaldEntry(level logging.Level, record *logging.Record, configFile string) []byte {
+	fields := fieldsOf(record, configFile)
+	entry := &bytes.Buffer{}
+	writeJournaldField(entry, "MESSAGE", record.Message())
+	writeJournaldField(entry, "PRIORITY", strconv.Itoa(journaldPriorities[level]))
+	writeJournaldField(entry, "SYSLOG_IDENTIFIER", logIdentifier)
+	writeJournaldField(entry, "COMPONENT", fields.Component)
+	writeJournaldField(entry, "CONFIG_FILE", fields.ConfigFile)
+	if fields.EventType != "" {
+		writeJournaldField(entry, "EVENT_TYPE", fields.EventType)
+	}
+	return entry.Bytes()
+}
+
+// writeJournaldField writes a field as KEY=value line. Values with line breaks are written with their length instead.
+func writeJournaldField(entry *bytes.Buffer, key string, value string) {
+	if !strings.Contains(value, "\n") {
+		entry.WriteString(key + "=" + value + "\n")
+		return
+	}
+
+	entry.WriteString(key + "\n")
+	_ = binary.Write(entry, binary.LittleEndian, uint64(len(value)))
+	entry.WriteString(value + "\n")
+}
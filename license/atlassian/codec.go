@@ -0,0 +1,303 @@
+package atlassian
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// versionSeparator separates the encoded license from its version and length suffix.
+	versionSeparator = "X"
+	// licenseVersion2 is the only supported license format version.
+	licenseVersion2 = "02"
+	// lengthRadix is the radix in which the length of the encoded license is appended.
+	lengthRadix = 31
+)
+
+// licensePrefix is the magic header in front of the compressed license body.
+var licensePrefix = []byte{13, 14, 12, 10, 15}
+
+// Decode decodes an Atlassian license blob. Whitespace and line breaks inside the blob are ignored.
+//
+// The blob consists of the base64 payload, followed by 'X', the format version and the length of the payload in
+// base 31. The payload contains a big endian length-prefixed body and a signature. The body starts with a magic
+// header, followed by the zlib compressed license properties. The signature is not verified.
+func Decode(blob string) (*License, error) {
+	normalized := Normalize(blob)
+	if normalized == "" {
+		return nil, errors.New("failed to decode license: license is empty")
+	}
+
+	payload, err := splitPayload(normalized)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode license")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode license: invalid base64 payload")
+	}
+
+	body, err := extractBody(decoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode license")
+	}
+
+	properties, err := parseProperties(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode license: invalid license properties")
+	}
+
+	return newLicense(properties), nil
+}
+
+// Encode creates an unsigned license blob from the given properties. It is the counterpart of Decode and is meant
+// to create license fixtures because Atlassian products will reject licenses without a valid signature.
+func Encode(properties map[string]string) (string, error) {
+	var compressed bytes.Buffer
+	compressed.Write(licensePrefix)
+	zlibWriter := zlib.NewWriter(&compressed)
+	_, err := zlibWriter.Write([]byte(formatProperties(properties)))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode license")
+	}
+	err = zlibWriter.Close()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode license")
+	}
+
+	raw := make([]byte, 4, 4+compressed.Len())
+	binary.BigEndian.PutUint32(raw, uint32(compressed.Len()))
+	raw = append(raw, compressed.Bytes()...)
+
+	payload := base64.StdEncoding.EncodeToString(raw)
+	return payload + versionSeparator + licenseVersion2 + strconv.FormatInt(int64(len(payload)), lengthRadix), nil
+}
+
+// Normalize removes all whitespace, e.g. line breaks, from a license blob.
+func Normalize(blob string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, blob)
+}
+
+// Fingerprint returns the hex encoded SHA-256 digest of the normalized license blob. An empty blob has no fingerprint.
+func Fingerprint(blob string) string {
+	normalized := Normalize(blob)
+	if normalized == "" {
+		return ""
+	}
+
+	digest := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(digest[:])
+}
+
+func splitPayload(blob string) (string, error) {
+	separatorIndex := strings.LastIndex(blob, versionSeparator)
+	if separatorIndex < 0 || len(blob) < separatorIndex+len(versionSeparator)+len(licenseVersion2)+1 {
+		return "", errors.New("missing license version suffix")
+	}
+
+	versionStart := separatorIndex + len(versionSeparator)
+	version := blob[versionStart : versionStart+len(licenseVersion2)]
+	if version != licenseVersion2 {
+		return "", errors.Errorf("unsupported license version '%s'", version)
+	}
+
+	lengthStr := blob[versionStart+len(licenseVersion2):]
+	length, err := strconv.ParseInt(lengthStr, lengthRadix, 64)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid license length '%s'", lengthStr)
+	}
+	if length != int64(separatorIndex) {
+		return "", errors.Errorf("license length %d does not match the payload length %d", length, separatorIndex)
+	}
+
+	return blob[:separatorIndex], nil
+}
+
+func extractBody(decoded []byte) ([]byte, error) {
+	if len(decoded) < 4 {
+		return nil, errors.New("payload too short")
+	}
+
+	bodyLength := binary.BigEndian.Uint32(decoded[:4])
+	if uint64(bodyLength) > uint64(len(decoded)-4) {
+		return nil, errors.Errorf("body length %d exceeds the payload length %d", bodyLength, len(decoded)-4)
+	}
+
+	body := decoded[4 : 4+bodyLength]
+	if !bytes.HasPrefix(body, licensePrefix) {
+		return nil, errors.New("unexpected license body header")
+	}
+
+	zlibReader, err := zlib.NewReader(bytes.NewReader(body[len(licensePrefix):]))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress license body")
+	}
+	defer zlibReader.Close()
+
+	decompressed, err := io.ReadAll(zlibReader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress license body")
+	}
+
+	return decompressed, nil
+}
+
+// parseProperties parses the body in the Java properties format.
+func parseProperties(body []byte) (map[string]string, error) {
+	properties := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	logicalLine := ""
+	for scanner.Scan() {
+		line := strings.TrimLeftFunc(scanner.Text(), unicode.IsSpace)
+		if logicalLine == "" && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+
+		if hasContinuation(line) {
+			logicalLine += line[:len(line)-1]
+			continue
+		}
+
+		logicalLine += line
+		key, value := splitProperty(logicalLine)
+		properties[key] = value
+		logicalLine = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if logicalLine != "" {
+		key, value := splitProperty(logicalLine)
+		properties[key] = value
+	}
+
+	return properties, nil
+}
+
+// hasContinuation checks if a line ends with an odd number of backslashes.
+func hasContinuation(line string) bool {
+	backslashes := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		backslashes++
+	}
+	return backslashes%2 == 1
+}
+
+func splitProperty(line string) (key string, value string) {
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '=' || r == ':' || unicode.IsSpace(r):
+			rest := strings.TrimLeftFunc(line[i:], unicode.IsSpace)
+			if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+				rest = strings.TrimLeftFunc(rest[1:], unicode.IsSpace)
+			}
+			return unescapeProperty(line[:i]), unescapeProperty(rest)
+		}
+	}
+
+	return unescapeProperty(line), ""
+}
+
+func unescapeProperty(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var result strings.Builder
+	runes := []rune(value)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '\\' || i == len(runes)-1 {
+			result.WriteRune(runes[i])
+			continue
+		}
+
+		i++
+		switch runes[i] {
+		case 't':
+			result.WriteRune('\t')
+		case 'n':
+			result.WriteRune('\n')
+		case 'r':
+			result.WriteRune('\r')
+		case 'f':
+			result.WriteRune('\f')
+		case 'u':
+			if i+4 < len(runes) {
+				if code, err := strconv.ParseUint(string(runes[i+1:i+5]), 16, 32); err == nil {
+					result.WriteRune(rune(code))
+					i += 4
+					continue
+				}
+			}
+			result.WriteRune('u')
+		default:
+			result.WriteRune(runes[i])
+		}
+	}
+
+	return result.String()
+}
+
+func formatProperties(properties map[string]string) string {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var result strings.Builder
+	for _, key := range keys {
+		result.WriteString(escapeProperty(key, true))
+		result.WriteString("=")
+		result.WriteString(escapeProperty(properties[key], false))
+		result.WriteString("\n")
+	}
+
+	return result.String()
+}
+
+func escapeProperty(value string, isKey bool) string {
+	var result strings.Builder
+	for i, r := range value {
+		switch {
+		case r == '\\' || r == '=' || r == ':' || r == '#' || r == '!':
+			result.WriteRune('\\')
+			result.WriteRune(r)
+		case r == '\n':
+			result.WriteString(`\n`)
+		case r == '\r':
+			result.WriteString(`\r`)
+		case r == '\t':
+			result.WriteString(`\t`)
+		case r == ' ' && (isKey || i == 0):
+			result.WriteString(`\ `)
+		default:
+			result.WriteRune(r)
+		}
+	}
+
+	return result.String()
+}
@@ -0,0 +1,193 @@
+package atlassian
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	t.Run("should decode encoded license", func(t *testing.T) {
+		blob := encodeLicense(t, getConfluenceProperties())
+
+		// when
+		actual, err := Decode(blob)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "confluence", actual.Product)
+		assert.Equal(t, "ENTERPRISE", actual.Edition)
+		assert.Equal(t, "COMMERCIAL", actual.LicenseType)
+		assert.Equal(t, time.Date(2027, 3, 31, 0, 0, 0, 0, time.UTC), actual.ExpiryDate)
+		assert.Equal(t, time.Date(2027, 4, 30, 0, 0, 0, 0, time.UTC), actual.MaintenanceExpiryDate)
+		assert.Equal(t, 500, actual.NumberOfUsers)
+		assert.Equal(t, "BXYZ-1234-ABCD-5678", actual.ServerID)
+		assert.Equal(t, "SEN-L12345678", actual.SEN)
+		assert.Equal(t, "Cloudogu GmbH", actual.Organisation)
+		assert.Equal(t, getConfluenceProperties(), actual.Properties)
+	})
+	t.Run("should ignore whitespace and line breaks", func(t *testing.T) {
+		blob := encodeLicense(t, getConfluenceProperties())
+		var wrapped strings.Builder
+		for i := 0; i < len(blob); i += 76 {
+			end := i + 76
+			if end > len(blob) {
+				end = len(blob)
+			}
+			wrapped.WriteString("  " + blob[i:end] + "\r\n")
+		}
+
+		// when
+		actual, err := Decode(wrapped.String())
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "SEN-L12345678", actual.SEN)
+	})
+	t.Run("should fail on empty license", func(t *testing.T) {
+		_, err := Decode(" \n ")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "license is empty")
+	})
+	t.Run("should fail on missing version suffix", func(t *testing.T) {
+		_, err := Decode("AAAABBBB")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing license version suffix")
+	})
+	t.Run("should fail on unsupported version", func(t *testing.T) {
+		_, err := Decode("AAAABBBBX018")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported license version '01'")
+	})
+	t.Run("should fail on length mismatch", func(t *testing.T) {
+		_, err := Decode("AAAABBBBX029")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "license length 9 does not match the payload length 8")
+	})
+	t.Run("should fail on invalid base64", func(t *testing.T) {
+		_, err := Decode("AAAA*BBBX028")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid base64 payload")
+	})
+	t.Run("should fail on unexpected body header", func(t *testing.T) {
+		// 4 byte length (1) followed by a single zero byte
+		_, err := Decode("AAAAAQA=X028")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unexpected license body header")
+	})
+	t.Run("should fail on synthetic test fixtures which are no real licenses", func(t *testing.T) {
+		_, err := Decode(`AAABOA0ODAoPeNp9UVtPwjAUfu+SETUP++SETUP+X BNy0Y6X9vBnwzAW2/+QZTOctX88X02fj`)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to decode license")
+	})
+}
+
+func TestEncode(t *testing.T) {
+	t.Run("should append version and length suffix", func(t *testing.T) {
+		actual, err := Encode(map[string]string{"key": "value"})
+
+		require.NoError(t, err)
+		separatorIndex := strings.LastIndex(actual, "X02")
+		require.Greater(t, separatorIndex, 0)
+		assert.Equal(t, strconv.FormatInt(int64(separatorIndex), 31), actual[separatorIndex+3:])
+	})
+	t.Run("should round trip properties with special characters", func(t *testing.T) {
+		properties := map[string]string{
+			"Description":  "Confluence: Evaluation = yes",
+			"key with spc": " leading space",
+			"multi":        "line1\nline2",
+			"backslash":    `C:\temp`,
+			"umlaut":       "Müller & Söhne",
+			"empty":        "",
+		}
+
+		// when
+		blob, err := Encode(properties)
+		require.NoError(t, err)
+		actual, err := Decode(blob)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, properties, actual.Properties)
+	})
+}
+
+func Test_parseProperties(t *testing.T) {
+	t.Run("should parse java properties", func(t *testing.T) {
+		body := "#Mon Jan 01 00:00:00 UTC 2024\n" +
+			"! another comment\n" +
+			"Description=Confluence\\: Evaluation\n" +
+			"  indented : value\n" +
+			"spaced value\n" +
+			"continued=first \\\n    second\n" +
+			"unicode=M\\u00fcller\n" +
+			"noValue\n"
+
+		// when
+		actual, err := parseProperties([]byte(body))
+
+		// then
+		require.NoError(t, err)
+		expected := map[string]string{
+			"Description": "Confluence: Evaluation",
+			"indented":    "value",
+			"spaced":      "value",
+			"continued":   "first second",
+			"unicode":     "Müller",
+			"noValue":     "",
+		}
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "AAAABBBBX02c", Normalize(" AAAA\nBBBB\r\n\tX02c "))
+}
+
+func TestFingerprint(t *testing.T) {
+	expected := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	assert.Equal(t, expected, Fingerprint("foo\n"))
+	assert.Equal(t, expected, Fingerprint(" f\no o"))
+	assert.Equal(t, "", Fingerprint(" \n"))
+}
+
+// test util stuff
+
+func getConfluenceProperties() map[string]string {
+	return map[string]string{
+		"Description":                "Confluence (Data Center): Commercial",
+		"CreationDate":               "2026-03-31",
+		"conf.active":                "true",
+		"conf.LicenseEdition":        "ENTERPRISE",
+		"conf.LicenseTypeName":       "COMMERCIAL",
+		"conf.NumberOfUsers":         "500",
+		"conf.DataCenter":            "true",
+		"LicenseExpiryDate":          "2027-03-31",
+		"MaintenanceExpiryDate":      "2027-04-30",
+		"ServerID":                   "BXYZ-1234-ABCD-5678",
+		"SEN":                        "SEN-L12345678",
+		"Organisation":               "Cloudogu GmbH",
+		"licenseVersion":             "2",
+		"ContactEMail":               "admin@example.com",
+		"com.atlassian.foo.active":   "false",
+		"com.atlassian.bar.Property": "true",
+	}
+}
+
+func encodeLicense(t *testing.T, properties map[string]string) string {
+	t.Helper()
+
+	blob, err := Encode(properties)
+	require.NoError(t, err)
+	return blob
+}
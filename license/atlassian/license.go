@@ -0,0 +1,174 @@
+package atlassian
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	propertyActiveSuffix          = ".active"
+	propertyLicenseEdition        = "LicenseEdition"
+	propertyLicenseTypeName       = "LicenseTypeName"
+	propertyNumberOfUsers         = "NumberOfUsers"
+	propertyLicenseExpiryDate     = "LicenseExpiryDate"
+	propertyMaintenanceExpiryDate = "MaintenanceExpiryDate"
+	propertyServerID              = "ServerID"
+	propertySEN                   = "SEN"
+	propertyOrganisation          = "Organisation"
+
+	dateLayout = "2006-01-02"
+	// UnlimitedUsers is the number of users of a license which is not limited to a certain number of users.
+	UnlimitedUsers = -1
+)
+
+// productNamespaces maps the property namespaces of known Atlassian products to their product names.
+var productNamespaces = map[string]string{
+	"conf":                           "confluence",
+	"jira":                           "jira",
+	"jira.product.jira-software":     "jira-software",
+	"jira.product.jira-core":         "jira-core",
+	"jira.product.jira-servicedesk":  "jira-servicedesk",
+	"bitbucket":                      "bitbucket",
+	"stash":                          "bitbucket",
+	"bamboo":                         "bamboo",
+	"crowd":                          "crowd",
+	"fisheye":                        "fisheye",
+	"crucible":                       "crucible",
+	"greenhopper":                    "greenhopper",
+	"com.atlassian.confluence.addon": "confluence-addon",
+}
+
+// License contains the decoded data of an Atlassian license.
+type License struct {
+	// Product is the name of the licensed product, e.g. "confluence".
+	Product string
+	// Edition is the licensed edition, e.g. "ENTERPRISE".
+	Edition string
+	// LicenseType is the licence type, e.g. "COMMERCIAL" or "EVALUATION".
+	LicenseType string
+	// ExpiryDate is the date on which the license expires. It is the zero time if the license does not expire.
+	ExpiryDate time.Time
+	// MaintenanceExpiryDate is the date on which the maintenance expires. It is the zero time if not set.
+	MaintenanceExpiryDate time.Time
+	// NumberOfUsers is the number of licensed users. It is UnlimitedUsers if the license is not limited.
+	NumberOfUsers int
+	// ServerID is the ID of the server to which the license is bound.
+	ServerID string
+	// SEN is the support entitlement number.
+	SEN string
+	// Organisation is the organisation to which the license was issued.
+	Organisation string
+	// Properties contains all properties of the license as found in the license body.
+	Properties map[string]string
+}
+
+// HasExpiry returns true if the license expires at some point in time.
+func (l *License) HasExpiry() bool {
+	return !l.ExpiryDate.IsZero()
+}
+
+// IsExpired returns true if the license expired at the given point in time.
+func (l *License) IsExpired(now time.Time) bool {
+	return l.HasExpiry() && !now.Before(l.ExpiryDate)
+}
+
+// DaysLeft returns the number of started days until the license expires. The result is zero or negative if the
+// license already expired and zero if the license does not expire at all.
+func (l *License) DaysLeft(now time.Time) int {
+	if !l.HasExpiry() {
+		return 0
+	}
+
+	left := l.ExpiryDate.Sub(now)
+	if left <= 0 {
+		return -int(-left / (24 * time.Hour))
+	}
+
+	return int((left + 24*time.Hour - 1) / (24 * time.Hour))
+}
+
+func newLicense(properties map[string]string) *License {
+	namespace := findProductNamespace(properties)
+	lookup := func(key string) string {
+		if namespace != "" {
+			if value, ok := properties[namespace+"."+key]; ok {
+				return value
+			}
+		}
+		return properties[key]
+	}
+
+	return &License{
+		Product:               productName(namespace),
+		Edition:               lookup(propertyLicenseEdition),
+		LicenseType:           lookup(propertyLicenseTypeName),
+		ExpiryDate:            parseDate(lookup(propertyLicenseExpiryDate)),
+		MaintenanceExpiryDate: parseDate(lookup(propertyMaintenanceExpiryDate)),
+		NumberOfUsers:         parseNumberOfUsers(lookup(propertyNumberOfUsers)),
+		ServerID:              lookup(propertyServerID),
+		SEN:                   lookup(propertySEN),
+		Organisation:          lookup(propertyOrganisation),
+		Properties:            properties,
+	}
+}
+
+// findProductNamespace returns the namespace of the first active product. Known products take precedence, otherwise
+// the lexically smallest active namespace is returned so that the result is deterministic.
+func findProductNamespace(properties map[string]string) string {
+	found := ""
+	for key, value := range properties {
+		if !strings.HasSuffix(key, propertyActiveSuffix) || value != "true" {
+			continue
+		}
+
+		namespace := strings.TrimSuffix(key, propertyActiveSuffix)
+		_, known := productNamespaces[namespace]
+		_, foundKnown := productNamespaces[found]
+		switch {
+		case found == "":
+			found = namespace
+		case known && !foundKnown:
+			found = namespace
+		case known == foundKnown && namespace < found:
+			found = namespace
+		}
+	}
+
+	return found
+}
+
+func productName(namespace string) string {
+	if name, ok := productNamespaces[namespace]; ok {
+		return name
+	}
+
+	return namespace
+}
+
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+
+	if date, err := time.Parse(dateLayout, value); err == nil {
+		return date
+	}
+
+	// older licenses carry dates as milliseconds since epoch
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil && millis > 0 {
+		return time.UnixMilli(millis).UTC()
+	}
+
+	return time.Time{}
+}
+
+func parseNumberOfUsers(value string) int {
+	users, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || users < 0 {
+		return UnlimitedUsers
+	}
+
+	return users
+}
@@ -0,0 +1,86 @@
+package atlassian
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_newLicense(t *testing.T) {
+	t.Run("should prefer product namespaced properties", func(t *testing.T) {
+		properties := map[string]string{
+			"conf.active":          "true",
+			"conf.LicenseEdition":  "ENTERPRISE",
+			"LicenseEdition":       "STARTER",
+			"conf.LicenseTypeName": "EVALUATION",
+		}
+
+		// when
+		actual := newLicense(properties)
+
+		// then
+		assert.Equal(t, "confluence", actual.Product)
+		assert.Equal(t, "ENTERPRISE", actual.Edition)
+		assert.Equal(t, "EVALUATION", actual.LicenseType)
+	})
+	t.Run("should prefer known products over unknown ones", func(t *testing.T) {
+		properties := map[string]string{
+			"aaa.unknown.active": "true",
+			"conf.active":        "true",
+		}
+
+		// when
+		actual := newLicense(properties)
+
+		// then
+		assert.Equal(t, "confluence", actual.Product)
+	})
+	t.Run("should use namespace of unknown product", func(t *testing.T) {
+		properties := map[string]string{"com.example.plugin.active": "true"}
+
+		// when
+		actual := newLicense(properties)
+
+		// then
+		assert.Equal(t, "com.example.plugin", actual.Product)
+	})
+	t.Run("should return defaults for missing properties", func(t *testing.T) {
+		actual := newLicense(map[string]string{})
+
+		assert.Empty(t, actual.Product)
+		assert.True(t, actual.ExpiryDate.IsZero())
+		assert.True(t, actual.MaintenanceExpiryDate.IsZero())
+		assert.Equal(t, UnlimitedUsers, actual.NumberOfUsers)
+	})
+}
+
+func Test_parseDate(t *testing.T) {
+	assert.Equal(t, time.Date(2020, 7, 22, 0, 0, 0, 0, time.UTC), parseDate("2020-07-22"))
+	assert.Equal(t, time.Date(2020, 7, 22, 0, 0, 0, 0, time.UTC), parseDate("1595376000000"))
+	assert.True(t, parseDate("Unlimited").IsZero())
+	assert.True(t, parseDate("").IsZero())
+}
+
+func Test_parseNumberOfUsers(t *testing.T) {
+	assert.Equal(t, 25, parseNumberOfUsers("25"))
+	assert.Equal(t, UnlimitedUsers, parseNumberOfUsers("-1"))
+	assert.Equal(t, UnlimitedUsers, parseNumberOfUsers(""))
+}
+
+func TestLicense_IsExpired(t *testing.T) {
+	sut := &License{ExpiryDate: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)}
+
+	assert.False(t, sut.IsExpired(time.Date(2026, 10, 17, 23, 59, 59, 0, time.UTC)))
+	assert.True(t, sut.IsExpired(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, (&License{}).IsExpired(time.Now()))
+}
+
+func TestLicense_DaysLeft(t *testing.T) {
+	sut := &License{ExpiryDate: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)}
+
+	assert.Equal(t, 1, sut.DaysLeft(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 30, sut.DaysLeft(time.Date(2026, 9, 18, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 0, sut.DaysLeft(time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, -2, sut.DaysLeft(time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 0, (&License{}).DaysLeft(time.Now()))
+}
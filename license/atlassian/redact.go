@@ -0,0 +1,41 @@
+package atlassian
+
+import (
+	"sync/atomic"
+)
+
+// redactedChars is the number of characters at the start and at the end of a license which Redact keeps.
+const redactedChars = 6
+
+var showLicenses atomic.Bool
+
+// ShowLicenses disables the redaction of Redact so that licenses are shown in full. It is only meant for debugging
+// because licenses end up in logs, error messages and state files afterwards.
+func ShowLicenses(show bool) {
+	showLicenses.Store(show)
+}
+
+// Redact returns a form of the license blob which may be logged or printed: its first and last characters and its
+// fingerprint, e.g. "AAABrQ...X02kt9 (sha256:3f9a...)". Short blobs are only shown by their fingerprint. An empty
+// blob is returned as empty string. The normalized blob is returned in full if ShowLicenses was enabled.
+func Redact(blob string) string {
+	if showLicenses.Load() {
+		return Normalize(blob)
+	}
+	return Abbreviate(blob)
+}
+
+// Abbreviate returns the same form of the license blob as Redact, but ignores ShowLicenses. It is meant for data
+// which outlives the process, e.g. state files, so that debugging does not leave licenses on disk.
+func Abbreviate(blob string) string {
+	normalized := Normalize(blob)
+	if normalized == "" {
+		return ""
+	}
+
+	fingerprint := "sha256:" + Fingerprint(normalized)
+	if len(normalized) <= 4*redactedChars {
+		return fingerprint
+	}
+	return normalized[:redactedChars] + "..." + normalized[len(normalized)-redactedChars:] + " (" + fingerprint + ")"
+}
@@ -0,0 +1,42 @@
+package atlassian
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	blob := "AAABrQ0ODAoPeNqFkV1PgjAUhu/7K5p4jWHgF0m9QHN\nhvmcBc0gEX02"
+
+	t.Run("should keep first and last characters and fingerprint", func(t *testing.T) {
+		actual := Redact(blob)
+
+		assert.Equal(t, "AAABrQ...0gEX02 (sha256:"+Fingerprint(blob)+")", actual)
+	})
+	t.Run("should only show fingerprint of short licenses", func(t *testing.T) {
+		assert.Equal(t, "sha256:"+Fingerprint("AAAB"), Redact("AAAB"))
+	})
+	t.Run("should return empty string for empty license", func(t *testing.T) {
+		assert.Empty(t, Redact(" \n"))
+	})
+	t.Run("should show full license if enabled", func(t *testing.T) {
+		ShowLicenses(true)
+		defer ShowLicenses(false)
+
+		assert.Equal(t, Normalize(blob), Redact(blob))
+	})
+}
+
+func TestAbbreviate(t *testing.T) {
+	blob := "AAABrQ0ODAoPeNqFkV1PgjAUhu/7K5p4jWHgF0m9QHN\nhvmcBc0gEX02"
+
+	t.Run("should redact even if licenses are shown", func(t *testing.T) {
+		ShowLicenses(true)
+		defer ShowLicenses(false)
+
+		assert.Equal(t, "AAABrQ...0gEX02 (sha256:"+Fingerprint(blob)+")", Abbreviate(blob))
+	})
+	t.Run("should return empty string for empty license", func(t *testing.T) {
+		assert.Empty(t, Abbreviate(""))
+	})
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"encoding/xml"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// LicenseProperty is the name of the property which holds the Confluence license.
+const LicenseProperty = "atlassian.license.message"
+
+// SetupStepComplete is the setup step of a Confluence instance whose setup is finished.
+const SetupStepComplete = "complete"
+
+// Configuration represents the parts of a Confluence configuration file (confluence.cfg.xml) that are of interest
+// for the license checker.
+type Configuration struct {
+	XMLName     xml.Name   `xml:"confluence-configuration"`
+	SetupStep   string     `xml:"setupStep"`
+	SetupType   string     `xml:"setupType"`
+	BuildNumber string     `xml:"buildNumber"`
+	Properties  []Property `xml:"properties>property"`
+}
+
+// Property is a single named property of a Confluence configuration.
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Parse reads a Confluence configuration from the given reader. XML entities in property values are unescaped.
+func Parse(reader io.Reader) (*Configuration, error) {
+	cfg := &Configuration{}
+
+	err := xml.NewDecoder(reader).Decode(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse Confluence configuration")
+	}
+
+	return cfg, nil
+}
+
+// Property returns the value of the property with the given name. The second return value is false if the property
+// does not exist.
+func (c *Configuration) Property(name string) (string, bool) {
+	for _, property := range c.Properties {
+		if property.Name == name {
+			return property.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// License returns the value of the license property. The second return value is false if the property does not exist.
+func (c *Configuration) License() (string, bool) {
+	return c.Property(LicenseProperty)
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("should parse configuration", func(t *testing.T) {
+		content := `<?xml version="1.0" encoding="UTF-8"?>
+
+<confluence-configuration>
+  <setupStep>complete</setupStep>
+  <setupType>custom</setupType>
+  <buildNumber>8501</buildNumber>
+  <properties>
+    <property name="admin.ui.allow.daily.backup.custom.location">false</property>
+    <property name="atlassian.license.message">AAAB&#10;CDEF&lt;X02</property>
+  </properties>
+</confluence-configuration>`
+
+		// when
+		actual, err := Parse(strings.NewReader(content))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "complete", actual.SetupStep)
+		assert.Equal(t, "custom", actual.SetupType)
+		assert.Equal(t, "8501", actual.BuildNumber)
+		require.Len(t, actual.Properties, 2)
+		license, found := actual.License()
+		assert.True(t, found)
+		assert.Equal(t, "AAAB\nCDEF<X02", license)
+	})
+	t.Run("should fail on empty content", func(t *testing.T) {
+		_, err := Parse(strings.NewReader("\n\n\n"))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to parse Confluence configuration")
+	})
+	t.Run("should fail on unexpected root element", func(t *testing.T) {
+		_, err := Parse(strings.NewReader("<configuration></configuration>"))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to parse Confluence configuration")
+	})
+}
+
+func TestConfiguration_Property(t *testing.T) {
+	sut := &Configuration{Properties: []Property{{Name: "a", Value: "1"}, {Name: "b", Value: ""}}}
+
+	t.Run("should find existing property", func(t *testing.T) {
+		actual, found := sut.Property("a")
+
+		assert.True(t, found)
+		assert.Equal(t, "1", actual)
+	})
+	t.Run("should find empty property", func(t *testing.T) {
+		actual, found := sut.Property("b")
+
+		assert.True(t, found)
+		assert.Empty(t, actual)
+	})
+	t.Run("should not find missing property", func(t *testing.T) {
+		_, found := sut.License()
+
+		assert.False(t, found)
+	})
+}
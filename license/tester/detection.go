@@ -0,0 +1,167 @@
+package tester
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/pkg/errors"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DetectionModeExact recognizes a setup license only if it equals the given setup license or matches one of the
+	// setup license fingerprints.
+	DetectionModeExact = "exact"
+	// DetectionModeDecoded additionally recognizes expired licenses and licenses matching a set of license
+	// properties as setup licenses.
+	DetectionModeDecoded = "decoded"
+)
+
+// Detection configures how a setup license is recognized.
+type Detection struct {
+	// Mode is either DetectionModeExact or DetectionModeDecoded. An empty mode is treated as DetectionModeExact.
+	Mode string
+	// SetupProperties are license properties which mark a license as setup license if all of them match exactly,
+	// e.g. {"conf.LicenseTypeName": "EVALUATION", "Organisation": "Cloudogu"}. Only used in DetectionModeDecoded.
+	SetupProperties map[string]string
+	// SetupFingerprints are SHA-256 fingerprints of setup licenses as lowercase hex strings, see
+	// atlassian.Fingerprint. A license whose fingerprint is one of them is a setup license in every mode, so the
+	// setup license itself does not need to be given.
+	SetupFingerprints []string
+}
+
+// Validate checks if the detection is configured correctly.
+func (d Detection) Validate() error {
+	for _, fingerprint := range d.SetupFingerprints {
+		if !isFingerprint(fingerprint) {
+			return errors.Errorf("invalid setup license fingerprint '%s': expected 64 lowercase hex digits", fingerprint)
+		}
+	}
+
+	switch d.Mode {
+	case "", DetectionModeExact:
+		if len(d.SetupProperties) > 0 {
+			return errors.Errorf("setup license properties are only supported in detection mode '%s'", DetectionModeDecoded)
+		}
+		return nil
+	case DetectionModeDecoded:
+		return nil
+	default:
+		return errors.Errorf("unsupported detection mode '%s'", d.Mode)
+	}
+}
+
+// RequiresSetupLicense returns true if a setup license must be given to recognize a setup license.
+func (d Detection) RequiresSetupLicense() bool {
+	return d.Mode != DetectionModeDecoded && len(d.SetupFingerprints) == 0
+}
+
+// IsSetupLicense checks if the given license counts as setup license at the given point in time.
+func (d Detection) IsSetupLicense(license string, setupLicense string, now time.Time) bool {
+	if setupLicense != "" && license == setupLicense {
+		log.Debug("License equals the given setup license")
+		return true
+	}
+
+	if len(d.SetupFingerprints) > 0 && slices.Contains(d.SetupFingerprints, atlassian.Fingerprint(license)) {
+		log.Debug("License matches a setup license fingerprint")
+		return true
+	}
+
+	if d.Mode != DetectionModeDecoded {
+		return false
+	}
+
+	decoded, err := atlassian.Decode(license)
+	if err != nil {
+		log.Debugf("License cannot be decoded and is therefore no setup license: %s", err.Error())
+		return false
+	}
+
+	if decoded.IsExpired(now) {
+		log.Debugf("License expired on %s and counts as setup license", decoded.ExpiryDate.Format(time.RFC3339))
+		return true
+	}
+
+	if len(d.SetupProperties) > 0 && matchesProperties(decoded, d.SetupProperties) {
+		log.Debug("License matches the setup license properties")
+		return true
+	}
+
+	return false
+}
+
+func matchesProperties(license *atlassian.License, properties map[string]string) bool {
+	for key, expected := range properties {
+		actual, ok := license.Properties[key]
+		if !ok || actual != expected {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseSetupProperties parses properties in the form key=value.
+func ParseSetupProperties(keyValues []string) (map[string]string, error) {
+	properties := map[string]string{}
+	for _, keyValue := range keyValues {
+		key, value, found := strings.Cut(keyValue, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, errors.Errorf("invalid setup license property '%s': expected the form key=value", keyValue)
+		}
+		properties[key] = strings.TrimSpace(value)
+	}
+
+	return properties, nil
+}
+
+// ParseSetupFingerprints parses SHA-256 fingerprints of setup licenses. A fingerprint may be prefixed with "sha256:"
+// as printed by the inspect command and is compared case-insensitively.
+func ParseSetupFingerprints(values []string) ([]string, error) {
+	var fingerprints []string
+	for _, value := range values {
+		fingerprint := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(value), "sha256:"))
+		if !isFingerprint(fingerprint) {
+			return nil, errors.Errorf("invalid setup license fingerprint '%s': expected 64 hex digits", value)
+		}
+		if !slices.Contains(fingerprints, fingerprint) {
+			fingerprints = append(fingerprints, fingerprint)
+		}
+	}
+
+	return fingerprints, nil
+}
+
+func isFingerprint(value string) bool {
+	if len(value) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil && strings.ToLower(value) == value
+}
+
+// String returns a printable representation of the detection.
+func (d Detection) String() string {
+	mode := d.Mode
+	if mode == "" {
+		mode = DetectionModeExact
+	}
+
+	keys := make([]string, 0, len(d.SetupProperties))
+	for key := range d.SetupProperties {
+		keys = append(keys, key+"="+d.SetupProperties[key])
+	}
+	sort.Strings(keys)
+
+	if len(d.SetupFingerprints) > 0 {
+		keys = append(keys, fmt.Sprintf("fingerprints=%d", len(d.SetupFingerprints)))
+	}
+
+	return mode + "[" + strings.Join(keys, ",") + "]"
+}
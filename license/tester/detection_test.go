@@ -0,0 +1,161 @@
+package tester
+
+import (
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func TestDetection_Validate(t *testing.T) {
+	assert.NoError(t, Detection{}.Validate())
+	assert.NoError(t, Detection{Mode: DetectionModeExact}.Validate())
+	assert.NoError(t, Detection{Mode: DetectionModeDecoded, SetupProperties: map[string]string{"a": "b"}}.Validate())
+
+	err := Detection{Mode: "fuzzy"}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported detection mode 'fuzzy'")
+
+	err = Detection{Mode: DetectionModeExact, SetupProperties: map[string]string{"a": "b"}}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only supported in detection mode 'decoded'")
+
+	err = Detection{SetupFingerprints: []string{"abc"}}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid setup license fingerprint 'abc'")
+}
+
+func TestDetection_IsSetupLicense(t *testing.T) {
+	expired := encodeTestLicense(t, map[string]string{"LicenseExpiryDate": "2020-07-22"})
+	valid := encodeTestLicense(t, map[string]string{"LicenseExpiryDate": "2027-07-22"})
+	evaluation := encodeTestLicense(t, map[string]string{
+		"LicenseExpiryDate":    "2027-07-22",
+		"conf.LicenseTypeName": "EVALUATION",
+		"Organisation":         "Cloudogu",
+	})
+
+	t.Run("exact mode", func(t *testing.T) {
+		sut := Detection{Mode: DetectionModeExact}
+
+		assert.True(t, sut.IsSetupLicense(getSetupLicense(), getSetupLicense(), testNow))
+		assert.False(t, sut.IsSetupLicense(getProductionLicense(), getSetupLicense(), testNow))
+		assert.False(t, sut.IsSetupLicense(expired, getSetupLicense(), testNow))
+		assert.False(t, sut.IsSetupLicense(expired, "", testNow))
+	})
+	t.Run("decoded mode", func(t *testing.T) {
+		sut := Detection{Mode: DetectionModeDecoded}
+
+		assert.True(t, sut.IsSetupLicense(getSetupLicense(), getSetupLicense(), testNow))
+		assert.True(t, sut.IsSetupLicense(expired, "", testNow))
+		assert.False(t, sut.IsSetupLicense(valid, "", testNow))
+		assert.False(t, sut.IsSetupLicense(evaluation, "", testNow))
+		assert.False(t, sut.IsSetupLicense(getProductionLicense(), "", testNow))
+	})
+	t.Run("decoded mode with setup properties", func(t *testing.T) {
+		sut := Detection{Mode: DetectionModeDecoded, SetupProperties: map[string]string{
+			"conf.LicenseTypeName": "EVALUATION",
+			"Organisation":         "Cloudogu",
+		}}
+
+		assert.True(t, sut.IsSetupLicense(evaluation, "", testNow))
+		assert.True(t, sut.IsSetupLicense(expired, "", testNow))
+		assert.False(t, sut.IsSetupLicense(valid, "", testNow))
+	})
+}
+
+func TestDetection_IsSetupLicense_fingerprints(t *testing.T) {
+	setupFingerprint := atlassian.Fingerprint(getSetupLicense())
+
+	t.Run("should recognize a setup license by its fingerprint in exact mode", func(t *testing.T) {
+		sut := Detection{SetupFingerprints: []string{setupFingerprint}}
+
+		assert.False(t, sut.RequiresSetupLicense())
+		assert.True(t, sut.IsSetupLicense(getSetupLicense(), "", testNow))
+		assert.False(t, sut.IsSetupLicense(getProductionLicense(), "", testNow))
+	})
+	t.Run("should ignore line breaks and whitespace of the configured license", func(t *testing.T) {
+		sut := Detection{SetupFingerprints: []string{setupFingerprint}}
+		license := getSetupLicense()
+		wrapped := license[:40] + "\n  " + license[40:80] + "\r\n" + license[80:]
+
+		assert.True(t, sut.IsSetupLicense(wrapped, "", testNow))
+	})
+	t.Run("should recognize a setup license by its fingerprint in decoded mode", func(t *testing.T) {
+		valid := encodeTestLicense(t, map[string]string{"LicenseExpiryDate": "2027-07-22"})
+		sut := Detection{Mode: DetectionModeDecoded, SetupFingerprints: []string{atlassian.Fingerprint(valid)}}
+
+		assert.True(t, sut.IsSetupLicense(valid, "", testNow))
+		assert.False(t, sut.IsSetupLicense(getProductionLicense(), "", testNow))
+	})
+}
+
+func TestParseSetupFingerprints(t *testing.T) {
+	fingerprint := atlassian.Fingerprint(getSetupLicense())
+
+	t.Run("should normalize fingerprints and remove duplicates", func(t *testing.T) {
+		actual, err := ParseSetupFingerprints([]string{" " + strings.ToUpper(fingerprint), "sha256:" + fingerprint})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{fingerprint}, actual)
+	})
+	t.Run("should return nil without fingerprints", func(t *testing.T) {
+		actual, err := ParseSetupFingerprints(nil)
+
+		require.NoError(t, err)
+		assert.Nil(t, actual)
+	})
+	t.Run("should fail on values which are no SHA-256 fingerprints", func(t *testing.T) {
+		_, err := ParseSetupFingerprints([]string{fingerprint[:63]})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "expected 64 hex digits")
+
+		_, err = ParseSetupFingerprints([]string{fingerprint[:63] + "x"})
+
+		require.Error(t, err)
+	})
+}
+
+func TestParseSetupProperties(t *testing.T) {
+	t.Run("should parse key value pairs", func(t *testing.T) {
+		actual, err := ParseSetupProperties([]string{"conf.LicenseTypeName=EVALUATION", " Organisation = Cloudogu GmbH", "empty="})
+
+		require.NoError(t, err)
+		expected := map[string]string{"conf.LicenseTypeName": "EVALUATION", "Organisation": "Cloudogu GmbH", "empty": ""}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should fail on missing separator", func(t *testing.T) {
+		_, err := ParseSetupProperties([]string{"Organisation"})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid setup license property 'Organisation'")
+	})
+	t.Run("should fail on empty key", func(t *testing.T) {
+		_, err := ParseSetupProperties([]string{"=value"})
+
+		require.Error(t, err)
+	})
+}
+
+func TestDetection_String(t *testing.T) {
+	assert.Equal(t, "exact[]", Detection{}.String())
+	assert.Equal(t, "decoded[a=1,b=2]", Detection{Mode: DetectionModeDecoded, SetupProperties: map[string]string{"b": "2", "a": "1"}}.String())
+	assert.Equal(t, "exact[fingerprints=2]", Detection{SetupFingerprints: []string{"a", "b"}}.String())
+}
+
+func encodeTestLicense(t *testing.T, properties map[string]string) string {
+	t.Helper()
+
+	allProperties := map[string]string{"conf.active": "true", "conf.LicenseTypeName": "COMMERCIAL"}
+	for key, value := range properties {
+		allProperties[key] = value
+	}
+
+	license, err := atlassian.Encode(allProperties)
+	require.NoError(t, err)
+	return license
+}
@@ -0,0 +1,59 @@
+package tester
+
+import (
+	"github.com/pkg/errors"
+)
+
+// State describes which kind of license was found in a configuration file.
+type State string
+
+const (
+	// StateSetupLicense means that the configured license is a setup license.
+	StateSetupLicense State = "setup-license"
+	// StateProductionLicense means that the configured license is no setup license.
+	StateProductionLicense State = "production-license"
+	// StateLicenseMissing means that the configuration file or its license property does not exist.
+	StateLicenseMissing State = "license-missing"
+	// StateConfigMalformed means that the configuration file cannot be parsed.
+	StateConfigMalformed State = "config-malformed"
+)
+
+// Result is the outcome of a setup license check.
+type Result struct {
+	// State is the kind of license that was found.
+	State State
+	// ConfigFile is the checked configuration file.
+	ConfigFile string
+	// Reason describes why the license is missing or the configuration is malformed. It is empty otherwise.
+	Reason string
+}
+
+// stateError marks an error that does not prevent a check but leads to a certain result state.
+type stateError struct {
+	state State
+	cause error
+}
+
+func (e *stateError) Error() string {
+	return e.cause.Error()
+}
+
+func (e *stateError) Unwrap() error {
+	return e.cause
+}
+
+// IsLicenseMissing checks if the error was caused by a missing config file or license property.
+func IsLicenseMissing(err error) bool {
+	state, ok := stateOf(err)
+	return ok && state == StateLicenseMissing
+}
+
+// stateOf returns the result state which is attached to an error.
+func stateOf(err error) (State, bool) {
+	var stateErr *stateError
+	if errors.As(err, &stateErr) {
+		return stateErr.state, true
+	}
+
+	return "", false
+}
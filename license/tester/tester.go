@@ -1,45 +1,78 @@
 package tester
 
 import (
-	"bufio"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/cloudogu/confluence-license-checker/license/config"
 	"github.com/op/go-logging"
 	"github.com/pkg/errors"
 	"io"
 	"os"
-	"strings"
+	"time"
 )
 
 var log = logging.MustGetLogger("tester")
 
 type Tester interface {
 	HasLicenseChanged(configFile string, knownLicense string) (changed bool, err error)
-	HasSetupLicense(configFile string, setupLicense string) (unchanged bool, err error)
+	// CheckSetupLicense checks which kind of license is configured in the given config file. A missing license or a
+	// malformed config file is reported as result state. An error is only returned if the check cannot be done at
+	// all, e.g. because the config file cannot be read.
+	CheckSetupLicense(configFile string, setupLicense string) (*Result, error)
+	// ReadLicense returns the license which is currently configured in the given config file.
+	ReadLicense(configFile string) (license string, err error)
+	// ReadSetupStep returns the setup step of the given config file, which is config.SetupStepComplete once the
+	// Confluence setup is finished.
+	ReadSetupStep(configFile string) (setupStep string, err error)
 }
 
+// New creates a Tester which recognizes a setup license only by comparing it with the given setup license.
 func New() Tester {
+	return NewWithDetection(Detection{Mode: DetectionModeExact})
+}
+
+// NewWithDetection creates a Tester which recognizes a setup license according to the given detection.
+func NewWithDetection(detection Detection) Tester {
 	opener := newFileOpener()
-	return &defaultLicenseTester{opener: opener}
+	return &defaultLicenseTester{opener: opener, detection: detection, now: time.Now}
 }
 
 type defaultLicenseTester struct {
-	opener fileOpener
+	opener    fileOpener
+	detection Detection
+	now       func() time.Time
 }
 
-func (lc *defaultLicenseTester) HasSetupLicense(configFile string, setupLicense string) (changed bool, err error) {
-	licenseChanged, err := lc.HasLicenseChanged(configFile, setupLicense)
-	return !licenseChanged, err
+func (lc *defaultLicenseTester) CheckSetupLicense(configFile string, setupLicense string) (*Result, error) {
+	log.Debugf("Checking configuration file '%s' for a setup license using detection %s", configFile, lc.detection)
+
+	license, err := readLicenseFrom(configFile, lc.opener)
+	if err != nil {
+		state, ok := stateOf(err)
+		if !ok {
+			return nil, errors.Wrap(err, "failed to check license")
+		}
+
+		log.Debugf("Checked license with result '%s': %s", state, err.Error())
+		return &Result{State: state, ConfigFile: configFile, Reason: err.Error()}, nil
+	}
+
+	if lc.detection.IsSetupLicense(license, setupLicense, lc.now()) {
+		return &Result{State: StateSetupLicense, ConfigFile: configFile}, nil
+	}
+
+	return &Result{State: StateProductionLicense, ConfigFile: configFile}, nil
 }
 
 func (lc *defaultLicenseTester) HasLicenseChanged(configFile string, knownLicense string) (changed bool, err error) {
 	log.Debugf("Checking configuration file '%s'", configFile)
-	log.Debugf("Comparing to license '%s'", knownLicense)
+	log.Debugf("Comparing to license '%s'", atlassian.Redact(knownLicense))
 
-	licenseLine, err := readLicenseLineFrom(configFile, lc.opener)
+	license, err := readLicenseFrom(configFile, lc.opener)
 	if err != nil {
 		return false, errors.Wrap(err, "failed to check license")
 	}
 
-	if strings.Contains(licenseLine, knownLicense) {
+	if license == knownLicense {
 		log.Debug("Found old license in license file")
 		return false, nil
 	}
@@ -48,29 +81,61 @@ func (lc *defaultLicenseTester) HasLicenseChanged(configFile string, knownLicens
 	return true, nil
 }
 
-func readLicenseLineFrom(fileToCheck string, opener fileOpener) (string, error) {
-	file, err := opener.Open(fileToCheck)
+func (lc *defaultLicenseTester) ReadLicense(configFile string) (string, error) {
+	log.Debugf("Reading license from configuration file '%s'", configFile)
+
+	license, err := readLicenseFrom(configFile, lc.opener)
 	if err != nil {
-		return "", errors.Wrapf(err, "error while opening config file '%s'", fileToCheck)
+		return "", errors.Wrap(err, "failed to read license")
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	return license, nil
+}
+
+func (lc *defaultLicenseTester) ReadSetupStep(configFile string) (string, error) {
+	log.Debugf("Reading setup step from configuration file '%s'", configFile)
 
-	licenseProperty := "atlassian.license.message"
-	fullLicenseLine := ""
-	for scanner.Scan() {
-		currentLine := scanner.Text()
-		if strings.Contains(currentLine, licenseProperty) {
-			fullLicenseLine = currentLine
+	cfg, err := readConfigFrom(configFile, lc.opener)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read setup step")
+	}
+
+	return cfg.SetupStep, nil
+}
+
+func readLicenseFrom(fileToCheck string, opener fileOpener) (string, error) {
+	cfg, err := readConfigFrom(fileToCheck, opener)
+	if err != nil {
+		return "", err
+	}
+
+	license, found := cfg.License()
+	if !found {
+		err = errors.Errorf("failed to find property '%s' in file '%s'", config.LicenseProperty, fileToCheck)
+		return "", &stateError{state: StateLicenseMissing, cause: err}
+	}
+
+	return license, nil
+}
+
+func readConfigFrom(fileToCheck string, opener fileOpener) (*config.Configuration, error) {
+	file, err := opener.Open(fileToCheck)
+	if err != nil {
+		err = errors.Wrapf(err, "error while opening config file '%s'", fileToCheck)
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil, &stateError{state: StateLicenseMissing, cause: err}
 		}
+		return nil, err
 	}
+	defer file.Close()
 
-	if fullLicenseLine == "" {
-		return "", errors.Errorf("failed to find property '%s' in file '%s'", licenseProperty, fileToCheck)
+	cfg, err := config.Parse(file)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to parse config file '%s'", fileToCheck)
+		return nil, &stateError{state: StateConfigMalformed, cause: err}
 	}
 
-	return fullLicenseLine, nil
+	return cfg, nil
 }
 
 type fileOpener interface {
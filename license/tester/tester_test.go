@@ -2,13 +2,17 @@ package tester
 
 import (
 	"fmt"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/op/go-logging"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_defaultLicenseTester_HasSetupLicense(t *testing.T) {
@@ -69,12 +73,12 @@ func Test_defaultLicenseTester_HasSetupLicense(t *testing.T) {
 
 		// then
 		require.Error(t, actualErr)
-		assert.Contains(t, actualErr.Error(), "failed to find property")
+		assert.Contains(t, actualErr.Error(), "failed to parse config file")
 	})
 }
 
-func Test_defaultLicenseTester_HasSetupLicense1(t *testing.T) {
-	t.Run("should return true", func(t *testing.T) {
+func Test_defaultLicenseTester_CheckSetupLicense(t *testing.T) {
+	t.Run("should return setup license state", func(t *testing.T) {
 		// given: a config file with old license
 		configFile, err := ioutil.TempFile("", "confluence.*.cfg.xml")
 		if err != nil {
@@ -87,13 +91,13 @@ func Test_defaultLicenseTester_HasSetupLicense1(t *testing.T) {
 
 		// when
 		sut := New()
-		actualChanged, err := sut.HasSetupLicense(configFile.Name(), getSetupLicense())
+		actual, err := sut.CheckSetupLicense(configFile.Name(), getSetupLicense())
 
 		// then
 		require.NoError(t, err)
-		assert.True(t, actualChanged)
+		assert.Equal(t, &Result{State: StateSetupLicense, ConfigFile: configFile.Name()}, actual)
 	})
-	t.Run("should return false", func(t *testing.T) {
+	t.Run("should return production license state", func(t *testing.T) {
 		// given: a config file with old license
 		configFile, err := ioutil.TempFile("", "confluence.*.cfg.xml")
 		if err != nil {
@@ -106,13 +110,13 @@ func Test_defaultLicenseTester_HasSetupLicense1(t *testing.T) {
 
 		// when
 		sut := New()
-		actualChanged, err := sut.HasSetupLicense(configFile.Name(), getSetupLicense())
+		actual, err := sut.CheckSetupLicense(configFile.Name(), getSetupLicense())
 
 		// then
 		require.NoError(t, err)
-		assert.False(t, actualChanged)
+		assert.Equal(t, &Result{State: StateProductionLicense, ConfigFile: configFile.Name()}, actual)
 	})
-	t.Run("should return error", func(t *testing.T) {
+	t.Run("should return config malformed state", func(t *testing.T) {
 		// given: a config file with new license
 		configFile, err := ioutil.TempFile("", "confluence.*.cfg.xml")
 		if err != nil {
@@ -126,26 +130,251 @@ func Test_defaultLicenseTester_HasSetupLicense1(t *testing.T) {
 
 		// when
 		sut := New()
-		_, actualErr := sut.HasSetupLicense(configFile.Name(), getSetupLicense())
+		actual, err := sut.CheckSetupLicense(configFile.Name(), getSetupLicense())
 
 		// then
-		require.Error(t, actualErr)
-		assert.Contains(t, actualErr.Error(), "failed to find property")
+		require.NoError(t, err)
+		assert.Equal(t, StateConfigMalformed, actual.State)
+		assert.Contains(t, actual.Reason, "failed to parse config file")
+	})
+	t.Run("should return license missing state on missing property", func(t *testing.T) {
+		configFile, err := ioutil.TempFile("", "confluence.*.cfg.xml")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.Remove(configFile.Name())
+		_, _ = configFile.WriteString("<confluence-configuration><setupStep>setupstart</setupStep></confluence-configuration>")
+		_ = configFile.Sync()
+
+		// when
+		sut := New()
+		actual, err := sut.CheckSetupLicense(configFile.Name(), getSetupLicense())
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, StateLicenseMissing, actual.State)
+		assert.Contains(t, actual.Reason, "failed to find property")
+	})
+	t.Run("should return license missing state on missing file", func(t *testing.T) {
+		sut := New()
+		actual, err := sut.CheckSetupLicense("/does/not/exist.cfg.xml", getSetupLicense())
+
+		require.NoError(t, err)
+		assert.Equal(t, StateLicenseMissing, actual.State)
+		assert.Contains(t, actual.Reason, "error while opening config file")
+	})
+	t.Run("should return error on unreadable file", func(t *testing.T) {
+		mockedOpener := new(fileOpenerMock)
+		mockedOpener.On("Open", "some/file").Return(nil, os.ErrPermission)
+		sut := &defaultLicenseTester{opener: mockedOpener, now: time.Now}
+
+		// when
+		_, err := sut.CheckSetupLicense("some/file", getSetupLicense())
+
+		// then
+		require.Error(t, err)
+		assert.ErrorIs(t, err, os.ErrPermission)
+		assert.Contains(t, err.Error(), "failed to check license")
+		mockedOpener.AssertExpectations(t)
 	})
 }
 
-func Test_readLicenseLineFrom(t *testing.T) {
+func Test_defaultLicenseTester_CheckSetupLicense_decoded(t *testing.T) {
+	t.Run("should recognize expired license without setup license", func(t *testing.T) {
+		configFile, err := ioutil.TempFile("", "confluence.*.cfg.xml")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.Remove(configFile.Name())
+		content := buildConfigFileContent(t, encodeTestLicense(t, map[string]string{"LicenseExpiryDate": "2020-07-22"}))
+		_, _ = configFile.WriteString(content)
+		_ = configFile.Sync()
+
+		// when
+		sut := NewWithDetection(Detection{Mode: DetectionModeDecoded})
+		actual, err := sut.CheckSetupLicense(configFile.Name(), "")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, StateSetupLicense, actual.State)
+	})
+	t.Run("should not recognize valid license without setup license", func(t *testing.T) {
+		configFile, err := ioutil.TempFile("", "confluence.*.cfg.xml")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.Remove(configFile.Name())
+		content := buildConfigFileContent(t, encodeTestLicense(t, map[string]string{"LicenseExpiryDate": "2099-07-22"}))
+		_, _ = configFile.WriteString(content)
+		_ = configFile.Sync()
+
+		// when
+		sut := NewWithDetection(Detection{Mode: DetectionModeDecoded})
+		actual, err := sut.CheckSetupLicense(configFile.Name(), "")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, StateProductionLicense, actual.State)
+	})
+}
+
+func Test_readLicenseFrom(t *testing.T) {
 	t.Run("should return error on opening file", func(t *testing.T) {
 		mockedOpener := new(fileOpenerMock)
 		mockedOpener.On("Open", "some/file").Return(nil, assert.AnError)
 		// when
-		_, err := readLicenseLineFrom("some/file", mockedOpener)
+		_, err := readLicenseFrom("some/file", mockedOpener)
 
 		// then
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "error while opening")
 		mockedOpener.AssertExpectations(t)
 	})
+	t.Run("should return error on missing license property", func(t *testing.T) {
+		content := `<confluence-configuration>
+  <properties>
+    <property name="attachments.dir">${confluenceHome}/attachments</property>
+  </properties>
+</confluence-configuration>`
+		mockedOpener := new(fileOpenerMock)
+		mockedOpener.On("Open", "some/file").Return(io.NopCloser(strings.NewReader(content)), nil)
+
+		// when
+		_, err := readLicenseFrom("some/file", mockedOpener)
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to find property 'atlassian.license.message'")
+		mockedOpener.AssertExpectations(t)
+	})
+	t.Run("should return unescaped license spanning several lines", func(t *testing.T) {
+		content := `<confluence-configuration>
+  <properties>
+    <!-- atlassian.license.message is set during the setup -->
+    <property name="atlassian.license.message">AAAB&#10;line2
+line3&amp;X02</property>
+  </properties>
+</confluence-configuration>`
+		mockedOpener := new(fileOpenerMock)
+		mockedOpener.On("Open", "some/file").Return(io.NopCloser(strings.NewReader(content)), nil)
+
+		// when
+		actual, err := readLicenseFrom("some/file", mockedOpener)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "AAAB\nline2\nline3&X02", actual)
+		mockedOpener.AssertExpectations(t)
+	})
+}
+
+func Test_defaultLicenseTester_HasLicenseChanged(t *testing.T) {
+	t.Run("should detect a license that only contains the known license", func(t *testing.T) {
+		configFile, err := ioutil.TempFile("", "confluence.*.cfg.xml")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.Remove(configFile.Name())
+		content := buildConfigFileContent(t, getSetupLicense()+"EXTRA")
+		_, _ = configFile.WriteString(content)
+		_ = configFile.Sync()
+
+		// when
+		sut := New()
+		actualChanged, err := sut.HasLicenseChanged(configFile.Name(), getSetupLicense())
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, actualChanged)
+	})
+	t.Run("should not log the known license", func(t *testing.T) {
+		// given
+		configFile, err := ioutil.TempFile("", "confluence.*.cfg.xml")
+		require.NoError(t, err)
+		defer os.Remove(configFile.Name())
+		_, _ = configFile.WriteString(buildConfigFileContent(t, getProductionLicense()))
+		_ = configFile.Sync()
+		logs := logging.NewMemoryBackend(10)
+		logging.SetBackend(logs)
+		logging.SetLevel(logging.DEBUG, "")
+		defer logging.SetBackend(logging.NewLogBackend(os.Stderr, "", 0))
+
+		// when
+		_, err = New().HasLicenseChanged(configFile.Name(), getSetupLicense())
+
+		// then
+		require.NoError(t, err)
+		var messages []string
+		for node := logs.Head(); node != nil; node = node.Next() {
+			messages = append(messages, node.Record.Message())
+		}
+		assert.Contains(t, messages, fmt.Sprintf("Comparing to license '%s'", atlassian.Redact(getSetupLicense())))
+		assert.NotContains(t, strings.Join(messages, "\n"), atlassian.Normalize(getSetupLicense()))
+	})
+}
+
+func Test_defaultLicenseTester_ReadLicense(t *testing.T) {
+	t.Run("should return configured license", func(t *testing.T) {
+		configFile, err := ioutil.TempFile("", "confluence.*.cfg.xml")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.Remove(configFile.Name())
+		content := buildConfigFileContent(t, getProductionLicense())
+		_, _ = configFile.WriteString(content)
+		_ = configFile.Sync()
+
+		// when
+		sut := New()
+		actual, err := sut.ReadLicense(configFile.Name())
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, getProductionLicense(), actual)
+	})
+	t.Run("should return error on missing file", func(t *testing.T) {
+		sut := New()
+		_, err := sut.ReadLicense("/does/not/exist.cfg.xml")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read license")
+		assert.True(t, IsLicenseMissing(err))
+	})
+}
+
+func Test_defaultLicenseTester_ReadSetupStep(t *testing.T) {
+	t.Run("should return setup step", func(t *testing.T) {
+		configFile, err := ioutil.TempFile("", "confluence.*.cfg.xml")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.Remove(configFile.Name())
+		content := buildConfigFileContent(t, getProductionLicense())
+		_, _ = configFile.WriteString(content)
+		_ = configFile.Sync()
+
+		// when
+		sut := New()
+		actual, err := sut.ReadSetupStep(configFile.Name())
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "complete", actual)
+	})
+	t.Run("should return error on malformed config file", func(t *testing.T) {
+		mockedOpener := new(fileOpenerMock)
+		mockedOpener.On("Open", "confluence.cfg.xml").Return(io.NopCloser(strings.NewReader("<confluence-config")), nil)
+		sut := &defaultLicenseTester{opener: mockedOpener}
+
+		// when
+		_, err := sut.ReadSetupStep("confluence.cfg.xml")
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read setup step")
+		state, _ := stateOf(err)
+		assert.Equal(t, StateConfigMalformed, state)
+	})
 }
 
 // test util stuff
@@ -0,0 +1,90 @@
+package watcher
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// Action is executed when the watcher emits an event to which the action subscribed.
+type Action interface {
+	// Name returns a short name of the action for log messages.
+	Name() string
+	// Execute handles the event. The context is done when the grace period after stopping the watcher expired.
+	Execute(ctx context.Context, event *Event) error
+}
+
+// Closer is implemented by actions which hold resources, e.g. queued notifications. Close is called when the watcher
+// stops. The context is done when the grace period expired.
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// Subscription subscribes an action to certain event types.
+type Subscription struct {
+	// Action is executed on subscribed events.
+	Action Action
+	// Events are the event types to which the action subscribed. An empty list subscribes to all event types.
+	Events []EventType
+}
+
+func (s Subscription) matches(eventType EventType) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+
+	for _, subscribed := range s.Events {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch executes all actions which subscribed to the event type in the order of the subscriptions. It returns
+// whether any action subscribed to the event.
+func dispatch(ctx context.Context, subscriptions []Subscription, event *Event) (handled bool, err error) {
+	var failures []string
+	for _, subscription := range subscriptions {
+		if !subscription.matches(event.Type) {
+			continue
+		}
+
+		handled = true
+		log.Debugf("Executing action '%s' on event '%s'", subscription.Action.Name(), event.Type)
+		actionErr := subscription.Action.Execute(ctx, event)
+		if actionErr != nil {
+			failures = append(failures, actionErr.Error())
+			err = errors.Wrapf(actionErr, "action '%s' failed on event '%s'", subscription.Action.Name(), event.Type)
+		}
+	}
+
+	if len(failures) > 1 {
+		err = errors.Errorf("%d actions failed on event '%s': %s", len(failures), event.Type, strings.Join(failures, "; "))
+	}
+
+	return handled, err
+}
+
+// commandAction executes the shell command of the watcher.
+type commandAction struct {
+	cmdExecutor executor
+	commandArgs []string
+}
+
+func (ca *commandAction) Name() string {
+	return "command"
+}
+
+// Execute runs the command with the details of the event as environment variables and expanded templates in the
+// command arguments.
+func (ca *commandAction) Execute(ctx context.Context, event *Event) error {
+	data := newCommandData(event)
+	commandArgs, err := expandCommandArgs(ca.commandArgs, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = ca.cmdExecutor.execute(ctx, commandArgs, data.environment())
+	return err
+}
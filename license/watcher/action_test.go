@@ -0,0 +1,149 @@
+package watcher
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_dispatch(t *testing.T) {
+	event := &Event{Type: EventProductionToSetup}
+
+	t.Run("should execute subscribed actions only", func(t *testing.T) {
+		subscribed := newActionMock("subscribed")
+		subscribed.On("Execute", event).Return(nil)
+		subscribedToAll := newActionMock("all")
+		subscribedToAll.On("Execute", event).Return(nil)
+		notSubscribed := newActionMock("not-subscribed")
+		subscriptions := []Subscription{
+			{Action: subscribed, Events: []EventType{EventSetupToProduction, EventProductionToSetup}},
+			{Action: subscribedToAll},
+			{Action: notSubscribed, Events: []EventType{EventSetupToProduction}},
+		}
+
+		// when
+		handled, err := dispatch(context.Background(), subscriptions, event)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, handled)
+		subscribed.AssertExpectations(t)
+		subscribedToAll.AssertExpectations(t)
+		notSubscribed.AssertExpectations(t)
+	})
+	t.Run("should not handle event without subscription", func(t *testing.T) {
+		notSubscribed := newActionMock("not-subscribed")
+		subscriptions := []Subscription{{Action: notSubscribed, Events: []EventType{EventSetupToProduction}}}
+
+		// when
+		handled, err := dispatch(context.Background(), subscriptions, event)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, handled)
+	})
+	t.Run("should execute all actions even if one fails", func(t *testing.T) {
+		failing := newActionMock("failing")
+		failing.On("Execute", event).Return(assert.AnError)
+		succeeding := newActionMock("succeeding")
+		succeeding.On("Execute", event).Return(nil)
+
+		// when
+		handled, err := dispatch(context.Background(), []Subscription{{Action: failing}, {Action: succeeding}}, event)
+
+		// then
+		assert.True(t, handled)
+		require.ErrorIs(t, err, assert.AnError)
+		assert.Contains(t, err.Error(), "action 'failing' failed on event 'production-to-setup'")
+		succeeding.AssertExpectations(t)
+	})
+	t.Run("should report several failures", func(t *testing.T) {
+		first := newActionMock("first")
+		first.On("Execute", event).Return(assert.AnError)
+		second := newActionMock("second")
+		second.On("Execute", event).Return(assert.AnError)
+
+		// when
+		_, err := dispatch(context.Background(), []Subscription{{Action: first}, {Action: second}}, event)
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "2 actions failed on event 'production-to-setup'")
+	})
+}
+
+func Test_commandAction_Execute(t *testing.T) {
+	commandArgs := []string{"/bin/true"}
+	mockedExecutor := new(executorMock)
+	mockedExecutor.On("execute", commandArgs).Return("", assert.AnError)
+	sut := &commandAction{cmdExecutor: mockedExecutor, commandArgs: commandArgs}
+
+	err := sut.Execute(context.Background(), &Event{})
+
+	require.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, "command", sut.Name())
+	mockedExecutor.AssertExpectations(t)
+}
+
+func Test_commandAction_Execute_withEventDetails(t *testing.T) {
+	t.Run("should pass event details as environment and expand templates", func(t *testing.T) {
+		// given
+		recorder := &recordingExecutor{}
+		sut := &commandAction{cmdExecutor: recorder, commandArgs: []string{"/bin/notify", "--event={{.Event}}", "{{.ConfigFile}}"}}
+		event := &Event{Type: EventSetupToProduction, ConfigFile: "confluence.cfg.xml"}
+
+		// when
+		err := sut.Execute(context.Background(), event)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/bin/notify", "--event=setup-to-production", "confluence.cfg.xml"}, recorder.commandArgs)
+		assert.Contains(t, recorder.env, "LICENSE_EVENT=setup-to-production")
+		assert.Contains(t, recorder.env, "CONFIG_FILE=confluence.cfg.xml")
+	})
+	t.Run("should not execute command with invalid template", func(t *testing.T) {
+		// given
+		recorder := &recordingExecutor{}
+		sut := &commandAction{cmdExecutor: recorder, commandArgs: []string{"/bin/notify", "{{.Unknown}}"}}
+
+		// when
+		err := sut.Execute(context.Background(), &Event{Type: EventSetupToProduction})
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to expand template in command argument 1")
+		assert.Nil(t, recorder.commandArgs)
+	})
+}
+
+// test util stuff
+type recordingExecutor struct {
+	commandArgs []string
+	env         []string
+}
+
+func (r *recordingExecutor) execute(_ context.Context, shellCommandArgs []string, env []string) (string, error) {
+	r.commandArgs = shellCommandArgs
+	r.env = env
+	return "", nil
+}
+
+type actionMock struct {
+	mock.Mock
+	name string
+}
+
+func newActionMock(name string) *actionMock {
+	return &actionMock{name: name}
+}
+
+func (a *actionMock) Name() string {
+	return a.name
+}
+
+func (a *actionMock) Execute(_ context.Context, event *Event) error {
+	args := a.Called(event)
+	return args.Error(0)
+}
@@ -0,0 +1,137 @@
+package watcher
+
+import (
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/pkg/errors"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Environment variables which pass the details of a license change to the command.
+const (
+	EnvLicenseEvent     = "LICENSE_EVENT"
+	EnvLicenseEventTime = "LICENSE_EVENT_TIME"
+	EnvConfigFile       = "CONFIG_FILE"
+	EnvLicenseOldSHA256 = "LICENSE_OLD_SHA256"
+	EnvLicenseNewSHA256 = "LICENSE_NEW_SHA256"
+	EnvLicenseOldExpiry = "LICENSE_OLD_EXPIRY"
+	EnvLicenseNewExpiry = "LICENSE_NEW_EXPIRY"
+	EnvLicenseOldSEN    = "LICENSE_OLD_SEN"
+	EnvLicenseNewSEN    = "LICENSE_NEW_SEN"
+	EnvWatchError       = "LICENSE_WATCH_ERROR"
+)
+
+// expiryLayout formats expiry dates in the same way as they are stored in a license.
+const expiryLayout = "2006-01-02"
+
+// CommandData contains the details of a license change which are passed to the command. Each field is available as
+// environment variable and as template field in the command arguments, e.g. {{.NewSEN}}. Fields which are not
+// known, e.g. the expiry of a license which cannot be decoded, are empty.
+type CommandData struct {
+	// Event is the type of the license change.
+	Event string
+	// Time is the point in time when the change was detected, formatted as RFC3339.
+	Time string
+	// ConfigFile is the config file in which the change was detected.
+	ConfigFile string
+	// OldSHA256 is the fingerprint of the license before the change.
+	OldSHA256 string
+	// NewSHA256 is the fingerprint of the license after the change.
+	NewSHA256 string
+	// OldExpiry is the expiry date of the license before the change, e.g. 2027-03-31.
+	OldExpiry string
+	// NewExpiry is the expiry date of the license after the change, e.g. 2027-03-31.
+	NewExpiry string
+	// OldSEN is the support entitlement number of the license before the change.
+	OldSEN string
+	// NewSEN is the support entitlement number of the license after the change.
+	NewSEN string
+	// Error describes why the watcher gave up. It is only set on the event watch-failed.
+	Error string
+}
+
+func newCommandData(event *Event) CommandData {
+	return CommandData{
+		Event:      string(event.Type),
+		Time:       formatEventTime(event.Time),
+		ConfigFile: event.ConfigFile,
+		OldSHA256:  atlassian.Fingerprint(event.OldLicense),
+		NewSHA256:  atlassian.Fingerprint(event.NewLicense),
+		OldExpiry:  expiryOf(event.Old),
+		NewExpiry:  expiryOf(event.New),
+		OldSEN:     senOf(event.Old),
+		NewSEN:     senOf(event.New),
+		Error:      event.Error,
+	}
+}
+
+func formatEventTime(eventTime time.Time) string {
+	if eventTime.IsZero() {
+		return ""
+	}
+	return eventTime.Format(time.RFC3339)
+}
+
+func expiryOf(license *atlassian.License) string {
+	if license == nil || !license.HasExpiry() {
+		return ""
+	}
+	return license.ExpiryDate.Format(expiryLayout)
+}
+
+func senOf(license *atlassian.License) string {
+	if license == nil {
+		return ""
+	}
+	return license.SEN
+}
+
+// environment returns the data as environment variables in the form key=value.
+func (cd CommandData) environment() []string {
+	return []string{
+		EnvLicenseEvent + "=" + cd.Event,
+		EnvLicenseEventTime + "=" + cd.Time,
+		EnvConfigFile + "=" + cd.ConfigFile,
+		EnvLicenseOldSHA256 + "=" + cd.OldSHA256,
+		EnvLicenseNewSHA256 + "=" + cd.NewSHA256,
+		EnvLicenseOldExpiry + "=" + cd.OldExpiry,
+		EnvLicenseNewExpiry + "=" + cd.NewExpiry,
+		EnvLicenseOldSEN + "=" + cd.OldSEN,
+		EnvLicenseNewSEN + "=" + cd.NewSEN,
+		EnvWatchError + "=" + cd.Error,
+	}
+}
+
+// ValidateCommandArgs checks that all templates in the command arguments can be expanded. This lets the watcher fail
+// at start instead of on the first license change.
+func ValidateCommandArgs(commandArgs []string) error {
+	_, err := expandCommandArgs(commandArgs, CommandData{})
+	return err
+}
+
+// expandCommandArgs expands the templates in the command arguments with the given data. Arguments without templates
+// are returned unchanged.
+func expandCommandArgs(commandArgs []string, data CommandData) ([]string, error) {
+	expanded := make([]string, 0, len(commandArgs))
+	for i, arg := range commandArgs {
+		if !strings.Contains(arg, "{{") {
+			expanded = append(expanded, arg)
+			continue
+		}
+
+		tmpl, err := template.New("argument").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse template in command argument %d", i)
+		}
+
+		var builder strings.Builder
+		err = tmpl.Execute(&builder, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to expand template in command argument %d", i)
+		}
+		expanded = append(expanded, builder.String())
+	}
+
+	return expanded, nil
+}
@@ -0,0 +1,97 @@
+package watcher
+
+import (
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_newCommandData(t *testing.T) {
+	t.Run("should contain details of both licenses", func(t *testing.T) {
+		// given
+		oldLicense, err := atlassian.Encode(map[string]string{"SEN": "SEN-OLD", "LicenseExpiryDate": "2026-01-31"})
+		require.NoError(t, err)
+		newLicense, err := atlassian.Encode(map[string]string{"SEN": "SEN-NEW", "LicenseExpiryDate": "2027-03-31"})
+		require.NoError(t, err)
+		event := &Event{
+			Type:       EventProductionToProduction,
+			Time:       time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC),
+			ConfigFile: "confluence.cfg.xml",
+			OldLicense: oldLicense,
+			NewLicense: newLicense,
+			Old:        decodeOrNil(oldLicense),
+			New:        decodeOrNil(newLicense),
+		}
+
+		// when
+		actual := newCommandData(event)
+
+		// then
+		expected := CommandData{
+			Event:      "production-to-production",
+			Time:       "2026-10-18T12:30:00Z",
+			ConfigFile: "confluence.cfg.xml",
+			OldSHA256:  atlassian.Fingerprint(oldLicense),
+			NewSHA256:  atlassian.Fingerprint(newLicense),
+			OldExpiry:  "2026-01-31",
+			NewExpiry:  "2027-03-31",
+			OldSEN:     "SEN-OLD",
+			NewSEN:     "SEN-NEW",
+		}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should leave details of missing and undecodable licenses empty", func(t *testing.T) {
+		event := &Event{Type: EventLicenseReappeared, NewLicense: "not a license"}
+
+		actual := newCommandData(event)
+
+		assert.Equal(t, CommandData{Event: "license-reappeared", NewSHA256: atlassian.Fingerprint("not a license")}, actual)
+	})
+}
+
+func TestCommandData_environment(t *testing.T) {
+	data := CommandData{Event: "setup-to-production", ConfigFile: "confluence.cfg.xml", NewSHA256: "abc", NewSEN: "SEN-L1"}
+
+	actual := data.environment()
+
+	assert.Contains(t, actual, "LICENSE_EVENT=setup-to-production")
+	assert.Contains(t, actual, "CONFIG_FILE=confluence.cfg.xml")
+	assert.Contains(t, actual, "LICENSE_NEW_SHA256=abc")
+	assert.Contains(t, actual, "LICENSE_NEW_SEN=SEN-L1")
+	assert.Contains(t, actual, "LICENSE_OLD_SHA256=")
+}
+
+func Test_expandCommandArgs(t *testing.T) {
+	t.Run("should expand templates", func(t *testing.T) {
+		data := CommandData{Event: "setup-to-production", NewExpiry: "2027-03-31"}
+
+		actual, err := expandCommandArgs([]string{"/bin/echo", "{{.Event}} until {{.NewExpiry}}", "plain"}, data)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/bin/echo", "setup-to-production until 2027-03-31", "plain"}, actual)
+	})
+	t.Run("should keep escaped braces", func(t *testing.T) {
+		data := CommandData{Event: "license-removed"}
+
+		actual, err := expandCommandArgs([]string{"/bin/echo", `{{"{{"}}.Event}} is {{.Event}}`}, data)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/bin/echo", "{{.Event}} is license-removed"}, actual)
+	})
+	t.Run("should fail on unknown field", func(t *testing.T) {
+		_, err := expandCommandArgs([]string{"/bin/echo", "{{.License}}"}, CommandData{})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to expand template in command argument 1")
+	})
+}
+
+func TestValidateCommandArgs(t *testing.T) {
+	assert.NoError(t, ValidateCommandArgs([]string{"/bin/echo", "{{.NewSEN}}"}))
+
+	err := ValidateCommandArgs([]string{"/bin/echo", "{{.NewSEN"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse template in command argument 1")
+}
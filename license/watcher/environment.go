@@ -0,0 +1,90 @@
+package watcher
+
+import (
+	"github.com/pkg/errors"
+	"path"
+	"strings"
+)
+
+// redactedValue replaces the values of environment variables in log messages.
+const redactedValue = "<redacted>"
+
+// DefaultDeniedEnvironment contains the patterns of environment variables which are never passed to the command
+// unless PassSecrets is set. These are secrets of the watcher which the command does not need.
+var DefaultDeniedEnvironment = []string{"SETUP_LICENSE", "WEBHOOK_SECRET", "WEBHOOK_HEADERS", "MAIL_USERNAME", "MAIL_PASSWORD"}
+
+// EnvironmentPolicy selects the environment variables of the watcher which are passed to the command. The details of
+// a license change are always passed. Patterns are matched against variable names with the syntax of path.Match,
+// e.g. CONFLUENCE_*.
+type EnvironmentPolicy struct {
+	// Clear starts the command with an empty environment instead of the environment of the watcher.
+	Clear bool
+	// Allow passes only variables matching one of these patterns. All variables are allowed if empty.
+	Allow []string
+	// Deny removes variables matching one of these patterns, even if they are allowed. The patterns are added to
+	// DefaultDeniedEnvironment.
+	Deny []string
+	// PassSecrets passes the variables of DefaultDeniedEnvironment unless they are denied explicitly.
+	PassSecrets bool
+}
+
+// Validate checks that all patterns are well-formed.
+func (ep EnvironmentPolicy) Validate() error {
+	for _, pattern := range append(append([]string{}, ep.Allow...), ep.Deny...) {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return errors.Wrapf(err, "invalid environment variable pattern '%s'", pattern)
+		}
+	}
+	return nil
+}
+
+// apply returns the variables of the given environment which are passed according to the policy.
+func (ep EnvironmentPolicy) apply(environ []string) []string {
+	if ep.Clear {
+		return []string{}
+	}
+
+	passed := []string{}
+	for _, variable := range environ {
+		name, _, _ := strings.Cut(variable, "=")
+		if ep.isAllowed(name) {
+			passed = append(passed, variable)
+		}
+	}
+	return passed
+}
+
+func (ep EnvironmentPolicy) isAllowed(name string) bool {
+	if len(ep.Allow) > 0 && !matchesAny(ep.Allow, name) {
+		return false
+	}
+	return !matchesAny(ep.denied(), name)
+}
+
+func (ep EnvironmentPolicy) denied() []string {
+	if ep.PassSecrets {
+		return ep.Deny
+	}
+	return append(append([]string{}, DefaultDeniedEnvironment...), ep.Deny...)
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, name)
+		if err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// redactEnvironment replaces the values of the given environment variables so that they can be logged.
+func redactEnvironment(environ []string) []string {
+	redacted := make([]string, 0, len(environ))
+	for _, variable := range environ {
+		name, _, _ := strings.Cut(variable, "=")
+		redacted = append(redacted, name+"="+redactedValue)
+	}
+	return redacted
+}
@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEnvironmentPolicy_apply(t *testing.T) {
+	environ := []string{"PATH=/bin", "SETUP_LICENSE=AAAB", "CONFLUENCE_HOME=/var/atlassian", "DB_PASSWORD=secret"}
+
+	t.Run("should deny the setup license by default", func(t *testing.T) {
+		actual := EnvironmentPolicy{}.apply(environ)
+
+		assert.Equal(t, []string{"PATH=/bin", "CONFLUENCE_HOME=/var/atlassian", "DB_PASSWORD=secret"}, actual)
+	})
+	t.Run("should deny the credentials of the webhook and mail actions by default", func(t *testing.T) {
+		environ := []string{"PATH=/bin", "WEBHOOK_URL=https://example.com", "WEBHOOK_SECRET=hmac",
+			"WEBHOOK_HEADERS=Authorization: Bearer token", "MAIL_USERNAME=checker", "MAIL_PASSWORD=secret"}
+
+		actual := EnvironmentPolicy{}.apply(environ)
+
+		assert.Equal(t, []string{"PATH=/bin", "WEBHOOK_URL=https://example.com"}, actual)
+	})
+	t.Run("should deny further variables in addition to the secrets", func(t *testing.T) {
+		actual := EnvironmentPolicy{Deny: []string{"*PASSWORD*"}}.apply(environ)
+
+		assert.Equal(t, []string{"PATH=/bin", "CONFLUENCE_HOME=/var/atlassian"}, actual)
+	})
+	t.Run("should pass the secrets only on explicit opt-out", func(t *testing.T) {
+		actual := EnvironmentPolicy{Deny: []string{"*PASSWORD*"}, PassSecrets: true}.apply(environ)
+
+		assert.Equal(t, []string{"PATH=/bin", "SETUP_LICENSE=AAAB", "CONFLUENCE_HOME=/var/atlassian"}, actual)
+	})
+	t.Run("should pass only allowed variables", func(t *testing.T) {
+		actual := EnvironmentPolicy{Allow: []string{"PATH", "CONFLUENCE_*", "SETUP_*"}}.apply(environ)
+
+		assert.Equal(t, []string{"PATH=/bin", "CONFLUENCE_HOME=/var/atlassian"}, actual)
+	})
+	t.Run("should start from an empty environment", func(t *testing.T) {
+		actual := EnvironmentPolicy{Clear: true}.apply(environ)
+
+		assert.Empty(t, actual)
+	})
+}
+
+func TestEnvironmentPolicy_Validate(t *testing.T) {
+	assert.NoError(t, EnvironmentPolicy{Allow: []string{"CONFLUENCE_*"}, Deny: []string{"*_PASSWORD"}}.Validate())
+
+	err := EnvironmentPolicy{Deny: []string{"SECRET_["}}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid environment variable pattern 'SECRET_['")
+}
+
+func Test_redactEnvironment(t *testing.T) {
+	actual := redactEnvironment([]string{"PATH=/bin", "DB_PASSWORD=secret=value", "EMPTY"})
+
+	assert.Equal(t, []string{"PATH=<redacted>", "DB_PASSWORD=<redacted>", "EMPTY=<redacted>"}, actual)
+}
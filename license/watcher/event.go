@@ -0,0 +1,138 @@
+package watcher
+
+import (
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+// EventType classifies a license transition.
+type EventType string
+
+const (
+	// EventSetupToProduction is emitted when a setup license is replaced by a production license.
+	EventSetupToProduction EventType = "setup-to-production"
+	// EventProductionToProduction is emitted when a production license is renewed or replaced by another one.
+	EventProductionToProduction EventType = "production-to-production"
+	// EventProductionToSetup is emitted when a production license is reverted to a setup license.
+	EventProductionToSetup EventType = "production-to-setup"
+	// EventSetupToSetup is emitted when a setup license is replaced by another setup license.
+	EventSetupToSetup EventType = "setup-to-setup"
+	// EventLicenseRemoved is emitted when the license disappears from the config file. A missing config file counts
+	// as failed license check instead.
+	EventLicenseRemoved EventType = "license-removed"
+	// EventLicenseReappeared is emitted when a license is configured and no license was known before, e.g. because
+	// the watcher started without a license. A license which is configured again after a removal is compared with the
+	// license before the removal instead.
+	EventLicenseReappeared EventType = "license-reappeared"
+	// EventLicenseExpiring is emitted when the configured license expires within one of the expiry warning days. The
+	// license does not change, so the old and the new license of the event are the same.
+	EventLicenseExpiring EventType = "license-expiring"
+	// EventWatchFailed is emitted when the watcher gives up because the license check failed too often. The old and
+	// the new license of the event are the last known license.
+	EventWatchFailed EventType = "watch-failed"
+)
+
+// AllEventTypes contains all event types which the watcher emits.
+var AllEventTypes = []EventType{
+	EventSetupToProduction,
+	EventProductionToProduction,
+	EventProductionToSetup,
+	EventSetupToSetup,
+	EventLicenseRemoved,
+	EventLicenseReappeared,
+	EventLicenseExpiring,
+	EventWatchFailed,
+}
+
+// DefaultCommandEventTypes are the event types which trigger the command if no event types are given. A removed
+// license does not trigger the command because Confluence may rewrite its config file at any time. An expiring
+// license or a failed watcher is no reason to restart Confluence.
+var DefaultCommandEventTypes = []EventType{
+	EventSetupToProduction,
+	EventProductionToProduction,
+	EventProductionToSetup,
+	EventSetupToSetup,
+	EventLicenseReappeared,
+}
+
+// Event describes a license transition detected by the watcher.
+type Event struct {
+	// Type classifies the transition.
+	Type EventType
+	// Time is the point in time when the transition was detected.
+	Time time.Time
+	// ConfigFile is the config file in which the transition was detected.
+	ConfigFile string
+	// OldLicense is the license before the transition. It is empty if the license was missing.
+	OldLicense string
+	// NewLicense is the license after the transition. It is empty if the license was removed.
+	NewLicense string
+	// Old contains the decoded license before the transition. It is nil if the license is missing or cannot be decoded.
+	Old *atlassian.License
+	// New contains the decoded license after the transition. It is nil if the license is missing or cannot be decoded.
+	New *atlassian.License
+	// Error describes why the watcher failed. It is only set for EventWatchFailed.
+	Error string
+}
+
+// ParseEventTypes parses a list of event type names.
+func ParseEventTypes(names []string) ([]EventType, error) {
+	var eventTypes []EventType
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		eventType := EventType(name)
+		if !isKnownEventType(eventType) {
+			return nil, errors.Errorf("unknown event type '%s'", name)
+		}
+		eventTypes = append(eventTypes, eventType)
+	}
+
+	return eventTypes, nil
+}
+
+func isKnownEventType(eventType EventType) bool {
+	for _, known := range AllEventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyTransition determines the event type of a transition between two licenses. An empty license is missing.
+func classifyTransition(oldLicense string, oldIsSetup bool, newLicense string, newIsSetup bool) EventType {
+	switch {
+	case newLicense == "":
+		return EventLicenseRemoved
+	case oldLicense == "":
+		return EventLicenseReappeared
+	case oldIsSetup && newIsSetup:
+		return EventSetupToSetup
+	case oldIsSetup:
+		return EventSetupToProduction
+	case newIsSetup:
+		return EventProductionToSetup
+	default:
+		return EventProductionToProduction
+	}
+}
+
+func decodeOrNil(license string) *atlassian.License {
+	if license == "" {
+		return nil
+	}
+
+	decoded, err := atlassian.Decode(license)
+	if err != nil {
+		log.Debugf("License cannot be decoded: %s", err.Error())
+		return nil
+	}
+
+	return decoded
+}
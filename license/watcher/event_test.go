@@ -0,0 +1,63 @@
+package watcher
+
+import (
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_classifyTransition(t *testing.T) {
+	tests := []struct {
+		name       string
+		oldLicense string
+		oldIsSetup bool
+		newLicense string
+		newIsSetup bool
+		expected   EventType
+	}{
+		{"activation", "setup", true, "production", false, EventSetupToProduction},
+		{"renewal", "production", false, "renewed", false, EventProductionToProduction},
+		{"revert", "production", false, "setup", true, EventProductionToSetup},
+		{"other setup license", "setup", true, "setup2", true, EventSetupToSetup},
+		{"removal", "production", false, "", false, EventLicenseRemoved},
+		{"reappearance", "", false, "production", false, EventLicenseReappeared},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := classifyTransition(tt.oldLicense, tt.oldIsSetup, tt.newLicense, tt.newIsSetup)
+
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestParseEventTypes(t *testing.T) {
+	t.Run("should parse event types", func(t *testing.T) {
+		actual, err := ParseEventTypes([]string{"setup-to-production", " license-removed ", ""})
+
+		require.NoError(t, err)
+		assert.Equal(t, []EventType{EventSetupToProduction, EventLicenseRemoved}, actual)
+	})
+	t.Run("should return nil for no event types", func(t *testing.T) {
+		actual, err := ParseEventTypes(nil)
+
+		require.NoError(t, err)
+		assert.Nil(t, actual)
+	})
+	t.Run("should fail on unknown event type", func(t *testing.T) {
+		_, err := ParseEventTypes([]string{"license-expired"})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown event type 'license-expired'")
+	})
+}
+
+func Test_decodeOrNil(t *testing.T) {
+	license, err := atlassian.Encode(map[string]string{"SEN": "SEN-L1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "SEN-L1", decodeOrNil(license).SEN)
+	assert.Nil(t, decodeOrNil(""))
+	assert.Nil(t, decodeOrNil("AAAB/testLicense+=okBf"))
+}
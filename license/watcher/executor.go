@@ -1,41 +1,123 @@
 package watcher
 
 import (
-	"bytes"
+	"context"
+	"fmt"
 	"github.com/pkg/errors"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
 )
 
+// killDelay is the time a command may take to exit after it was asked to terminate. It is killed afterwards.
+const killDelay = 5 * time.Second
+
+// exitCodeNotStarted is reported for commands which could not be started or did not exit regularly.
+const exitCodeNotStarted = -1
+
 type executor interface {
-	execute(shellCommandArgs []string) (string, error)
+	// execute runs the given command with the given environment variables in addition to the environment of the
+	// watcher which the environment policy passes. The command is terminated when the context is done.
+	execute(ctx context.Context, shellCommandArgs []string, env []string) (string, error)
+}
+
+func newExecutor(policy RetryPolicy, output OutputOptions, environment EnvironmentPolicy) executor {
+	return &defaultExecutor{policy: policy, output: output, environment: environment}
 }
 
-func newExecutor() executor {
-	return &defaultExecutor{}
+// defaultExecutor executes a command and retries it according to its policy. The output of the command is streamed
+// into the log.
+type defaultExecutor struct {
+	policy      RetryPolicy
+	output      OutputOptions
+	environment EnvironmentPolicy
 }
 
-type defaultExecutor struct{}
+func (de *defaultExecutor) execute(ctx context.Context, shellCommandArgs []string, env []string) (string, error) {
+	attempts := de.policy.Retries + 1
+	env = append(de.environment.apply(os.Environ()), env...)
+	log.Infof("Environment of command %v: %v", shellCommandArgs, redactEnvironment(env))
+
+	var output string
+	attempt := 0
+	err := retry(ctx, de.policy, fmt.Sprintf("command %v", shellCommandArgs), func(attemptCtx context.Context) error {
+		attempt++
+		var attemptErr error
+		output, attemptErr = de.executeAttempt(attemptCtx, shellCommandArgs, env, attempt, attempts)
+		return attemptErr
+	})
+	return output, err
+}
 
-func (de *defaultExecutor) execute(shellCommandArgs []string) (string, error) {
+// executeAttempt runs the command once. The context is limited by the timeout of the retry policy.
+func (de *defaultExecutor) executeAttempt(ctx context.Context, shellCommandArgs []string, env []string, attempt int,
+	attempts int) (string, error) {
 	argumentRemainder := []string{}
 	if len(shellCommandArgs) > 1 {
 		argumentRemainder = shellCommandArgs[1:]
 	}
 
-	cmd := exec.Command(shellCommandArgs[0], argumentRemainder...)
-	cmd.Env = os.Environ()
-
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-	// Output() runs the actual binary
-	output, err := cmd.Output()
-	outputStr := string(output)
+	cmd := exec.CommandContext(ctx, shellCommandArgs[0], argumentRemainder...)
+	cmd.Env = env
+	cmd.Cancel = func() error {
+		log.Warningf("Terminating command %v", shellCommandArgs)
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = killDelay
 
+	sink, err := openOutputSink(de.output.LogFile)
 	if err != nil {
-		return err.Error(), errors.Wrapf(err, "Command %s returned error: %s", shellCommandArgs, err.Error())
+		log.Warningf("Command output will not be written to a file: %s", err.Error())
+	}
+	defer sink.close()
+
+	commandName := filepath.Base(shellCommandArgs[0])
+	stdout := newStreamWriter(commandName, streamStdout, de.output.tailLines(), sink)
+	stderr := newStreamWriter(commandName, streamStderr, de.output.tailLines(), sink)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	err = cmd.Run()
+	duration := time.Since(start)
+	stdout.flush()
+	stderr.flush()
+	outputStr := stdout.tail.String()
+
+	exitCode := exitCodeOf(cmd, err)
+	log.Infof("Attempt %d/%d of command %v finished after %s with exit code %d", attempt, attempts, shellCommandArgs,
+		duration.Round(time.Millisecond), exitCode)
+
+	// the watcher cancels the context when it stops, so only an exceeded deadline is caused by the timeout
+	if de.policy.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return outputStr, errors.Errorf("Command %s timed out after %s%s", shellCommandArgs, de.policy.Timeout,
+			formatTails(stdout, stderr))
+	}
+
+	if exitCode != exitCodeNotStarted && de.policy.isSuccess(exitCode) {
+		log.Infof("Command %v returned successfully", shellCommandArgs)
+		return outputStr, nil
+	}
+
+	if err == nil {
+		err = errors.Errorf("exit code %d is not a success exit code", exitCode)
+	}
+	return err.Error(), errors.Wrapf(err, "Command %s returned error: %s%s", shellCommandArgs, err.Error(),
+		formatTails(stdout, stderr))
+}
+
+// exitCodeOf returns the exit code of a finished command.
+func exitCodeOf(cmd *exec.Cmd, err error) int {
+	if cmd.ProcessState != nil {
+		return cmd.ProcessState.ExitCode()
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
 	}
 
-	log.Infof("Command %v returned successfully: %s", shellCommandArgs, outputStr)
-	return outputStr, nil
+	return exitCodeNotStarted
 }
@@ -1,16 +1,20 @@
 package watcher
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func Test_defaultExecutor_execute(t *testing.T) {
 	t.Run("should echo to stdout", func(t *testing.T) {
 		sut := &defaultExecutor{}
 
-		actual, err := sut.execute([]string{"/bin/echo", "-n", "hello", "world"})
+		actual, err := sut.execute(context.Background(), []string{"/bin/echo", "-n", "hello", "world"}, nil)
 
 		require.NoError(t, err)
 		assert.Equal(t, "hello world", actual)
@@ -18,9 +22,117 @@ func Test_defaultExecutor_execute(t *testing.T) {
 	t.Run("should fail with output from stderr", func(t *testing.T) {
 		sut := &defaultExecutor{}
 
-		actual, err := sut.execute([]string{"/bin/something", "--not", "existing"})
+		actual, err := sut.execute(context.Background(), []string{"/bin/something", "--not", "existing"}, nil)
 
 		require.Error(t, err)
 		assert.Contains(t, actual, "no such file or directory")
 	})
+	t.Run("should terminate command when the context is done", func(t *testing.T) {
+		sut := &defaultExecutor{}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := sut.execute(ctx, []string{"/bin/sleep", "10"}, nil)
+
+		require.Error(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+	t.Run("should retry failed command", func(t *testing.T) {
+		marker := filepath.Join(t.TempDir(), "marker")
+		sut := &defaultExecutor{policy: RetryPolicy{Retries: 2, Backoff: Backoff{Initial: time.Millisecond}}}
+
+		actual, err := sut.execute(context.Background(), []string{"/bin/sh", "-c",
+			"if [ -f " + marker + " ]; then echo -n ok; else touch " + marker + "; exit 1; fi"}, nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, "ok", actual)
+	})
+	t.Run("should fail after all retries", func(t *testing.T) {
+		sut := &defaultExecutor{policy: RetryPolicy{Retries: 2, Backoff: Backoff{Initial: time.Millisecond}}}
+
+		_, err := sut.execute(context.Background(), []string{"/bin/sh", "-c", "exit 2"}, nil)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed after 3 attempts")
+		assert.Contains(t, err.Error(), "exit status 2")
+	})
+	t.Run("should accept configured success exit codes", func(t *testing.T) {
+		sut := &defaultExecutor{policy: RetryPolicy{SuccessExitCodes: []int{0, 3}}}
+
+		_, err := sut.execute(context.Background(), []string{"/bin/sh", "-c", "exit 3"}, nil)
+
+		require.NoError(t, err)
+	})
+	t.Run("should reject exit code 0 if it is no configured success exit code", func(t *testing.T) {
+		sut := &defaultExecutor{policy: RetryPolicy{SuccessExitCodes: []int{3}}}
+
+		_, err := sut.execute(context.Background(), []string{"/bin/true"}, nil)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exit code 0 is not a success exit code")
+	})
+	t.Run("should terminate attempt after timeout", func(t *testing.T) {
+		sut := &defaultExecutor{policy: RetryPolicy{Timeout: 50 * time.Millisecond}}
+
+		start := time.Now()
+		_, err := sut.execute(context.Background(), []string{"/bin/sleep", "10"}, nil)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out after 50ms")
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+	t.Run("should not retry after the context is done", func(t *testing.T) {
+		sut := &defaultExecutor{policy: RetryPolicy{Retries: 5, Backoff: Backoff{Initial: time.Hour}}}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := sut.execute(ctx, []string{"/bin/false"}, nil)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "aborted before the next retry")
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+	t.Run("should attach the output tails to the error of a failed command", func(t *testing.T) {
+		sut := &defaultExecutor{output: OutputOptions{TailLines: 2}}
+
+		_, err := sut.execute(context.Background(), []string{"/bin/sh", "-c",
+			"echo first; echo second; echo third; echo 'cannot stop confluence' >&2; exit 1"}, nil)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--- last lines of stdout ---\nsecond\nthird\n")
+		assert.Contains(t, err.Error(), "--- last lines of stderr ---\ncannot stop confluence")
+		assert.NotContains(t, err.Error(), "---\nfirst")
+	})
+	t.Run("should write the output to the log file", func(t *testing.T) {
+		logFile := filepath.Join(t.TempDir(), "command.log")
+		sut := &defaultExecutor{output: OutputOptions{LogFile: logFile}}
+
+		_, err := sut.execute(context.Background(), []string{"/bin/sh", "-c", "echo out; echo err >&2"}, nil)
+
+		require.NoError(t, err)
+		content, err := os.ReadFile(logFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "[stdout] out\n")
+		assert.Contains(t, string(content), "[stderr] err\n")
+	})
+	t.Run("should pass the given environment variables to the command", func(t *testing.T) {
+		sut := &defaultExecutor{}
+
+		actual, err := sut.execute(context.Background(), []string{"/bin/sh", "-c", "echo -n $LICENSE_EVENT"},
+			[]string{"LICENSE_EVENT=setup-to-production"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "setup-to-production", actual)
+	})
+	t.Run("should not pass the setup license to the command", func(t *testing.T) {
+		t.Setenv("SETUP_LICENSE", "AAAB")
+		sut := &defaultExecutor{}
+
+		actual, err := sut.execute(context.Background(), []string{"/bin/sh", "-c", "echo -n \"${SETUP_LICENSE:-unset}\""}, nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, "unset", actual)
+	})
 }
@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// expiryCheckInterval is the interval in which the watcher checks whether the configured license expires soon.
+const expiryCheckInterval = time.Hour
+
+// expiryWarning remembers the last warning so that each warning day is only emitted once per license.
+type expiryWarning struct {
+	license string
+	day     int
+}
+
+// checkExpiry emits EventLicenseExpiring if the known license expires within one of the expiry warning days and no
+// warning was emitted for this day yet. Setup licenses are not warned about because they expire by design.
+func (dw *defaultWatcher) checkExpiry(ctx context.Context) {
+	if len(dw.args.ExpiryWarningDays) == 0 || dw.knownLicense == "" {
+		return
+	}
+
+	decoded := decodeOrNil(dw.knownLicense)
+	if decoded == nil || !decoded.HasExpiry() {
+		return
+	}
+
+	now := time.Now()
+	if dw.args.Detection.IsSetupLicense(dw.knownLicense, dw.args.SetupLicense, now) {
+		return
+	}
+
+	day, due := dueWarningDay(dw.args.ExpiryWarningDays, decoded.DaysLeft(now))
+	if !due || (dw.lastExpiryWarning == expiryWarning{license: dw.knownLicense, day: day}) {
+		return
+	}
+	dw.lastExpiryWarning = expiryWarning{license: dw.knownLicense, day: day}
+
+	event := &Event{
+		Type:       EventLicenseExpiring,
+		Time:       now,
+		ConfigFile: dw.args.ConfluenceConfigFile,
+		OldLicense: dw.knownLicense,
+		NewLicense: dw.knownLicense,
+		Old:        decoded,
+		New:        decoded,
+	}
+	log.Infof("License expires in %d days.", decoded.DaysLeft(now))
+
+	actionCtx, cancel := withGracePeriod(ctx, dw.args.GracePeriod)
+	defer cancel()
+	handled, err := dw.dispatch(actionCtx, dw.subscriptions(), event)
+	if handled {
+		dw.state.recordAction(event, err)
+		dw.saveState()
+	}
+	if err != nil {
+		log.Errorf("Action failed on expiring license: %+s", err)
+	}
+}
+
+// dueWarningDay returns the smallest warning day which is not before the days left.
+func dueWarningDay(warningDays []int, daysLeft int) (day int, due bool) {
+	sorted := append([]int{}, warningDays...)
+	sort.Ints(sorted)
+
+	for _, warningDay := range sorted {
+		if daysLeft <= warningDay {
+			return warningDay, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,136 @@
+package watcher
+
+import (
+	"context"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_dueWarningDay(t *testing.T) {
+	warningDays := []int{7, 30, 1}
+
+	for daysLeft, expected := range map[int]int{30: 30, 25: 30, 7: 7, 2: 7, 1: 1, -3: 1} {
+		day, due := dueWarningDay(warningDays, daysLeft)
+
+		assert.True(t, due, daysLeft)
+		assert.Equal(t, expected, day, daysLeft)
+	}
+
+	_, due := dueWarningDay(warningDays, 31)
+	assert.False(t, due)
+}
+
+func Test_defaultWatcher_checkExpiry(t *testing.T) {
+	expiringLicense := encodeLicenseExpiringIn(t, 5)
+
+	t.Run("should emit expiring event once per warning day", func(t *testing.T) {
+		// given
+		action := newActionMock("mail")
+		action.On("Execute", mock.MatchedBy(func(event *Event) bool {
+			return event.Type == EventLicenseExpiring && event.NewLicense == expiringLicense && event.New != nil &&
+				event.ConfigFile == "confluence.cfg.xml"
+		})).Return(nil).Once()
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: "confluence.cfg.xml",
+				ExpiryWarningDays:    []int{30, 7, 1},
+				Actions:              []Subscription{{Action: action}},
+			},
+			knownLicense: expiringLicense,
+		}
+
+		// when
+		sut.checkExpiry(context.Background())
+		sut.checkExpiry(context.Background())
+
+		// then
+		action.AssertExpectations(t)
+		assert.Equal(t, expiryWarning{license: expiringLicense, day: 7}, sut.lastExpiryWarning)
+	})
+	t.Run("should not warn about licenses which expire later", func(t *testing.T) {
+		action := newActionMock("mail")
+		sut := &defaultWatcher{
+			args:         &ProcessArgs{ExpiryWarningDays: []int{1}, Actions: []Subscription{{Action: action}}},
+			knownLicense: expiringLicense,
+		}
+
+		sut.checkExpiry(context.Background())
+
+		action.AssertNotCalled(t, "Execute", mock.Anything)
+	})
+	t.Run("should not warn about setup licenses", func(t *testing.T) {
+		action := newActionMock("mail")
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				SetupLicense:      expiringLicense,
+				ExpiryWarningDays: []int{30},
+				Actions:           []Subscription{{Action: action}},
+			},
+			knownLicense: expiringLicense,
+		}
+
+		sut.checkExpiry(context.Background())
+
+		action.AssertNotCalled(t, "Execute", mock.Anything)
+	})
+}
+
+func Test_defaultWatcher_closeActions(t *testing.T) {
+	closing := &closingAction{actionMock: newActionMock("mail")}
+	sut := &defaultWatcher{
+		args: &ProcessArgs{
+			GracePeriod: time.Second,
+			Actions:     []Subscription{{Action: newActionMock("signal")}, {Action: closing}},
+		},
+	}
+
+	sut.closeActions(context.Background())
+
+	assert.True(t, closing.closed)
+}
+
+func Test_defaultWatcher_Watch_closeActions(t *testing.T) {
+	t.Run("should close actions when the watcher cannot start", func(t *testing.T) {
+		// given
+		closing := &closingAction{actionMock: newActionMock("mail")}
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: "/var/atlassian/confluence/confluence.cfg.xml",
+				GracePeriod:          time.Second,
+				Actions:              []Subscription{{Action: closing}},
+			},
+		}
+
+		// when
+		err := sut.Watch(context.Background())
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "a setup license is required")
+		assert.True(t, closing.closed)
+	})
+}
+
+// test util stuff
+func encodeLicenseExpiringIn(t *testing.T, days int) string {
+	t.Helper()
+
+	expiry := time.Now().AddDate(0, 0, days).Format("2006-01-02")
+	license, err := atlassian.Encode(map[string]string{"LicenseExpiryDate": expiry})
+	require.NoError(t, err)
+	return license
+}
+
+type closingAction struct {
+	*actionMock
+	closed bool
+}
+
+func (c *closingAction) Close(ctx context.Context) error {
+	c.closed = ctx.Err() == nil
+	return nil
+}
@@ -0,0 +1,88 @@
+package watcher
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// ErrorPolicy configures how the watcher handles license checks which fail, e.g. because Confluence rewrites its
+// config file in the moment it is read. A failed check is repeated after a backoff instead of the next trigger.
+type ErrorPolicy struct {
+	// Tolerated is the number of consecutive failed checks which are only logged as warnings. Further failed checks
+	// are logged as errors.
+	Tolerated int
+	// GiveUpAfter is the number of consecutive failed checks after which the watcher emits EventWatchFailed and
+	// stops. The watcher gives up on the first failed check if it is not positive.
+	GiveUpAfter int
+	// Backoff configures the waiting time until a failed check is repeated.
+	Backoff Backoff
+	// OnErrorCommand is a shell call which is executed when the watcher gives up. It gets the same environment and
+	// templates as the command, including the error. Nothing is executed if empty.
+	OnErrorCommand []string
+}
+
+// handleFailedCheck counts a failed license check and decides whether the watcher gives up. The watcher is done if
+// it gave up.
+func (dw *defaultWatcher) handleFailedCheck(ctx context.Context, checkErr error) (done bool, err error) {
+	dw.failedChecks++
+	policy := dw.args.ErrorPolicy
+
+	if dw.failedChecks >= policy.GiveUpAfter {
+		err = errors.Wrapf(checkErr, "license check failed %d times in a row", dw.failedChecks)
+		dw.giveUp(ctx, err)
+		return true, err
+	}
+
+	delay := policy.Backoff.Delay(dw.failedChecks)
+	if dw.failedChecks <= policy.Tolerated {
+		log.Warningf("License check failed %d times in a row, checking again in %s: %s", dw.failedChecks, delay, checkErr.Error())
+	} else {
+		log.Errorf("License check failed %d times in a row, checking again in %s: %s", dw.failedChecks, delay, checkErr.Error())
+	}
+	return false, nil
+}
+
+// retryDelay returns the waiting time until a failed check is repeated. It is zero if the last check succeeded.
+func (dw *defaultWatcher) retryDelay() time.Duration {
+	if dw.failedChecks == 0 {
+		return 0
+	}
+	return dw.args.ErrorPolicy.Backoff.Delay(dw.failedChecks)
+}
+
+// giveUp emits EventWatchFailed to the on-error command and all actions which subscribed to it.
+func (dw *defaultWatcher) giveUp(ctx context.Context, cause error) {
+	log.Errorf("Giving up watching the license: %s", cause.Error())
+
+	known := decodeOrNil(dw.knownLicense)
+	event := &Event{
+		Type:       EventWatchFailed,
+		Time:       time.Now(),
+		ConfigFile: dw.args.ConfluenceConfigFile,
+		OldLicense: dw.knownLicense,
+		NewLicense: dw.knownLicense,
+		Old:        known,
+		New:        known,
+		Error:      cause.Error(),
+	}
+
+	subscriptions := dw.subscriptions()
+	if len(dw.args.ErrorPolicy.OnErrorCommand) > 0 {
+		onError := Subscription{
+			Action: &commandAction{cmdExecutor: dw.cmdExecutor, commandArgs: dw.args.ErrorPolicy.OnErrorCommand},
+			Events: []EventType{EventWatchFailed},
+		}
+		subscriptions = append([]Subscription{onError}, subscriptions...)
+	}
+
+	actionCtx, cancel := withGracePeriod(ctx, dw.args.GracePeriod)
+	defer cancel()
+	handled, err := dw.dispatch(actionCtx, subscriptions, event)
+	if handled {
+		dw.state.recordAction(event, err)
+	}
+	if err != nil {
+		log.Errorf("Action failed after giving up: %+s", err)
+	}
+}
@@ -0,0 +1,160 @@
+package watcher
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_defaultWatcher_handleFailedCheck(t *testing.T) {
+	const licFile = "/var/atlassian/confluence/confluence.cfg.xml"
+	const license = "AAAB/testLicense+=okBf"
+
+	t.Run("should tolerate failed checks until giving up", func(t *testing.T) {
+		// given
+		action := newActionMock("webhook")
+		action.On("Execute", mock.MatchedBy(func(event *Event) bool {
+			return event.Type == EventWatchFailed && event.OldLicense == license && event.NewLicense == license &&
+				event.ConfigFile == licFile
+		})).Return(nil).Once()
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: licFile,
+				ErrorPolicy:          ErrorPolicy{Tolerated: 1, GiveUpAfter: 3, Backoff: Backoff{Initial: time.Second}},
+				Actions:              []Subscription{{Action: action, Events: []EventType{EventWatchFailed}}},
+			},
+			knownLicense: license,
+		}
+
+		// when
+		firstDone, firstErr := sut.handleFailedCheck(context.Background(), assert.AnError)
+		firstDelay := sut.retryDelay()
+		secondDone, secondErr := sut.handleFailedCheck(context.Background(), assert.AnError)
+		secondDelay := sut.retryDelay()
+		thirdDone, thirdErr := sut.handleFailedCheck(context.Background(), assert.AnError)
+
+		// then
+		assert.False(t, firstDone)
+		require.NoError(t, firstErr)
+		assert.Equal(t, time.Second, firstDelay)
+		assert.False(t, secondDone)
+		require.NoError(t, secondErr)
+		assert.Equal(t, 2*time.Second, secondDelay)
+		assert.True(t, thirdDone)
+		require.ErrorIs(t, thirdErr, assert.AnError)
+		assert.Contains(t, thirdErr.Error(), "license check failed 3 times in a row")
+		action.AssertExpectations(t)
+	})
+	t.Run("should give up on the first failed check without error policy", func(t *testing.T) {
+		// given
+		sut := &defaultWatcher{args: &ProcessArgs{ConfluenceConfigFile: licFile}, knownLicense: license}
+
+		// when
+		done, err := sut.handleFailedCheck(context.Background(), assert.AnError)
+
+		// then
+		assert.True(t, done)
+		require.ErrorIs(t, err, assert.AnError)
+	})
+	t.Run("should execute the on-error command with the error when giving up", func(t *testing.T) {
+		// given
+		recorder := &recordingExecutor{}
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				CommandArgs:          []string{"/opt/atlassian/confluence/bin/shutdown.sh"},
+				ConfluenceConfigFile: licFile,
+				ErrorPolicy:          ErrorPolicy{OnErrorCommand: []string{"/bin/alert", "{{.Event}}"}},
+			},
+			cmdExecutor:  recorder,
+			knownLicense: license,
+		}
+
+		// when
+		done, err := sut.handleFailedCheck(context.Background(), assert.AnError)
+
+		// then
+		assert.True(t, done)
+		require.Error(t, err)
+		assert.Equal(t, []string{"/bin/alert", "watch-failed"}, recorder.commandArgs)
+		assert.Contains(t, recorder.env, "LICENSE_WATCH_ERROR="+err.Error())
+	})
+}
+
+func Test_defaultWatcher_doWatchWork_missingConfigFile(t *testing.T) {
+	const licFile = "/var/atlassian/confluence/confluence.cfg.xml"
+	commandArgs := []string{"/opt/atlassian/confluence/bin/shutdown.sh"}
+
+	t.Run("should count a briefly missing config file as failed check", func(t *testing.T) {
+		// given
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("", readMissingConfigFile(t)).Once()
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("setup", nil).Once()
+		mockedExecutor := new(executorMock)
+		action := newActionMock("webhook")
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				CommandArgs:          commandArgs,
+				ConfluenceConfigFile: licFile,
+				SetupLicense:         "setup",
+				ErrorPolicy:          ErrorPolicy{Tolerated: 3, GiveUpAfter: 20, Backoff: Backoff{Initial: time.Second}},
+				Actions:              []Subscription{{Action: action, Events: AllEventTypes}},
+			},
+			cmdExecutor:   mockedExecutor,
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  "setup",
+		}
+
+		// when
+		missingDone, missingErr := sut.doWatchWork(context.Background())
+		retryDelay := sut.retryDelay()
+		backDone, backErr := sut.doWatchWork(context.Background())
+
+		// then
+		require.NoError(t, missingErr)
+		assert.False(t, missingDone)
+		assert.Equal(t, time.Second, retryDelay)
+		require.NoError(t, backErr)
+		assert.False(t, backDone)
+		assert.Zero(t, sut.failedChecks)
+		assert.Equal(t, "setup", sut.knownLicense)
+		action.AssertExpectations(t)
+		mockedExecutor.AssertExpectations(t)
+	})
+	t.Run("should give up if the config file stays missing", func(t *testing.T) {
+		// given
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("", readMissingConfigFile(t))
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: licFile,
+				SetupLicense:         "setup",
+				ErrorPolicy:          ErrorPolicy{GiveUpAfter: 2, Backoff: Backoff{Initial: time.Second}},
+			},
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  "setup",
+		}
+
+		// when
+		firstDone, firstErr := sut.doWatchWork(context.Background())
+		secondDone, secondErr := sut.doWatchWork(context.Background())
+
+		// then
+		require.NoError(t, firstErr)
+		assert.False(t, firstDone)
+		assert.True(t, secondDone)
+		require.ErrorIs(t, secondErr, os.ErrNotExist)
+	})
+}
+
+func Test_defaultWatcher_retryDelay(t *testing.T) {
+	sut := &defaultWatcher{args: &ProcessArgs{ErrorPolicy: ErrorPolicy{Backoff: Backoff{Initial: time.Second}}}}
+
+	assert.Zero(t, sut.retryDelay())
+
+	sut.failedChecks = 3
+	assert.Equal(t, 4*time.Second, sut.retryDelay())
+}
@@ -0,0 +1,188 @@
+//go:build linux
+
+package watcher
+
+import (
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_CREATE | syscall.IN_DELETE |
+		syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM
+	// inotifyDebounce is the time to wait for further events before the config file is checked. Confluence writes
+	// the config file in several steps, so a single check after the last event suffices.
+	inotifyDebounce = 200 * time.Millisecond
+)
+
+// magic numbers of file systems on which inotify does not notice changes made by other hosts
+var remoteFileSystems = map[uint32]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x65735546: "fuse",
+	0x01021997: "9p",
+}
+
+// supportsInotify checks if changes of the config file can be detected with inotify.
+func supportsInotify(configFile string) (bool, string) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(filepath.Dir(configFile), &stat)
+	if err != nil {
+		return false, "cannot determine file system: " + err.Error()
+	}
+
+	if name, remote := remoteFileSystems[uint32(stat.Type)]; remote {
+		return false, "inotify is not supported on " + name + " file systems"
+	}
+
+	return true, ""
+}
+
+// inotifyTrigger watches the directory of the config file so that atomic replacements by rename are noticed, too.
+type inotifyTrigger struct {
+	fd       int
+	wd       int
+	fileName string
+	c        chan struct{}
+	debounce *time.Timer
+	stopping chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+	mutex    sync.Mutex
+	closed   bool
+	err      error
+}
+
+func newInotifyTrigger(configFile string) (trigger, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize inotify")
+	}
+
+	dir := filepath.Dir(configFile)
+	wd, err := syscall.InotifyAddWatch(fd, dir, inotifyMask)
+	if err != nil {
+		_ = syscall.Close(fd)
+		return nil, errors.Wrapf(err, "failed to watch directory '%s'", dir)
+	}
+
+	it := &inotifyTrigger{
+		fd:       fd,
+		wd:       wd,
+		fileName: filepath.Base(configFile),
+		c:        make(chan struct{}, 1),
+		stopping: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	it.debounce = time.AfterFunc(time.Hour, it.notify)
+	it.debounce.Stop()
+	go it.readEvents()
+
+	return it, nil
+}
+
+func (it *inotifyTrigger) readEvents() {
+	defer close(it.stopped)
+	defer func() {
+		it.debounce.Stop()
+		it.mutex.Lock()
+		it.closed = true
+		close(it.c)
+		it.mutex.Unlock()
+	}()
+
+	buffer := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := syscall.Read(it.fd, buffer)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			it.setErr(errors.Wrap(err, "failed to read inotify events"))
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			mask := binary.NativeEndian.Uint32(buffer[offset+4:])
+			nameLength := int(binary.NativeEndian.Uint32(buffer[offset+12:]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := trimNull(buffer[nameStart : nameStart+nameLength])
+			offset = nameStart + nameLength
+
+			if mask&syscall.IN_IGNORED != 0 {
+				select {
+				case <-it.stopping:
+				default:
+					it.setErr(errors.New("inotify watch was removed, e.g. because the watched directory was deleted"))
+				}
+				return
+			}
+
+			if name == it.fileName {
+				log.Debugf("Received inotify event 0x%x for '%s'", mask, name)
+				it.debounce.Reset(inotifyDebounce)
+			}
+		}
+	}
+}
+
+func trimNull(name []byte) string {
+	for i, b := range name {
+		if b == 0 {
+			return string(name[:i])
+		}
+	}
+	return string(name)
+}
+
+// notify sends a non-blocking notification so that several changes lead to a single check.
+func (it *inotifyTrigger) notify() {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+
+	if it.closed {
+		return
+	}
+
+	select {
+	case it.c <- struct{}{}:
+	default:
+	}
+}
+
+func (it *inotifyTrigger) setErr(err error) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.err = err
+}
+
+func (it *inotifyTrigger) C() <-chan struct{} {
+	return it.c
+}
+
+func (it *inotifyTrigger) Err() error {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	return it.err
+}
+
+// Stop removes the watch. The kernel then sends an IN_IGNORED event which ends the blocking read.
+func (it *inotifyTrigger) Stop() {
+	it.stopOnce.Do(func() {
+		close(it.stopping)
+		select {
+		case <-it.stopped:
+			// the reader already stopped on its own
+		default:
+			_, _ = syscall.InotifyRmWatch(it.fd, uint32(it.wd))
+			<-it.stopped
+		}
+		_ = syscall.Close(it.fd)
+	})
+}
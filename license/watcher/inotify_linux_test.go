@@ -0,0 +1,141 @@
+//go:build linux
+
+package watcher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_inotifyTrigger(t *testing.T) {
+	t.Run("should fire when the config file is written", func(t *testing.T) {
+		configFile := filepath.Join(t.TempDir(), "confluence.cfg.xml")
+		require.NoError(t, os.WriteFile(configFile, []byte("old"), 0600))
+		sut, err := newInotifyTrigger(configFile)
+		require.NoError(t, err)
+		defer sut.Stop()
+
+		// when
+		require.NoError(t, os.WriteFile(configFile, []byte("new"), 0600))
+
+		// then
+		expectTrigger(t, sut)
+	})
+	t.Run("should fire when the config file is replaced by rename", func(t *testing.T) {
+		dir := t.TempDir()
+		configFile := filepath.Join(dir, "confluence.cfg.xml")
+		require.NoError(t, os.WriteFile(configFile, []byte("old"), 0600))
+		sut, err := newInotifyTrigger(configFile)
+		require.NoError(t, err)
+		defer sut.Stop()
+
+		// when
+		tempFile := filepath.Join(dir, "confluence.cfg.xml.tmp")
+		require.NoError(t, os.WriteFile(tempFile, []byte("new"), 0600))
+		require.NoError(t, os.Rename(tempFile, configFile))
+
+		// then
+		expectTrigger(t, sut)
+	})
+	t.Run("should coalesce several writes into a single check", func(t *testing.T) {
+		configFile := filepath.Join(t.TempDir(), "confluence.cfg.xml")
+		sut, err := newInotifyTrigger(configFile)
+		require.NoError(t, err)
+		defer sut.Stop()
+
+		// when
+		for i := 0; i < 5; i++ {
+			require.NoError(t, os.WriteFile(configFile, []byte{byte(i)}, 0600))
+		}
+
+		// then
+		expectTrigger(t, sut)
+		select {
+		case <-sut.C():
+			t.Fatal("expected a single check")
+		case <-time.After(2 * inotifyDebounce):
+		}
+	})
+	t.Run("should ignore other files", func(t *testing.T) {
+		dir := t.TempDir()
+		sut, err := newInotifyTrigger(filepath.Join(dir, "confluence.cfg.xml"))
+		require.NoError(t, err)
+		defer sut.Stop()
+
+		// when
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "other.xml"), []byte("other"), 0600))
+
+		// then
+		select {
+		case <-sut.C():
+			t.Fatal("expected no check")
+		case <-time.After(2 * inotifyDebounce):
+		}
+	})
+	t.Run("should close channel on stop", func(t *testing.T) {
+		sut, err := newInotifyTrigger(filepath.Join(t.TempDir(), "confluence.cfg.xml"))
+		require.NoError(t, err)
+
+		sut.Stop()
+		sut.Stop()
+
+		_, open := <-sut.C()
+		assert.False(t, open)
+		assert.NoError(t, sut.Err())
+	})
+	t.Run("should stop with error when the directory is removed", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "confluence")
+		require.NoError(t, os.Mkdir(dir, 0700))
+		sut, err := newInotifyTrigger(filepath.Join(dir, "confluence.cfg.xml"))
+		require.NoError(t, err)
+		defer sut.Stop()
+
+		// when
+		require.NoError(t, os.Remove(dir))
+
+		// then
+		select {
+		case _, open := <-sut.C():
+			assert.False(t, open)
+		case <-time.After(time.Second):
+			t.Fatal("inotify trigger did not stop")
+		}
+		require.Error(t, sut.Err())
+		assert.Contains(t, sut.Err().Error(), "inotify watch was removed")
+	})
+	t.Run("should fail on missing directory", func(t *testing.T) {
+		_, err := newInotifyTrigger("/does/not/exist/confluence.cfg.xml")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to watch directory")
+	})
+}
+
+func Test_supportsInotify(t *testing.T) {
+	t.Run("should support local directory", func(t *testing.T) {
+		actual, reason := supportsInotify(filepath.Join(t.TempDir(), "confluence.cfg.xml"))
+
+		assert.True(t, actual, reason)
+	})
+	t.Run("should not support missing directory", func(t *testing.T) {
+		actual, reason := supportsInotify("/does/not/exist/confluence.cfg.xml")
+
+		assert.False(t, actual)
+		assert.Contains(t, reason, "cannot determine file system")
+	})
+}
+
+func expectTrigger(t *testing.T, sut trigger) {
+	t.Helper()
+
+	select {
+	case _, open := <-sut.C():
+		require.True(t, open)
+	case <-time.After(2 * time.Second):
+		t.Fatal("inotify trigger did not fire")
+	}
+}
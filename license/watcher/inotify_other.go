@@ -0,0 +1,15 @@
+//go:build !linux
+
+package watcher
+
+import (
+	"github.com/pkg/errors"
+)
+
+func supportsInotify(string) (bool, string) {
+	return false, "inotify is only supported on linux"
+}
+
+func newInotifyTrigger(string) (trigger, error) {
+	return nil, errors.New("inotify is only supported on linux")
+}
@@ -0,0 +1,359 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/pkg/errors"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// mailQueueSize limits the number of mails which wait to be sent.
+const mailQueueSize = 100
+
+// DefaultMailSubjectTemplate renders the subject of mails.
+const DefaultMailSubjectTemplate = `{{if eq .Event "license-expiring"}}Confluence license on {{.Host}} expires in ` +
+	`{{.NewLicense.DaysLeft}} days{{else}}Confluence license event {{.Event}} on {{.Host}}{{end}}`
+
+// DefaultMailTextTemplate renders the plain text body of mails.
+const DefaultMailTextTemplate = `{{if eq .Event "license-expiring"}}The Confluence license on {{.Host}} expires on ` +
+	`{{.NewLicense.Expiry}}.{{else}}The license-checker on {{.Host}} detected the license event {{.Event}}.{{end}}
+
+Config file: {{.ConfigFile}}
+Time:        {{.Time.Format "2006-01-02 15:04:05 MST"}}
+{{- with .OldLicense}}
+Old license: {{.SHA256}}{{if .Expiry}}, expires on {{.Expiry}}{{end}}{{end}}
+{{- with .NewLicense}}
+New license: {{.SHA256}}{{if .Expiry}}, expires on {{.Expiry}}{{end}}{{end}}
+{{- with .Error}}
+Error:       {{.}}{{end}}
+`
+
+// DefaultMailHTMLTemplate renders the HTML body of mails.
+const DefaultMailHTMLTemplate = `<html><body>
+<p>{{if eq .Event "license-expiring"}}The Confluence license on <b>{{.Host}}</b> expires on ` +
+	`<b>{{.NewLicense.Expiry}}</b>.{{else}}The license-checker on <b>{{.Host}}</b> detected the license event ` +
+	`<b>{{.Event}}</b>.{{end}}</p>
+<table>
+<tr><td>Config file</td><td>{{.ConfigFile}}</td></tr>
+<tr><td>Time</td><td>{{.Time.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+{{- with .OldLicense}}
+<tr><td>Old license</td><td>{{.SHA256}}{{if .Expiry}}, expires on {{.Expiry}}{{end}}</td></tr>{{end}}
+{{- with .NewLicense}}
+<tr><td>New license</td><td>{{.SHA256}}{{if .Expiry}}, expires on {{.Expiry}}{{end}}</td></tr>{{end}}
+{{- with .Error}}
+<tr><td>Error</td><td>{{.}}</td></tr>{{end}}
+</table>
+</body></html>
+`
+
+// MailOptions configures the mail action.
+type MailOptions struct {
+	// Address is the address of the SMTP relay in the form host:port.
+	Address string
+	// StartTLS requires the relay to upgrade the connection with STARTTLS before anything else is sent.
+	StartTLS bool
+	// Username and Password authenticate with PLAIN authentication. No authentication is done if the username is
+	// empty. The password is only sent over encrypted connections or to localhost.
+	Username string
+	Password string
+	// From is the sender address.
+	From string
+	// To are the recipient addresses.
+	To []string
+	// SubjectTemplate, TextTemplate and HTMLTemplate are Go templates which render the subject and both bodies of a
+	// mail from a Notification. The defaults are used if empty.
+	SubjectTemplate string
+	TextTemplate    string
+	HTMLTemplate    string
+	// Retry configures the timeout of a single delivery and how often a mail is retried while the relay cannot be
+	// reached. Its success exit codes are ignored.
+	Retry RetryPolicy
+}
+
+// mailMessage is a rendered mail which waits in the queue.
+type mailMessage struct {
+	event   EventType
+	content []byte
+}
+
+// mailAction sends mails about license events. Mails are queued and sent in the background so that an unreachable
+// relay does not hold up other actions.
+type mailAction struct {
+	options     MailOptions
+	sender      string
+	recipients  []string
+	subject     *template.Template
+	text        *template.Template
+	html        *htmltemplate.Template
+	host        string
+	tlsConfig   *tls.Config
+	queue       chan *mailMessage
+	mutex       sync.Mutex
+	closed      bool
+	stopped     chan struct{}
+	sendCtx     context.Context
+	cancelSends context.CancelFunc
+}
+
+// NewMailAction creates an action which sends mails about license events through an SMTP relay.
+func NewMailAction(options MailOptions) (Action, error) {
+	if options.Address == "" {
+		return nil, errors.New("an SMTP relay address must be given")
+	}
+	host, _, err := net.SplitHostPort(options.Address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid SMTP relay address '%s'", options.Address)
+	}
+
+	from, err := mail.ParseAddress(options.From)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid sender address '%s'", options.From)
+	}
+	if len(options.To) == 0 {
+		return nil, errors.New("at least one recipient address must be given")
+	}
+	var recipients []string
+	for _, recipient := range options.To {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid recipient address '%s'", recipient)
+		}
+		recipients = append(recipients, address.Address)
+	}
+
+	action := &mailAction{
+		options:    options,
+		sender:     from.Address,
+		recipients: recipients,
+		host:       hostname(),
+		tlsConfig:  &tls.Config{ServerName: host},
+		queue:      make(chan *mailMessage, mailQueueSize),
+		stopped:    make(chan struct{}),
+	}
+
+	action.subject, err = template.New("subject").Parse(defaultIfEmpty(options.SubjectTemplate, DefaultMailSubjectTemplate))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse mail subject template")
+	}
+	action.text, err = template.New("text").Parse(defaultIfEmpty(options.TextTemplate, DefaultMailTextTemplate))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse mail text template")
+	}
+	action.html, err = htmltemplate.New("html").Parse(defaultIfEmpty(options.HTMLTemplate, DefaultMailHTMLTemplate))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse mail HTML template")
+	}
+
+	action.sendCtx, action.cancelSends = context.WithCancel(context.Background())
+	go action.run()
+	return action, nil
+}
+
+func defaultIfEmpty(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func (ma *mailAction) Name() string {
+	return "mail"
+}
+
+// Execute renders the mail and queues it. The mail is sent in the background.
+func (ma *mailAction) Execute(_ context.Context, event *Event) error {
+	content, err := ma.render(newNotification(event, ma.host))
+	if err != nil {
+		return err
+	}
+
+	ma.mutex.Lock()
+	defer ma.mutex.Unlock()
+	if ma.closed {
+		return errors.Errorf("cannot send mail on event '%s' because the mail action was closed", event.Type)
+	}
+
+	select {
+	case ma.queue <- &mailMessage{event: event.Type, content: content}:
+		log.Debugf("Queued mail on event '%s'", event.Type)
+		return nil
+	default:
+		return errors.Errorf("cannot send mail on event '%s' because %d mails are already waiting", event.Type, mailQueueSize)
+	}
+}
+
+// Close stops accepting mails and waits until all queued mails are sent. The queued mails may take as long as the
+// retry policy allows for them, even if the deadline of the context is earlier, so that a short grace period does not
+// drop mails which are still retried. Remaining mails are dropped when the context and this time are over.
+func (ma *mailAction) Close(ctx context.Context) error {
+	ma.mutex.Lock()
+	if !ma.closed {
+		ma.closed = true
+		close(ma.queue)
+	}
+	// the mail which is currently sent is not in the queue anymore
+	pending := len(ma.queue) + 1
+	ma.mutex.Unlock()
+
+	drainTime := ma.options.Retry.maxDuration() * time.Duration(pending)
+	if deadline, ok := ctx.Deadline(); ok && drainTime > 0 && time.Until(deadline) < drainTime {
+		log.Debugf("Waiting up to %s for queued mails to be sent", drainTime)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), drainTime)
+		defer cancel()
+	}
+
+	select {
+	case <-ma.stopped:
+		return nil
+	case <-ctx.Done():
+		ma.cancelSends()
+		<-ma.stopped
+		return errors.Wrap(ctx.Err(), "stopped sending queued mails")
+	}
+}
+
+func (ma *mailAction) run() {
+	defer close(ma.stopped)
+	defer ma.cancelSends()
+
+	for message := range ma.queue {
+		if ma.sendCtx.Err() != nil {
+			log.Errorf("Dropped mail on event '%s' because the mail action was closed", message.event)
+			continue
+		}
+
+		description := fmt.Sprintf("sending mail on event '%s'", message.event)
+		err := retry(ma.sendCtx, ma.options.Retry, description, func(ctx context.Context) error {
+			return ma.send(ctx, message.content)
+		})
+		if err != nil {
+			log.Errorf("Failed to send mail: %s", err.Error())
+			continue
+		}
+		log.Infof("Sent mail on event '%s'", message.event)
+	}
+}
+
+func (ma *mailAction) render(notification *Notification) ([]byte, error) {
+	var subject, text, html bytes.Buffer
+	if err := ma.subject.Execute(&subject, notification); err != nil {
+		return nil, errors.Wrap(err, "failed to render mail subject")
+	}
+	if err := ma.text.Execute(&text, notification); err != nil {
+		return nil, errors.Wrap(err, "failed to render mail text")
+	}
+	if err := ma.html.Execute(&html, notification); err != nil {
+		return nil, errors.Wrap(err, "failed to render mail HTML")
+	}
+
+	var content bytes.Buffer
+	body := multipart.NewWriter(&content)
+	headers := []string{
+		"From: " + ma.options.From,
+		"To: " + strings.Join(ma.options.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())),
+		"Date: " + notification.Time.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + body.Boundary(),
+	}
+	content.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{{"text/plain; charset=utf-8", text.Bytes()}, {"text/html; charset=utf-8", html.Bytes()}} {
+		writer, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create mail part")
+		}
+
+		encoder := quotedprintable.NewWriter(writer)
+		if _, err = encoder.Write(part.content); err != nil {
+			return nil, errors.Wrap(err, "failed to write mail part")
+		}
+		if err = encoder.Close(); err != nil {
+			return nil, errors.Wrap(err, "failed to write mail part")
+		}
+	}
+
+	if err := body.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to finish mail")
+	}
+	return content.Bytes(), nil
+}
+
+func (ma *mailAction) send(ctx context.Context, content []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", ma.options.Address)
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to SMTP relay '%s'", ma.options.Address)
+	}
+	defer conn.Close()
+
+	// the SMTP client does not support contexts, so a done context interrupts the connection
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	client, err := smtp.NewClient(conn, ma.tlsConfig.ServerName)
+	if err != nil {
+		return errors.Wrap(err, "failed to greet SMTP relay")
+	}
+	defer client.Close()
+
+	if ma.options.StartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return &permanentError{cause: errors.Errorf("SMTP relay '%s' does not support STARTTLS", ma.options.Address)}
+		}
+		if err = client.StartTLS(ma.tlsConfig); err != nil {
+			return errors.Wrap(err, "failed to start TLS")
+		}
+	}
+
+	if ma.options.Username != "" {
+		auth := smtp.PlainAuth("", ma.options.Username, ma.options.Password, ma.tlsConfig.ServerName)
+		if err = client.Auth(auth); err != nil {
+			return errors.Wrap(err, "failed to authenticate with SMTP relay")
+		}
+	}
+
+	if err = client.Mail(ma.sender); err != nil {
+		return errors.Wrap(err, "SMTP relay rejected the sender")
+	}
+	for _, recipient := range ma.recipients {
+		if err = client.Rcpt(recipient); err != nil {
+			return errors.Wrapf(err, "SMTP relay rejected the recipient '%s'", recipient)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return errors.Wrap(err, "SMTP relay rejected the mail")
+	}
+	if _, err = writer.Write(content); err != nil {
+		return errors.Wrap(err, "failed to send the mail")
+	}
+	if err = writer.Close(); err != nil {
+		return errors.Wrap(err, "SMTP relay rejected the mail")
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,363 @@
+package watcher
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewMailAction(t *testing.T) {
+	valid := MailOptions{Address: "mail.example.com:25", From: "Confluence <confluence@example.com>", To: []string{"ops@example.com"}}
+
+	action, err := NewMailAction(valid)
+	require.NoError(t, err)
+	require.NoError(t, action.(Closer).Close(context.Background()))
+
+	for message, options := range map[string]MailOptions{
+		"an SMTP relay address must be given":   {From: valid.From, To: valid.To},
+		"invalid SMTP relay address":            {Address: "mail.example.com", From: valid.From, To: valid.To},
+		"invalid sender address":                {Address: valid.Address, From: "confluence", To: valid.To},
+		"at least one recipient address":        {Address: valid.Address, From: valid.From},
+		"invalid recipient address 'ops'":       {Address: valid.Address, From: valid.From, To: []string{"ops"}},
+		"failed to parse mail subject template": {Address: valid.Address, From: valid.From, To: valid.To, SubjectTemplate: "{{"},
+		"failed to parse mail HTML template":    {Address: valid.Address, From: valid.From, To: valid.To, HTMLTemplate: "{{end}}"},
+		"failed to parse mail text template":    {Address: valid.Address, From: valid.From, To: valid.To, TextTemplate: "{{.Event"},
+	} {
+		_, err := NewMailAction(options)
+
+		require.Error(t, err, message)
+		assert.Contains(t, err.Error(), message)
+	}
+}
+
+func Test_mailAction(t *testing.T) {
+	newLicense, err := atlassian.Encode(map[string]string{"LicenseExpiryDate": "2027-03-31"})
+	require.NoError(t, err)
+	event := &Event{
+		Type:       EventSetupToProduction,
+		Time:       time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC),
+		ConfigFile: "confluence.cfg.xml",
+		OldLicense: "setup",
+		NewLicense: newLicense,
+		New:        decodeOrNil(newLicense),
+	}
+
+	t.Run("should send mail with text and HTML part", func(t *testing.T) {
+		// given
+		server := newFakeSMTPServer(t, nil, 0)
+		sut := newTestMailAction(t, MailOptions{Address: server.address(), From: "Confluence <confluence@example.com>",
+			To: []string{"ops@example.com", "admin@example.com"}})
+
+		// when
+		err := sut.Execute(context.Background(), event)
+		require.NoError(t, err)
+		err = sut.Close(context.Background())
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, server.sentMails(), 1)
+		sent := server.sentMails()[0]
+		assert.Equal(t, "confluence@example.com", sent.from)
+		assert.Equal(t, []string{"ops@example.com", "admin@example.com"}, sent.to)
+
+		message, err := mail.ReadMessage(strings.NewReader(sent.data))
+		require.NoError(t, err)
+		subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+		require.NoError(t, err)
+		assert.Equal(t, "Confluence license event setup-to-production on dogu-host", subject)
+
+		parts := readMailParts(t, message)
+		assert.Contains(t, parts["text/plain; charset=utf-8"], "detected the license event setup-to-production")
+		assert.Contains(t, parts["text/plain; charset=utf-8"], "New license: "+atlassian.Fingerprint(newLicense)+", expires on 2027-03-31")
+		assert.Contains(t, parts["text/html; charset=utf-8"], "<b>setup-to-production</b>")
+		assert.NotContains(t, sent.data, newLicense)
+	})
+	t.Run("should render expiry warning", func(t *testing.T) {
+		server := newFakeSMTPServer(t, nil, 0)
+		sut := newTestMailAction(t, MailOptions{Address: server.address(), From: "confluence@example.com", To: []string{"ops@example.com"}})
+		expiring := *event
+		expiring.Type = EventLicenseExpiring
+		expiring.Time = time.Date(2027, 3, 24, 12, 0, 0, 0, time.UTC)
+
+		require.NoError(t, sut.Execute(context.Background(), &expiring))
+		require.NoError(t, sut.Close(context.Background()))
+
+		require.Len(t, server.sentMails(), 1)
+		message, err := mail.ReadMessage(strings.NewReader(server.sentMails()[0].data))
+		require.NoError(t, err)
+		assert.Equal(t, "Confluence license on dogu-host expires in 7 days", message.Header.Get("Subject"))
+		assert.Contains(t, readMailParts(t, message)["text/plain; charset=utf-8"], "expires on 2027-03-31")
+	})
+	t.Run("should authenticate after STARTTLS", func(t *testing.T) {
+		// given
+		serverTLS, clientTLS := newTestTLSConfigs(t)
+		server := newFakeSMTPServer(t, serverTLS, 0)
+		sut := newTestMailAction(t, MailOptions{Address: server.address(), StartTLS: true, Username: "mailer",
+			Password: "secret", From: "confluence@example.com", To: []string{"ops@example.com"}})
+		sut.tlsConfig = clientTLS
+
+		// when
+		require.NoError(t, sut.Execute(context.Background(), event))
+		err := sut.Close(context.Background())
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, server.sentMails(), 1)
+		assert.True(t, server.sentMails()[0].tls)
+		assert.Equal(t, "\x00mailer\x00secret", server.sentMails()[0].auth)
+	})
+	t.Run("should retry while the relay is not available", func(t *testing.T) {
+		server := newFakeSMTPServer(t, nil, 2)
+		sut := newTestMailAction(t, MailOptions{Address: server.address(), From: "confluence@example.com",
+			To: []string{"ops@example.com"}, Retry: RetryPolicy{Retries: 3, Backoff: Backoff{Initial: time.Millisecond}}})
+
+		require.NoError(t, sut.Execute(context.Background(), event))
+		require.NoError(t, sut.Close(context.Background()))
+
+		assert.Len(t, server.sentMails(), 1)
+		assert.Equal(t, 3, server.connectionCount())
+	})
+	t.Run("should keep retrying queued mails after the grace period", func(t *testing.T) {
+		// given
+		server := newFakeSMTPServer(t, nil, 3)
+		sut := newTestMailAction(t, MailOptions{Address: server.address(), From: "confluence@example.com",
+			To: []string{"ops@example.com"}, Retry: RetryPolicy{Timeout: time.Second, Retries: 5,
+				Backoff: Backoff{Initial: 50 * time.Millisecond}}})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		// when
+		require.NoError(t, sut.Execute(context.Background(), event))
+		err := sut.Close(ctx)
+
+		// then
+		require.NoError(t, err)
+		assert.Len(t, server.sentMails(), 1)
+		assert.Equal(t, 4, server.connectionCount())
+	})
+	t.Run("should drop queued mails when closing takes too long", func(t *testing.T) {
+		server := newFakeSMTPServer(t, nil, 1000)
+		sut := newTestMailAction(t, MailOptions{Address: server.address(), From: "confluence@example.com",
+			To: []string{"ops@example.com"}, Retry: RetryPolicy{Retries: 1000, Backoff: Backoff{Initial: 10 * time.Millisecond}}})
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		require.NoError(t, sut.Execute(context.Background(), event))
+		err := sut.Close(ctx)
+
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Empty(t, server.sentMails())
+		err = sut.Execute(context.Background(), event)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "because the mail action was closed")
+	})
+}
+
+// test util stuff
+
+type sentMail struct {
+	from string
+	to   []string
+	data string
+	auth string
+	tls  bool
+}
+
+// fakeSMTPServer accepts mails over SMTP and records them. It supports STARTTLS if a TLS config is given. The first
+// connections are rejected as if the server was not available.
+type fakeSMTPServer struct {
+	listener    net.Listener
+	tlsConfig   *tls.Config
+	mutex       sync.Mutex
+	unavailable int
+	connections int
+	mails       []sentMail
+}
+
+func newFakeSMTPServer(t *testing.T, tlsConfig *tls.Config, unavailable int) *fakeSMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fakeSMTPServer{listener: listener, tlsConfig: tlsConfig, unavailable: unavailable}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeSMTPServer) address() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeSMTPServer) sentMails() []sentMail {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]sentMail{}, s.mails...)
+}
+
+func (s *fakeSMTPServer) connectionCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.connections
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	s.mutex.Lock()
+	s.connections++
+	unavailable := s.connections <= s.unavailable
+	s.mutex.Unlock()
+
+	text := textproto.NewConn(conn)
+	if unavailable {
+		_ = text.PrintfLine("421 service not available")
+		return
+	}
+	_ = text.PrintfLine("220 fake ESMTP")
+
+	current := sentMail{}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO", "HELO":
+			extensions := []string{"250-fake", "250-AUTH PLAIN"}
+			if s.tlsConfig != nil && !current.tls {
+				extensions = append(extensions, "250-STARTTLS")
+			}
+			_ = text.PrintfLine("%s\r\n250 8BITMIME", strings.Join(extensions, "\r\n"))
+		case "STARTTLS":
+			_ = text.PrintfLine("220 ready for TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn = tlsConn
+			text = textproto.NewConn(conn)
+			current.tls = true
+		case "AUTH":
+			decoded, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "AUTH PLAIN "))
+			current.auth = string(decoded)
+			_ = text.PrintfLine("235 authenticated")
+		case "MAIL":
+			current.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			current.from = strings.Split(current.from, ">")[0]
+			_ = text.PrintfLine("250 ok")
+		case "RCPT":
+			current.to = append(current.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			_ = text.PrintfLine("250 ok")
+		case "DATA":
+			_ = text.PrintfLine("354 send data")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			current.data = string(data)
+			s.mutex.Lock()
+			s.mails = append(s.mails, current)
+			s.mutex.Unlock()
+			_ = text.PrintfLine("250 queued")
+		case "QUIT":
+			_ = text.PrintfLine("221 bye")
+			return
+		default:
+			_ = text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func newTestMailAction(t *testing.T, options MailOptions) *mailAction {
+	t.Helper()
+
+	action, err := NewMailAction(options)
+	require.NoError(t, err)
+	mailer := action.(*mailAction)
+	mailer.host = "dogu-host"
+	return mailer
+}
+
+// newTestTLSConfigs creates a self-signed certificate for 127.0.0.1 and configs for server and client which use it.
+func newTestTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake smtp"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(certificate)
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	clientConfig := &tls.Config{ServerName: "127.0.0.1", RootCAs: roots}
+	return serverConfig, clientConfig
+}
+
+// readMailParts returns the decoded parts of a multipart mail by their content type.
+func readMailParts(t *testing.T, message *mail.Message) map[string]string {
+	t.Helper()
+
+	_, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	require.NoError(t, err)
+
+	parts := map[string]string{}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(bufio.NewReader(part))
+		require.NoError(t, err)
+		parts[part.Header.Get("Content-Type")] = string(content)
+	}
+}
@@ -0,0 +1,239 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsContentType is the content type of the Prometheus text format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Results of license checks and outcomes of actions as used in metric labels.
+const (
+	metricResultSuccess = "success"
+	metricResultFailure = "failure"
+)
+
+// checkDurationBuckets are the upper bounds of the check duration histogram in seconds. Reading the config file
+// usually takes milliseconds, but may take seconds on a slow shared file system.
+var checkDurationBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Metrics counts license checks and action invocations of a running watcher and exposes them in the Prometheus text
+// format. It is safe for concurrent use.
+type Metrics struct {
+	mutex               sync.Mutex
+	checks              map[string]int
+	checkDurationCounts []int
+	checkDurationSum    float64
+	lastSuccessfulCheck time.Time
+	actions             map[actionOutcome]int
+	licenseExpiry       time.Time
+	setupLicense        bool
+}
+
+type actionOutcome struct {
+	action  string
+	event   EventType
+	outcome string
+}
+
+// NewMetrics creates metrics without any observations.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		checks:              map[string]int{metricResultSuccess: 0, metricResultFailure: 0},
+		checkDurationCounts: make([]int, len(checkDurationBuckets)),
+		actions:             map[actionOutcome]int{},
+	}
+}
+
+// observeCheck counts a license check which took the given duration.
+func (m *Metrics) observeCheck(at time.Time, duration time.Duration, checkErr error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if checkErr != nil {
+		m.checks[metricResultFailure]++
+	} else {
+		m.checks[metricResultSuccess]++
+		m.lastSuccessfulCheck = at
+	}
+
+	seconds := duration.Seconds()
+	m.checkDurationSum += seconds
+	for i, bound := range checkDurationBuckets {
+		if seconds <= bound {
+			m.checkDurationCounts[i]++
+		}
+	}
+}
+
+// observeLicense remembers the expiry of the configured license. A nil license means that no license is configured
+// or that it cannot be decoded. The expiry gauge is omitted in this case.
+func (m *Metrics) observeLicense(license *atlassian.License, isSetupLicense bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.licenseExpiry = time.Time{}
+	if license != nil {
+		m.licenseExpiry = license.ExpiryDate
+	}
+	m.setupLicense = isSetupLicense
+}
+
+// observeAction counts an action invocation on the given event.
+func (m *Metrics) observeAction(action string, event EventType, actionErr error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	outcome := metricResultSuccess
+	if actionErr != nil {
+		outcome = metricResultFailure
+	}
+	m.actions[actionOutcome{action: action, event: event, outcome: outcome}]++
+}
+
+// Handler returns the HTTP handler which serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", metricsContentType)
+		_, err := writer.Write(m.format(time.Now()))
+		if err != nil {
+			log.Warningf("Failed to write metrics response: %s", err.Error())
+		}
+	})
+}
+
+// format renders the metrics in the Prometheus text format. The time until the license expires is calculated for
+// the given point in time.
+func (m *Metrics) format(now time.Time) []byte {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	out := &bytes.Buffer{}
+
+	writeMetricHeader(out, "license_checker_checks_total", "counter", "Number of license checks by result.")
+	for _, result := range []string{metricResultSuccess, metricResultFailure} {
+		writeSample(out, "license_checker_checks_total", labels("result", result), float64(m.checks[result]))
+	}
+
+	writeMetricHeader(out, "license_checker_check_duration_seconds", "histogram", "Duration of license checks.")
+	total := m.checks[metricResultSuccess] + m.checks[metricResultFailure]
+	for i, bound := range checkDurationBuckets {
+		writeSample(out, "license_checker_check_duration_seconds_bucket", labels("le", formatFloat(bound)), float64(m.checkDurationCounts[i]))
+	}
+	writeSample(out, "license_checker_check_duration_seconds_bucket", labels("le", "+Inf"), float64(total))
+	writeSample(out, "license_checker_check_duration_seconds_sum", "", m.checkDurationSum)
+	writeSample(out, "license_checker_check_duration_seconds_count", "", float64(total))
+
+	writeMetricHeader(out, "license_checker_last_successful_check_timestamp_seconds", "gauge",
+		"Unix time of the last successful license check. Zero if no check succeeded yet.")
+	lastSuccess := 0.0
+	if !m.lastSuccessfulCheck.IsZero() {
+		lastSuccess = float64(m.lastSuccessfulCheck.UnixMilli()) / 1000
+	}
+	writeSample(out, "license_checker_last_successful_check_timestamp_seconds", "", lastSuccess)
+
+	writeMetricHeader(out, "license_checker_action_invocations_total", "counter", "Number of action invocations by action, event and outcome.")
+	outcomes := make([]actionOutcome, 0, len(m.actions))
+	for outcome := range m.actions {
+		outcomes = append(outcomes, outcome)
+	}
+	sort.Slice(outcomes, func(i, j int) bool {
+		if outcomes[i].action != outcomes[j].action {
+			return outcomes[i].action < outcomes[j].action
+		}
+		if outcomes[i].event != outcomes[j].event {
+			return outcomes[i].event < outcomes[j].event
+		}
+		return outcomes[i].outcome < outcomes[j].outcome
+	})
+	for _, outcome := range outcomes {
+		sampleLabels := labels("action", outcome.action, "event", string(outcome.event), "outcome", outcome.outcome)
+		writeSample(out, "license_checker_action_invocations_total", sampleLabels, float64(m.actions[outcome]))
+	}
+
+	writeMetricHeader(out, "license_checker_setup_license", "gauge", "1 if the configured license is a setup license, 0 otherwise.")
+	setupLicense := 0.0
+	if m.setupLicense {
+		setupLicense = 1
+	}
+	writeSample(out, "license_checker_setup_license", "", setupLicense)
+
+	if !m.licenseExpiry.IsZero() {
+		writeMetricHeader(out, "license_checker_license_expiry_seconds", "gauge",
+			"Seconds until the configured license expires. Negative if it already expired.")
+		writeSample(out, "license_checker_license_expiry_seconds", "", m.licenseExpiry.Sub(now).Round(time.Second).Seconds())
+	}
+
+	return out.Bytes()
+}
+
+func writeMetricHeader(out *bytes.Buffer, name string, metricType string, help string) {
+	_, _ = fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func writeSample(out *bytes.Buffer, name string, sampleLabels string, value float64) {
+	_, _ = fmt.Fprintf(out, "%s%s %s\n", name, sampleLabels, formatFloat(value))
+}
+
+// labels renders pairs of label names and values, e.g. {result="success"}.
+func labels(namesAndValues ...string) string {
+	pairs := make([]string, 0, len(namesAndValues)/2)
+	for i := 0; i+1 < len(namesAndValues); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, namesAndValues[i], labelEscaper.Replace(namesAndValues[i+1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// measuredAction counts the invocations of an action.
+type measuredAction struct {
+	Action
+	metrics *Metrics
+}
+
+func (ma *measuredAction) Execute(ctx context.Context, event *Event) error {
+	err := ma.Action.Execute(ctx, event)
+	ma.metrics.observeAction(ma.Name(), event.Type, err)
+	return err
+}
+
+// dispatch executes the subscribed actions like the function dispatch and counts their invocations if metrics are
+// configured.
+func (dw *defaultWatcher) dispatch(ctx context.Context, subscriptions []Subscription, event *Event) (bool, error) {
+	if dw.args.Metrics == nil {
+		return dispatch(ctx, subscriptions, event)
+	}
+
+	measured := make([]Subscription, len(subscriptions))
+	for i, subscription := range subscriptions {
+		measured[i] = Subscription{Action: &measuredAction{Action: subscription.Action, metrics: dw.args.Metrics}, Events: subscription.Events}
+	}
+	return dispatch(ctx, measured, event)
+}
+
+// observeCheck counts a license check if metrics are configured.
+func (dw *defaultWatcher) observeCheck(start time.Time, license string, checkErr error) {
+	if dw.args.Metrics == nil {
+		return
+	}
+
+	dw.args.Metrics.observeCheck(start, time.Since(start), checkErr)
+	if checkErr == nil {
+		isSetupLicense := license != "" && dw.args.Detection.IsSetupLicense(license, dw.args.SetupLicense, start)
+		dw.args.Metrics.observeLicense(decodeOrNil(license), isSetupLicense)
+	}
+}
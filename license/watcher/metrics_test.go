@@ -0,0 +1,118 @@
+package watcher
+
+import (
+	"context"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetrics_format(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should render empty metrics", func(t *testing.T) {
+		actual := string(NewMetrics().format(now))
+
+		assert.Contains(t, actual, "# TYPE license_checker_checks_total counter\n")
+		assert.Contains(t, actual, "license_checker_checks_total{result=\"success\"} 0\n")
+		assert.Contains(t, actual, "license_checker_checks_total{result=\"failure\"} 0\n")
+		assert.Contains(t, actual, "license_checker_check_duration_seconds_bucket{le=\"+Inf\"} 0\n")
+		assert.Contains(t, actual, "license_checker_last_successful_check_timestamp_seconds 0\n")
+		assert.Contains(t, actual, "# TYPE license_checker_action_invocations_total counter\n")
+		assert.NotContains(t, actual, "license_checker_license_expiry_seconds")
+	})
+	t.Run("should render observations", func(t *testing.T) {
+		// given
+		sut := NewMetrics()
+		sut.observeCheck(now, 3*time.Millisecond, nil)
+		sut.observeCheck(now.Add(time.Minute), 2*time.Second, assert.AnError)
+		sut.observeAction("command", EventSetupToProduction, nil)
+		sut.observeAction("webhook", EventSetupToProduction, assert.AnError)
+		sut.observeAction("command", EventSetupToProduction, nil)
+		sut.observeLicense(&atlassian.License{ExpiryDate: now.Add(48 * time.Hour)}, true)
+
+		// when
+		actual := string(sut.format(now))
+
+		// then
+		assert.Contains(t, actual, "license_checker_checks_total{result=\"success\"} 1\n")
+		assert.Contains(t, actual, "license_checker_checks_total{result=\"failure\"} 1\n")
+		assert.Contains(t, actual, "license_checker_check_duration_seconds_bucket{le=\"0.001\"} 0\n")
+		assert.Contains(t, actual, "license_checker_check_duration_seconds_bucket{le=\"0.005\"} 1\n")
+		assert.Contains(t, actual, "license_checker_check_duration_seconds_bucket{le=\"5\"} 2\n")
+		assert.Contains(t, actual, "license_checker_check_duration_seconds_bucket{le=\"+Inf\"} 2\n")
+		assert.Contains(t, actual, "license_checker_check_duration_seconds_sum 2.003\n")
+		assert.Contains(t, actual, "license_checker_check_duration_seconds_count 2\n")
+		assert.Contains(t, actual, "license_checker_last_successful_check_timestamp_seconds 1.7923248e+09\n")
+		assert.Contains(t, actual, "license_checker_action_invocations_total{action=\"command\",event=\"setup-to-production\",outcome=\"success\"} 2\n")
+		assert.Contains(t, actual, "license_checker_action_invocations_total{action=\"webhook\",event=\"setup-to-production\",outcome=\"failure\"} 1\n")
+		assert.Contains(t, actual, "license_checker_setup_license 1\n")
+		assert.Contains(t, actual, "license_checker_license_expiry_seconds 172800\n")
+	})
+	t.Run("should omit expiry of a license without expiry", func(t *testing.T) {
+		sut := NewMetrics()
+		sut.observeLicense(&atlassian.License{ExpiryDate: now}, false)
+		sut.observeLicense(&atlassian.License{}, false)
+
+		actual := string(sut.format(now))
+
+		assert.NotContains(t, actual, "license_checker_license_expiry_seconds")
+	})
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+
+	NewMetrics().Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, metricsContentType, recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "license_checker_checks_total")
+}
+
+func Test_labels(t *testing.T) {
+	assert.Equal(t, `{a="1",b="say \"hi\"\n"}`, labels("a", "1", "b", "say \"hi\"\n"))
+}
+
+func Test_defaultWatcher_metrics(t *testing.T) {
+	const licFile = "/var/atlassian/confluence/confluence.cfg.xml"
+	commandArgs := []string{"/opt/atlassian/confluence/bin/shutdown.sh"}
+
+	t.Run("should count checks and action invocations", func(t *testing.T) {
+		// given
+		production, err := atlassian.Encode(map[string]string{"LicenseExpiryDate": "2099-01-01"})
+		require.NoError(t, err)
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return(production, nil)
+		mockedExecutor := new(executorMock)
+		mockedExecutor.On("execute", commandArgs).Return("", assert.AnError)
+		metrics := NewMetrics()
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				CommandArgs:          commandArgs,
+				ConfluenceConfigFile: licFile,
+				SetupLicense:         "setup",
+				Continuous:           true,
+				Metrics:              metrics,
+			},
+			cmdExecutor:   mockedExecutor,
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  "setup",
+		}
+
+		// when
+		_, err = sut.doWatchWork(context.Background())
+
+		// then
+		require.NoError(t, err)
+		actual := string(metrics.format(time.Now()))
+		assert.Contains(t, actual, "license_checker_checks_total{result=\"success\"} 1\n")
+		assert.Contains(t, actual, "license_checker_action_invocations_total{action=\"command\",event=\"setup-to-production\",outcome=\"failure\"} 1\n")
+		assert.Contains(t, actual, "license_checker_setup_license 0\n")
+		assert.Contains(t, actual, "license_checker_license_expiry_seconds ")
+	})
+}
@@ -0,0 +1,193 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cloudogu/confluence-license-checker/license/tester"
+	"github.com/pkg/errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// staleCheckIntervals is the number of watch intervals without a successful license check after which the watcher
+// counts as unhealthy.
+const staleCheckIntervals = 3
+
+// Monitor shares the state of a running watcher with the HTTP endpoints /healthz, /readyz and /status. It is safe
+// for concurrent use.
+type Monitor struct {
+	mutex      sync.RWMutex
+	status     Status
+	staleAfter time.Duration
+	createdAt  time.Time
+}
+
+// Status is the state of a running watcher as served by the /status endpoint.
+type Status struct {
+	State
+	// WaitingForSetup is true while the watcher waits for the Confluence setup to complete.
+	WaitingForSetup bool `json:"waitingForSetup"`
+	// ConfigParsed is true once the config file was parsed.
+	ConfigParsed bool `json:"configParsed"`
+	// LicenseDetails describes the watched license. It is nil if no license is configured or the last successful
+	// check saw no license. The redacted license is part of the embedded State.
+	LicenseDetails *LicenseStatus `json:"licenseDetails,omitempty"`
+	// Healthy is true if the last successful license check is recent enough.
+	Healthy bool `json:"healthy"`
+	// Ready is true once the config file was parsed.
+	Ready bool `json:"ready"`
+	// Problem describes why the watcher is unhealthy or not ready. It is empty otherwise.
+	Problem string `json:"problem,omitempty"`
+}
+
+// LicenseStatus describes the watched license.
+type LicenseStatus struct {
+	// Kind is either tester.StateSetupLicense or tester.StateProductionLicense.
+	Kind tester.State `json:"kind"`
+	// Current is false if the last license check failed. The details describe the license of the last successful
+	// check then, which may have changed since.
+	Current bool `json:"current"`
+	// Product is the licensed product. It is empty if the license cannot be decoded.
+	Product string `json:"product,omitempty"`
+	// LicenseType is the licence type, e.g. COMMERCIAL. It is empty if the license cannot be decoded.
+	LicenseType string `json:"licenseType,omitempty"`
+	// ExpiryDate is the date on which the license expires. It is empty if the license does not expire.
+	ExpiryDate string `json:"expiryDate,omitempty"`
+	// DaysLeft is the number of days until the license expires. It is nil if the license does not expire.
+	DaysLeft *int `json:"daysLeft,omitempty"`
+}
+
+// NewMonitor creates a monitor for a watcher with the given schedule. The watcher counts as unhealthy if no license
+// check succeeded within three of the longest intervals of the schedule.
+func NewMonitor(schedule Schedule) *Monitor {
+	return &Monitor{staleAfter: staleCheckIntervals * schedule.longestInterval(), createdAt: time.Now()}
+}
+
+// Status returns the current status of the watcher.
+func (m *Monitor) Status(now time.Time) Status {
+	status := m.snapshot()
+	readyErr := m.checkReady(status)
+	healthErr := m.checkHealth(status, now)
+	status.Ready = readyErr == nil
+	status.Healthy = healthErr == nil
+	switch {
+	case healthErr != nil:
+		status.Problem = healthErr.Error()
+	case readyErr != nil:
+		status.Problem = readyErr.Error()
+	}
+	return status
+}
+
+func (m *Monitor) snapshot() Status {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.status
+}
+
+func (m *Monitor) update(status Status) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.status = status
+}
+
+// checkHealth fails if the last successful license check is older than the stale period. Waiting for the Confluence
+// setup is healthy because no license is checked in the meantime.
+func (m *Monitor) checkHealth(status Status, now time.Time) error {
+	if status.WaitingForSetup {
+		return nil
+	}
+
+	since := m.createdAt
+	if status.StartedAt.After(since) {
+		since = status.StartedAt
+	}
+	if status.LastSuccessfulCheck.After(since) {
+		since = status.LastSuccessfulCheck
+	}
+
+	if age := now.Sub(since); age > m.staleAfter {
+		return errors.Errorf("no successful license check for %s, expected one within %s", age.Round(time.Second), m.staleAfter)
+	}
+	return nil
+}
+
+func (m *Monitor) checkReady(status Status) error {
+	if status.WaitingForSetup {
+		return errors.New("waiting for the Confluence setup to complete")
+	}
+	if !status.ConfigParsed {
+		return errors.New("config file was not parsed yet")
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler which serves the endpoints of the monitor.
+func (m *Monitor) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(writer http.ResponseWriter, _ *http.Request) {
+		writeProbe(writer, m.checkHealth(m.snapshot(), time.Now()))
+	})
+	mux.HandleFunc("GET /readyz", func(writer http.ResponseWriter, _ *http.Request) {
+		writeProbe(writer, m.checkReady(m.snapshot()))
+	})
+	mux.HandleFunc("GET /status", func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(m.Status(time.Now()))
+		if err != nil {
+			log.Warningf("Failed to write status response: %s", err.Error())
+		}
+	})
+	return mux
+}
+
+func writeProbe(writer http.ResponseWriter, problem error) {
+	writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if problem != nil {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprintln(writer, problem.Error())
+		return
+	}
+	_, _ = fmt.Fprintln(writer, "ok")
+}
+
+// publishStatus shares the state of the watcher with the monitor. Nothing is shared if no monitor is configured.
+func (dw *defaultWatcher) publishStatus() {
+	if dw.args.Monitor == nil {
+		return
+	}
+
+	dw.args.Monitor.update(Status{
+		State:           dw.state,
+		WaitingForSetup: dw.waitingForSetup,
+		ConfigParsed:    dw.configParsed,
+		LicenseDetails:  dw.licenseStatus(time.Now()),
+	})
+}
+
+func (dw *defaultWatcher) licenseStatus(now time.Time) *LicenseStatus {
+	if dw.knownLicense == "" || dw.licenseRemoved {
+		return nil
+	}
+
+	status := &LicenseStatus{Kind: tester.StateProductionLicense, Current: dw.failedChecks == 0}
+	if dw.args.Detection.IsSetupLicense(dw.knownLicense, dw.args.SetupLicense, now) {
+		status.Kind = tester.StateSetupLicense
+	}
+
+	decoded := decodeOrNil(dw.knownLicense)
+	if decoded == nil {
+		return status
+	}
+	status.Product = decoded.Product
+	status.LicenseType = decoded.LicenseType
+	if decoded.HasExpiry() {
+		daysLeft := decoded.DaysLeft(now)
+		status.ExpiryDate = decoded.ExpiryDate.Format(time.DateOnly)
+		status.DaysLeft = &daysLeft
+	}
+	return status
+}
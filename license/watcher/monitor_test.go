@@ -0,0 +1,262 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/cloudogu/confluence-license-checker/license/tester"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewMonitor(t *testing.T) {
+	t.Run("should become stale after three of the longest intervals", func(t *testing.T) {
+		actual := NewMonitor(Schedule{Interval: 10 * time.Second, MaxInterval: time.Minute, Jitter: 5 * time.Second})
+
+		assert.Equal(t, 195*time.Second, actual.staleAfter)
+	})
+	t.Run("should use default interval", func(t *testing.T) {
+		actual := NewMonitor(Schedule{})
+
+		assert.Equal(t, 90*time.Second, actual.staleAfter)
+	})
+}
+
+func TestMonitor_Status(t *testing.T) {
+	now := time.Now()
+
+	t.Run("should be healthy and ready after a recent successful check", func(t *testing.T) {
+		sut := &Monitor{staleAfter: time.Minute, createdAt: now.Add(-time.Hour)}
+		sut.update(Status{State: State{StartedAt: now.Add(-time.Hour), LastSuccessfulCheck: now.Add(-time.Second)}, ConfigParsed: true})
+
+		actual := sut.Status(now)
+
+		assert.True(t, actual.Healthy)
+		assert.True(t, actual.Ready)
+		assert.Empty(t, actual.Problem)
+	})
+	t.Run("should be unhealthy if the last successful check is too old", func(t *testing.T) {
+		sut := &Monitor{staleAfter: time.Minute, createdAt: now.Add(-time.Hour)}
+		sut.update(Status{State: State{StartedAt: now.Add(-time.Hour), LastSuccessfulCheck: now.Add(-2 * time.Minute)}, ConfigParsed: true})
+
+		actual := sut.Status(now)
+
+		assert.False(t, actual.Healthy)
+		assert.True(t, actual.Ready)
+		assert.Equal(t, "no successful license check for 2m0s, expected one within 1m0s", actual.Problem)
+	})
+	t.Run("should ignore successful checks of former runs", func(t *testing.T) {
+		sut := &Monitor{staleAfter: time.Minute, createdAt: now.Add(-time.Second)}
+		sut.update(Status{State: State{StartedAt: now.Add(-time.Second), LastSuccessfulCheck: now.Add(-24 * time.Hour)}})
+
+		actual := sut.Status(now)
+
+		assert.True(t, actual.Healthy)
+		assert.False(t, actual.Ready)
+		assert.Equal(t, "config file was not parsed yet", actual.Problem)
+	})
+	t.Run("should be healthy but not ready while waiting for the setup", func(t *testing.T) {
+		sut := &Monitor{staleAfter: time.Minute, createdAt: now.Add(-time.Hour)}
+		sut.update(Status{State: State{StartedAt: now.Add(-time.Hour)}, WaitingForSetup: true})
+
+		actual := sut.Status(now)
+
+		assert.True(t, actual.Healthy)
+		assert.False(t, actual.Ready)
+		assert.Equal(t, "waiting for the Confluence setup to complete", actual.Problem)
+	})
+}
+
+func TestMonitor_Handler(t *testing.T) {
+	healthy := &Monitor{staleAfter: time.Minute, createdAt: time.Now()}
+	healthy.update(Status{State: State{Checks: 2, LastSuccessfulCheck: time.Now()}, ConfigParsed: true})
+	stale := &Monitor{staleAfter: time.Minute, createdAt: time.Now().Add(-time.Hour)}
+
+	t.Run("should pass probes", func(t *testing.T) {
+		for _, path := range []string{"/healthz", "/readyz"} {
+			recorder := serveTestRequest(healthy, path)
+
+			assert.Equal(t, http.StatusOK, recorder.Code, path)
+			assert.Equal(t, "ok\n", recorder.Body.String(), path)
+		}
+	})
+	t.Run("should fail probes", func(t *testing.T) {
+		for _, path := range []string{"/healthz", "/readyz"} {
+			recorder := serveTestRequest(stale, path)
+
+			assert.Equal(t, http.StatusServiceUnavailable, recorder.Code, path)
+			assert.NotEmpty(t, recorder.Body.String(), path)
+		}
+	})
+	t.Run("should serve status as JSON", func(t *testing.T) {
+		recorder := serveTestRequest(healthy, "/status")
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+		actual := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &actual))
+		assert.Equal(t, float64(2), actual["checks"])
+		assert.Equal(t, true, actual["healthy"])
+		assert.Equal(t, true, actual["ready"])
+	})
+	t.Run("should not serve unknown paths", func(t *testing.T) {
+		recorder := serveTestRequest(healthy, "/unknown")
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
+
+func Test_defaultWatcher_publishStatus(t *testing.T) {
+	const licFile = "/var/atlassian/confluence/confluence.cfg.xml"
+
+	t.Run("should publish each check with the watched license", func(t *testing.T) {
+		// given
+		production, err := atlassian.Encode(map[string]string{"conf.active": "true", "conf.LicenseTypeName": "COMMERCIAL", "LicenseExpiryDate": "2099-01-01"})
+		require.NoError(t, err)
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return(production, nil)
+		monitor := NewMonitor(Schedule{})
+		sut := &defaultWatcher{
+			args:          &ProcessArgs{ConfluenceConfigFile: licFile, Continuous: true, Monitor: monitor},
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  production,
+		}
+
+		// when
+		_, err = sut.doWatchWork(context.Background())
+
+		// then
+		require.NoError(t, err)
+		actual := monitor.Status(time.Now())
+		assert.Equal(t, 1, actual.Checks)
+		assert.True(t, actual.ConfigParsed)
+		assert.True(t, actual.Ready)
+		require.NotNil(t, actual.LicenseDetails)
+		assert.Equal(t, tester.StateProductionLicense, actual.LicenseDetails.Kind)
+		assert.True(t, actual.LicenseDetails.Current)
+		assert.Equal(t, "confluence", actual.LicenseDetails.Product)
+		assert.Equal(t, "COMMERCIAL", actual.LicenseDetails.LicenseType)
+		assert.Equal(t, "2099-01-01", actual.LicenseDetails.ExpiryDate)
+		assert.NotNil(t, actual.LicenseDetails.DaysLeft)
+		served := serveTestRequest(monitor, "/status")
+		var document map[string]any
+		require.NoError(t, json.Unmarshal(served.Body.Bytes(), &document))
+		assert.Equal(t, atlassian.Redact(production), document["license"])
+		require.IsType(t, map[string]any{}, document["licenseDetails"])
+		assert.Equal(t, string(tester.StateProductionLicense), document["licenseDetails"].(map[string]any)["kind"])
+	})
+	t.Run("should mark the license details as not current after a failed check", func(t *testing.T) {
+		// given
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("", errors.New("config file is being rewritten"))
+		monitor := NewMonitor(Schedule{})
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: licFile,
+				Continuous:           true,
+				Monitor:              monitor,
+				ErrorPolicy:          ErrorPolicy{GiveUpAfter: 3, Backoff: Backoff{Initial: time.Second}},
+			},
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  "AAAB",
+		}
+
+		// when
+		_, err := sut.doWatchWork(context.Background())
+
+		// then
+		require.NoError(t, err)
+		actual := monitor.Status(time.Now())
+		require.NotNil(t, actual.LicenseDetails)
+		assert.False(t, actual.LicenseDetails.Current)
+	})
+	t.Run("should clear the license details after the license was removed", func(t *testing.T) {
+		// given
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("", readMissingLicense(t))
+		monitor := NewMonitor(Schedule{})
+		sut := &defaultWatcher{
+			args:          &ProcessArgs{ConfluenceConfigFile: licFile, Continuous: true, Monitor: monitor},
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  "AAAB",
+		}
+
+		// when
+		_, err := sut.doWatchWork(context.Background())
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, sut.licenseRemoved)
+		assert.Nil(t, monitor.Status(time.Now()).LicenseDetails)
+	})
+	t.Run("should not count a missing config file as parsed", func(t *testing.T) {
+		// given
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("", readMissingConfigFile(t))
+		monitor := NewMonitor(Schedule{})
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: licFile,
+				Continuous:           true,
+				Monitor:              monitor,
+				ErrorPolicy:          ErrorPolicy{GiveUpAfter: 3, Backoff: Backoff{Initial: time.Second}},
+			},
+			licenseTester: mockedLicenseChecker,
+		}
+
+		// when
+		_, err := sut.doWatchWork(context.Background())
+
+		// then
+		require.NoError(t, err)
+		actual := monitor.Status(time.Now())
+		assert.False(t, actual.Ready)
+		assert.Nil(t, actual.LicenseDetails)
+	})
+}
+
+func Test_defaultWatcher_Watch_liveness(t *testing.T) {
+	const licFile = "/var/atlassian/confluence/confluence.cfg.xml"
+
+	t.Run("should check regularly without trigger events while monitored", func(t *testing.T) {
+		// given
+		schedule := Schedule{Interval: 10 * time.Millisecond}
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("setup", nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: licFile,
+				SetupLicense:         "setup",
+				Schedule:             schedule,
+				Monitor:              NewMonitor(schedule),
+			},
+			licenseTester: mockedLicenseChecker,
+			createTrigger: func(string, string, Schedule) (trigger, error) {
+				return &fakeTrigger{c: make(chan struct{})}, nil
+			},
+		}
+
+		// when
+		err := sut.Watch(ctx)
+
+		// then
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Greater(t, sut.state.Checks, 2)
+		assert.True(t, sut.args.Monitor.Status(time.Now()).Ready)
+	})
+}
+
+// test util stuff
+
+func serveTestRequest(monitor *Monitor, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	monitor.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder
+}
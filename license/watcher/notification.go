@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Notification describes a license event without revealing the licenses. It is the JSON document which the webhook
+// action posts and the data of webhook and mail templates.
+type Notification struct {
+	// Event is the type of the license event.
+	Event EventType `json:"event"`
+	// Time is the point in time when the event was detected.
+	Time time.Time `json:"time"`
+	// Host is the name of the host on which the watcher runs.
+	Host string `json:"host"`
+	// ConfigFile is the config file in which the event was detected.
+	ConfigFile string `json:"configFile"`
+	// OldLicense summarizes the license before the event. It is nil if the license was missing.
+	OldLicense *LicenseSummary `json:"oldLicense,omitempty"`
+	// NewLicense summarizes the license after the event. It is nil if the license was removed.
+	NewLicense *LicenseSummary `json:"newLicense,omitempty"`
+	// Error describes why the watcher gave up. It is only set on the event watch-failed.
+	Error string `json:"error,omitempty"`
+}
+
+// LicenseSummary describes a license without revealing it.
+type LicenseSummary struct {
+	// SHA256 is the fingerprint of the license.
+	SHA256 string `json:"sha256"`
+	// Expiry is the expiry date of the license, e.g. 2027-03-31. It is empty if the license does not expire or
+	// cannot be decoded.
+	Expiry string `json:"expiry,omitempty"`
+	// DaysLeft is the number of started days from the event until the license expires. It is nil if the license does
+	// not expire or cannot be decoded.
+	DaysLeft *int `json:"daysLeft,omitempty"`
+}
+
+func newNotification(event *Event, host string) *Notification {
+	data := newCommandData(event)
+	notification := &Notification{Event: event.Type, Time: event.Time, Host: host, ConfigFile: event.ConfigFile,
+		Error: event.Error}
+	if event.OldLicense != "" {
+		notification.OldLicense = &LicenseSummary{SHA256: data.OldSHA256, Expiry: data.OldExpiry}
+		if event.Old != nil && event.Old.HasExpiry() {
+			daysLeft := event.Old.DaysLeft(event.Time)
+			notification.OldLicense.DaysLeft = &daysLeft
+		}
+	}
+	if event.NewLicense != "" {
+		notification.NewLicense = &LicenseSummary{SHA256: data.NewSHA256, Expiry: data.NewExpiry}
+		if event.New != nil && event.New.HasExpiry() {
+			daysLeft := event.New.DaysLeft(event.Time)
+			notification.NewLicense.DaysLeft = &daysLeft
+		}
+	}
+	return notification
+}
+
+// hostname returns the name of the host for notifications. It is empty if the name cannot be determined.
+func hostname() string {
+	host, err := os.Hostname()
+	if err != nil {
+		log.Warningf("Failed to determine host name for notifications: %s", err.Error())
+	}
+	return host
+}
+
+func toJSON(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
@@ -0,0 +1,155 @@
+package watcher
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	streamStdout = "stdout"
+	streamStderr = "stderr"
+
+	// DefaultTailLines is the number of lines per stream which are kept if no other number is configured.
+	DefaultTailLines = 20
+	// maxLineLength splits overly long lines so that the memory used per stream stays bounded.
+	maxLineLength = 4096
+)
+
+// OutputOptions configures what happens with the output of the command.
+type OutputOptions struct {
+	// TailLines is the number of last lines per stream that are kept and attached to the error of a failed command.
+	// If zero, DefaultTailLines is used.
+	TailLines int
+	// LogFile is a file to which all output lines are appended. No file is written if empty.
+	LogFile string
+}
+
+func (oo OutputOptions) tailLines() int {
+	if oo.TailLines <= 0 {
+		return DefaultTailLines
+	}
+	return oo.TailLines
+}
+
+// lineTail keeps the last lines written to it.
+type lineTail struct {
+	lines []string
+	max   int
+}
+
+func newLineTail(max int) *lineTail {
+	return &lineTail{max: max}
+}
+
+func (lt *lineTail) add(line string) {
+	lt.lines = append(lt.lines, line)
+	if len(lt.lines) > lt.max {
+		lt.lines = lt.lines[len(lt.lines)-lt.max:]
+	}
+}
+
+func (lt *lineTail) String() string {
+	return strings.Join(lt.lines, "\n")
+}
+
+// streamWriter splits the output of a command stream into lines. Each line is logged with the name of the stream,
+// kept in a bounded tail and written to an optional sink.
+type streamWriter struct {
+	command string
+	stream  string
+	partial []byte
+	tail    *lineTail
+	sink    *outputSink
+}
+
+func newStreamWriter(command string, stream string, tailLines int, sink *outputSink) *streamWriter {
+	return &streamWriter{command: command, stream: stream, tail: newLineTail(tailLines), sink: sink}
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	sw.partial = append(sw.partial, p...)
+	for {
+		index := bytes.IndexByte(sw.partial, '\n')
+		if index < 0 {
+			break
+		}
+		sw.emit(string(sw.partial[:index]))
+		sw.partial = sw.partial[index+1:]
+	}
+
+	for len(sw.partial) > maxLineLength {
+		sw.emit(string(sw.partial[:maxLineLength]))
+		sw.partial = sw.partial[maxLineLength:]
+	}
+
+	return len(p), nil
+}
+
+// flush emits the last line if it does not end with a line break.
+func (sw *streamWriter) flush() {
+	if len(sw.partial) > 0 {
+		sw.emit(string(sw.partial))
+		sw.partial = nil
+	}
+}
+
+func (sw *streamWriter) emit(line string) {
+	line = strings.TrimSuffix(line, "\r")
+	log.Infof("%s [%s] %s", sw.command, sw.stream, line)
+	sw.tail.add(line)
+	sw.sink.writeLine(sw.stream, line)
+}
+
+// outputSink appends output lines of both streams to a file. A nil sink discards all lines.
+type outputSink struct {
+	mutex  sync.Mutex
+	writer io.WriteCloser
+}
+
+func openOutputSink(logFile string) (*outputSink, error) {
+	if logFile == "" {
+		return nil, nil
+	}
+
+	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open command output log file '%s'", logFile)
+	}
+
+	return &outputSink{writer: file}, nil
+}
+
+func (sink *outputSink) writeLine(stream string, line string) {
+	if sink == nil {
+		return
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	_, err := fmt.Fprintf(sink.writer, "%s [%s] %s\n", time.Now().Format(time.RFC3339), stream, line)
+	if err != nil {
+		log.Warningf("Failed to write command output to log file: %s", err.Error())
+	}
+}
+
+func (sink *outputSink) close() {
+	if sink == nil {
+		return
+	}
+
+	err := sink.writer.Close()
+	if err != nil {
+		log.Warningf("Failed to close command output log file: %s", err.Error())
+	}
+}
+
+// formatTails formats the tails of both streams for an error message.
+func formatTails(stdout *streamWriter, stderr *streamWriter) string {
+	return fmt.Sprintf("\n--- last lines of stdout ---\n%s\n--- last lines of stderr ---\n%s", stdout.tail, stderr.tail)
+}
@@ -0,0 +1,70 @@
+package watcher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_streamWriter(t *testing.T) {
+	t.Run("should split written chunks into lines", func(t *testing.T) {
+		sut := newStreamWriter("restart.sh", streamStdout, 10, nil)
+
+		_, _ = sut.Write([]byte("hello "))
+		_, _ = sut.Write([]byte("world\nsecond line\r\nthird"))
+		sut.flush()
+
+		assert.Equal(t, []string{"hello world", "second line", "third"}, sut.tail.lines)
+	})
+	t.Run("should keep only the last lines", func(t *testing.T) {
+		sut := newStreamWriter("restart.sh", streamStderr, 2, nil)
+
+		_, _ = sut.Write([]byte("1\n2\n3\n4\n"))
+
+		assert.Equal(t, "3\n4", sut.tail.String())
+	})
+	t.Run("should split overly long lines", func(t *testing.T) {
+		sut := newStreamWriter("restart.sh", streamStdout, 10, nil)
+
+		_, _ = sut.Write([]byte(strings.Repeat("a", maxLineLength+10)))
+		sut.flush()
+
+		require.Len(t, sut.tail.lines, 2)
+		assert.Len(t, sut.tail.lines[0], maxLineLength)
+		assert.Len(t, sut.tail.lines[1], 10)
+	})
+}
+
+func Test_openOutputSink(t *testing.T) {
+	t.Run("should return no sink without log file", func(t *testing.T) {
+		actual, err := openOutputSink("")
+
+		require.NoError(t, err)
+		assert.Nil(t, actual)
+		actual.writeLine(streamStdout, "discarded")
+		actual.close()
+	})
+	t.Run("should append lines to the log file", func(t *testing.T) {
+		logFile := filepath.Join(t.TempDir(), "command.log")
+		require.NoError(t, os.WriteFile(logFile, []byte("previous run\n"), 0640))
+
+		sut, err := openOutputSink(logFile)
+		require.NoError(t, err)
+		sut.writeLine(streamStderr, "failed")
+		sut.close()
+
+		content, err := os.ReadFile(logFile)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(content), "previous run\n"))
+		assert.True(t, strings.HasSuffix(string(content), " [stderr] failed\n"))
+	})
+	t.Run("should fail if the log file cannot be opened", func(t *testing.T) {
+		_, err := openOutputSink(filepath.Join(t.TempDir(), "missing", "command.log"))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to open command output log file")
+	})
+}
@@ -0,0 +1,97 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"github.com/cloudogu/confluence-license-checker/license/config"
+	"github.com/pkg/errors"
+	"time"
+)
+
+const (
+	// defaultReadinessInterval is the interval in which the config file is read while waiting for readiness.
+	defaultReadinessInterval = 2 * time.Second
+	// readinessProgressInterval is the interval in which the watcher logs that it is still waiting.
+	readinessProgressInterval = time.Minute
+)
+
+// Readiness configures the phase in which the watcher waits for Confluence to be set up before it starts comparing
+// licenses. Confluence is ready when its config file exists, can be parsed and its setup step is
+// config.SetupStepComplete.
+type Readiness struct {
+	// Wait enables the waiting phase. The watcher starts comparing licenses right away if false.
+	Wait bool
+	// Timeout limits the time the watcher waits. Zero means waiting until the watcher is stopped.
+	Timeout time.Duration
+	// Interval is the interval in which the config file is read. Defaults to two seconds.
+	Interval time.Duration
+}
+
+func (r Readiness) interval() time.Duration {
+	if r.Interval <= 0 {
+		return defaultReadinessInterval
+	}
+	return r.Interval
+}
+
+// waitUntilReady blocks until Confluence is ready, the timeout expired or the context is done. Each change of the
+// reason why Confluence is not ready yet is logged.
+func (dw *defaultWatcher) waitUntilReady(ctx context.Context) error {
+	readiness := dw.args.Readiness
+	waitCtx := ctx
+	if readiness.Timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, readiness.Timeout)
+		defer cancel()
+	}
+
+	log.Infof("Waiting for the Confluence setup in '%s' to complete", dw.args.ConfluenceConfigFile)
+	dw.waitingForSetup = true
+	dw.publishStatus()
+	defer func() {
+		dw.waitingForSetup = false
+		dw.publishStatus()
+	}()
+	start := time.Now()
+	ticker := time.NewTicker(readiness.interval())
+	defer ticker.Stop()
+	progress := time.NewTicker(readinessProgressInterval)
+	defer progress.Stop()
+
+	lastReason := ""
+	for {
+		reason := dw.notReadyReason()
+		if reason == "" {
+			dw.configParsed = true
+			log.Infof("Confluence setup is complete after %s", time.Since(start).Round(time.Second))
+			return nil
+		}
+		if reason != lastReason {
+			log.Infof("Confluence is not ready yet: %s", reason)
+			lastReason = reason
+		}
+
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return errors.Wrap(ctx.Err(), "stopped waiting for Confluence to be ready")
+			}
+			return errors.Errorf("Confluence was not ready within %s: %s", readiness.Timeout, reason)
+		case <-progress.C:
+			log.Infof("Still waiting for Confluence since %s: %s", time.Since(start).Round(time.Second), reason)
+		case <-ticker.C:
+		}
+	}
+}
+
+// notReadyReason returns why Confluence is not ready yet. It is empty if Confluence is ready.
+func (dw *defaultWatcher) notReadyReason() string {
+	setupStep, err := dw.licenseTester.ReadSetupStep(dw.args.ConfluenceConfigFile)
+	if err != nil {
+		return err.Error()
+	}
+	if setupStep != config.SetupStepComplete {
+		return fmt.Sprintf("setup step is '%s' instead of '%s'", setupStep, config.SetupStepComplete)
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package watcher
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_defaultWatcher_waitUntilReady(t *testing.T) {
+	const licFile = "/var/atlassian/confluence/confluence.cfg.xml"
+
+	t.Run("should wait until the config file exists and the setup is complete", func(t *testing.T) {
+		// given
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadSetupStep", licFile).Return("", assert.AnError).Once()
+		mockedLicenseChecker.On("ReadSetupStep", licFile).Return("setupdbtype", nil).Twice()
+		mockedLicenseChecker.On("ReadSetupStep", licFile).Return("complete", nil).Once()
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: licFile,
+				Readiness:            Readiness{Wait: true, Interval: time.Millisecond},
+			},
+			licenseTester: mockedLicenseChecker,
+		}
+
+		// when
+		err := sut.waitUntilReady(context.Background())
+
+		// then
+		require.NoError(t, err)
+		mockedLicenseChecker.AssertExpectations(t)
+	})
+	t.Run("should fail with the reason when the timeout expires", func(t *testing.T) {
+		// given
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadSetupStep", licFile).Return("setupdbtype", nil)
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: licFile,
+				Readiness:            Readiness{Wait: true, Timeout: 20 * time.Millisecond, Interval: time.Millisecond},
+			},
+			licenseTester: mockedLicenseChecker,
+		}
+
+		// when
+		err := sut.waitUntilReady(context.Background())
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Confluence was not ready within 20ms: setup step is 'setupdbtype' instead of 'complete'")
+	})
+	t.Run("should stop waiting when the context is done", func(t *testing.T) {
+		// given
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadSetupStep", licFile).Return("", assert.AnError)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		sut := &defaultWatcher{
+			args:          &ProcessArgs{ConfluenceConfigFile: licFile, Readiness: Readiness{Wait: true, Timeout: time.Hour}},
+			licenseTester: mockedLicenseChecker,
+		}
+
+		// when
+		err := sut.waitUntilReady(ctx)
+
+		// then
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
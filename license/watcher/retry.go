@@ -0,0 +1,148 @@
+package watcher
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"time"
+)
+
+const (
+	defaultBackoffInitial    = time.Second
+	defaultBackoffMax        = time.Minute
+	defaultBackoffMultiplier = 2
+)
+
+// RetryPolicy configures how often and how long the command is executed.
+type RetryPolicy struct {
+	// Timeout is the time a single attempt may take. The attempt is terminated afterwards. Zero means no timeout.
+	Timeout time.Duration
+	// Retries is the number of further attempts after a failed attempt.
+	Retries int
+	// Backoff configures the waiting time between attempts.
+	Backoff Backoff
+	// SuccessExitCodes are the exit codes which count as success. If empty, only 0 counts as success.
+	SuccessExitCodes []int
+}
+
+// Backoff is an exponential backoff policy.
+type Backoff struct {
+	// Initial is the waiting time after the first failed attempt. Defaults to one second.
+	Initial time.Duration
+	// Max limits the waiting time between two attempts. Defaults to one minute.
+	Max time.Duration
+	// Multiplier increases the waiting time after each failed attempt. Defaults to 2.
+	Multiplier float64
+}
+
+// Delay returns the waiting time after the given number of failed attempts, starting with 1.
+func (b Backoff) Delay(failedAttempts int) time.Duration {
+	initial, maxDelay, multiplier := b.Initial, b.Max, b.Multiplier
+	if initial <= 0 {
+		initial = defaultBackoffInitial
+	}
+	if maxDelay <= 0 {
+		maxDelay = defaultBackoffMax
+	}
+	if multiplier < 1 {
+		multiplier = defaultBackoffMultiplier
+	}
+
+	delay := float64(initial)
+	for i := 1; i < failedAttempts && delay < float64(maxDelay); i++ {
+		delay *= multiplier
+	}
+
+	if delay > float64(maxDelay) {
+		return maxDelay
+	}
+	return time.Duration(delay)
+}
+
+func (rp RetryPolicy) isSuccess(exitCode int) bool {
+	if len(rp.SuccessExitCodes) == 0 {
+		return exitCode == 0
+	}
+
+	for _, successCode := range rp.SuccessExitCodes {
+		if successCode == exitCode {
+			return true
+		}
+	}
+	return false
+}
+
+// maxDuration returns the longest time all attempts and the waiting times between them may take. Zero means that
+// there is no limit because the attempts have no timeout.
+func (rp RetryPolicy) maxDuration() time.Duration {
+	if rp.Timeout <= 0 {
+		return 0
+	}
+
+	total := time.Duration(rp.Retries+1) * rp.Timeout
+	for failedAttempts := 1; failedAttempts <= rp.Retries; failedAttempts++ {
+		total += rp.Backoff.Delay(failedAttempts)
+	}
+	return total
+}
+
+// permanentError marks an error after which a further attempt would fail the same way.
+type permanentError struct {
+	cause error
+}
+
+func (pe *permanentError) Error() string {
+	return pe.cause.Error()
+}
+
+func (pe *permanentError) Cause() error {
+	return pe.cause
+}
+
+func (pe *permanentError) Unwrap() error {
+	return pe.cause
+}
+
+// retry calls the attempt function until it succeeds, returns a permanentError or all retries of the policy are used
+// up. Each attempt gets its own context limited by the timeout of the policy. The error of a single attempt without
+// retries is returned as is.
+func retry(ctx context.Context, policy RetryPolicy, description string, attemptFn func(ctx context.Context) error) error {
+	attempts := policy.Retries + 1
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = callWithTimeout(ctx, policy.Timeout, attemptFn)
+		if err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return errors.Wrapf(permanent.cause, "%s failed", description)
+		}
+		if attempt == attempts || ctx.Err() != nil {
+			break
+		}
+
+		delay := policy.Backoff.Delay(attempt)
+		log.Infof("Attempt %d/%d: %s failed, retrying in %s: %s", attempt, attempts, description, delay, err.Error())
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return errors.Wrapf(err, "%s was aborted before the next retry", description)
+		}
+	}
+
+	if attempts == 1 {
+		return err
+	}
+	return errors.Wrapf(err, "%s failed after %d attempts", description, attempts)
+}
+
+func callWithTimeout(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
@@ -0,0 +1,100 @@
+package watcher
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	t.Run("should grow exponentially up to the maximum", func(t *testing.T) {
+		sut := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 3}
+
+		assert.Equal(t, 100*time.Millisecond, sut.Delay(1))
+		assert.Equal(t, 300*time.Millisecond, sut.Delay(2))
+		assert.Equal(t, 900*time.Millisecond, sut.Delay(3))
+		assert.Equal(t, time.Second, sut.Delay(4))
+		assert.Equal(t, time.Second, sut.Delay(100))
+	})
+	t.Run("should use defaults", func(t *testing.T) {
+		sut := Backoff{}
+
+		assert.Equal(t, time.Second, sut.Delay(1))
+		assert.Equal(t, 2*time.Second, sut.Delay(2))
+		assert.Equal(t, time.Minute, sut.Delay(10))
+	})
+}
+
+func TestRetryPolicy_isSuccess(t *testing.T) {
+	assert.True(t, RetryPolicy{}.isSuccess(0))
+	assert.False(t, RetryPolicy{}.isSuccess(1))
+
+	sut := RetryPolicy{SuccessExitCodes: []int{0, 3}}
+	assert.True(t, sut.isSuccess(0))
+	assert.True(t, sut.isSuccess(3))
+	assert.False(t, sut.isSuccess(1))
+}
+
+func TestRetryPolicy_maxDuration(t *testing.T) {
+	assert.Equal(t, time.Duration(0), RetryPolicy{Retries: 3}.maxDuration())
+	assert.Equal(t, 10*time.Second, RetryPolicy{Timeout: 10 * time.Second}.maxDuration())
+
+	sut := RetryPolicy{Timeout: 10 * time.Second, Retries: 2, Backoff: Backoff{Initial: time.Second}}
+	assert.Equal(t, 33*time.Second, sut.maxDuration())
+}
+
+func Test_retry(t *testing.T) {
+	policy := RetryPolicy{Retries: 2, Backoff: Backoff{Initial: time.Millisecond}}
+
+	t.Run("should retry until the attempt succeeds", func(t *testing.T) {
+		calls := 0
+
+		err := retry(context.Background(), policy, "sending", func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return assert.AnError
+			}
+			return nil
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+	t.Run("should fail after all retries", func(t *testing.T) {
+		err := retry(context.Background(), policy, "sending", func(ctx context.Context) error {
+			return assert.AnError
+		})
+
+		require.ErrorIs(t, err, assert.AnError)
+		assert.Contains(t, err.Error(), "sending failed after 3 attempts")
+	})
+	t.Run("should return the error of a single attempt as is", func(t *testing.T) {
+		err := retry(context.Background(), RetryPolicy{}, "sending", func(ctx context.Context) error {
+			return assert.AnError
+		})
+
+		assert.Equal(t, assert.AnError, err)
+	})
+	t.Run("should not retry permanent errors", func(t *testing.T) {
+		calls := 0
+
+		err := retry(context.Background(), policy, "sending", func(ctx context.Context) error {
+			calls++
+			return &permanentError{cause: assert.AnError}
+		})
+
+		require.ErrorIs(t, err, assert.AnError)
+		assert.Contains(t, err.Error(), "sending failed: ")
+		assert.Equal(t, 1, calls)
+	})
+	t.Run("should limit each attempt by the timeout", func(t *testing.T) {
+		err := retry(context.Background(), RetryPolicy{Timeout: 10 * time.Millisecond}, "sending", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
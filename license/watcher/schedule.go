@@ -0,0 +1,119 @@
+package watcher
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultWatchInterval is the interval of the polling backend if no interval is given.
+	DefaultWatchInterval = 30 * time.Second
+	// adaptiveIntervalMultiplier increases the interval after each check in which the config file did not change.
+	adaptiveIntervalMultiplier = 1.5
+)
+
+// Schedule configures in which intervals the polling backend checks the config file. The first check is always done
+// right after the watcher started, regardless of the backend.
+type Schedule struct {
+	// Interval is the waiting time between two checks. Defaults to DefaultWatchInterval.
+	Interval time.Duration
+	// MaxInterval enables an adaptive interval if it is greater than Interval: each check in which the config file
+	// did not change increases the waiting time up to MaxInterval. A change of the config file resets it to Interval.
+	MaxInterval time.Duration
+	// Jitter is the upper limit of a random duration which is added to each waiting time, so that many watchers on
+	// a shared file system do not check at the same time. No jitter is added if zero.
+	Jitter time.Duration
+}
+
+// ParseInterval parses a duration like 500ms or 2m. A plain number is read as seconds.
+func ParseInterval(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.Errorf("invalid interval '%s': use a duration like 30s or a number of seconds", value)
+	}
+	return interval, nil
+}
+
+// Validate checks that the schedule has a positive interval and no negative durations.
+func (s Schedule) Validate() error {
+	if s.Interval <= 0 {
+		return errors.New("watch interval must be greater than zero")
+	}
+	if s.MaxInterval < 0 || s.Jitter < 0 {
+		return errors.New("maximum watch interval and jitter must not be negative")
+	}
+	if s.MaxInterval > 0 && s.MaxInterval < s.Interval {
+		return errors.Errorf("maximum watch interval %s must not be less than the watch interval %s", s.MaxInterval, s.Interval)
+	}
+	return nil
+}
+
+func (s Schedule) String() string {
+	description := s.interval().String()
+	if s.isAdaptive() {
+		description += " up to " + s.MaxInterval.String()
+	}
+	if s.Jitter > 0 {
+		description += fmt.Sprintf(" with a jitter of %s", s.Jitter)
+	}
+	return description
+}
+
+func (s Schedule) interval() time.Duration {
+	if s.Interval <= 0 {
+		return DefaultWatchInterval
+	}
+	return s.Interval
+}
+
+func (s Schedule) isAdaptive() bool {
+	return s.MaxInterval > s.interval()
+}
+
+// longestInterval returns the longest waiting time between two checks.
+func (s Schedule) longestInterval() time.Duration {
+	longest := s.interval()
+	if s.isAdaptive() {
+		longest = s.MaxInterval
+	}
+	return longest + s.Jitter
+}
+
+// next returns the waiting time until the next check after the given number of checks in a row in which the config
+// file did not change.
+func (s Schedule) next(unchangedChecks int) time.Duration {
+	interval := s.interval()
+	if s.isAdaptive() {
+		adaptive := Backoff{Initial: interval, Max: s.MaxInterval, Multiplier: adaptiveIntervalMultiplier}
+		interval = adaptive.Delay(unchangedChecks + 1)
+	}
+	if s.Jitter > 0 {
+		interval += rand.N(s.Jitter)
+	}
+	return interval
+}
+
+// fileState identifies a version of the config file for the adaptive interval.
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, modTime: info.ModTime(), size: info.Size()}
+}
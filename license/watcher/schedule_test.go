@@ -0,0 +1,78 @@
+package watcher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	t.Run("should parse durations and seconds", func(t *testing.T) {
+		for value, expected := range map[string]time.Duration{"500ms": 500 * time.Millisecond, "2m": 2 * time.Minute, "30": 30 * time.Second} {
+			actual, err := ParseInterval(value)
+
+			require.NoError(t, err, value)
+			assert.Equal(t, expected, actual, value)
+		}
+	})
+	t.Run("should fail on invalid interval", func(t *testing.T) {
+		_, err := ParseInterval("often")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid interval 'often'")
+	})
+}
+
+func TestSchedule_Validate(t *testing.T) {
+	assert.NoError(t, Schedule{Interval: time.Second}.Validate())
+	assert.NoError(t, Schedule{Interval: time.Second, MaxInterval: time.Minute, Jitter: time.Second}.Validate())
+	assert.Error(t, Schedule{}.Validate())
+	assert.Error(t, Schedule{Interval: time.Second, Jitter: -time.Second}.Validate())
+	assert.Error(t, Schedule{Interval: time.Minute, MaxInterval: time.Second}.Validate())
+}
+
+func TestSchedule_String(t *testing.T) {
+	assert.Equal(t, "30s", Schedule{}.String())
+	assert.Equal(t, "10s up to 5m0s with a jitter of 2s", Schedule{Interval: 10 * time.Second, MaxInterval: 5 * time.Minute, Jitter: 2 * time.Second}.String())
+}
+
+func TestSchedule_next(t *testing.T) {
+	t.Run("should use fixed interval", func(t *testing.T) {
+		sut := Schedule{Interval: 10 * time.Second}
+
+		assert.Equal(t, 10*time.Second, sut.next(0))
+		assert.Equal(t, 10*time.Second, sut.next(5))
+	})
+	t.Run("should slow down while nothing changes", func(t *testing.T) {
+		sut := Schedule{Interval: 10 * time.Second, MaxInterval: 20 * time.Second}
+
+		assert.Equal(t, 10*time.Second, sut.next(0))
+		assert.Equal(t, 15*time.Second, sut.next(1))
+		assert.Equal(t, 20*time.Second, sut.next(2))
+		assert.Equal(t, 20*time.Second, sut.next(10))
+	})
+	t.Run("should add jitter", func(t *testing.T) {
+		sut := Schedule{Interval: 10 * time.Second, Jitter: time.Second}
+
+		for i := 0; i < 100; i++ {
+			actual := sut.next(0)
+
+			assert.GreaterOrEqual(t, actual, 10*time.Second)
+			assert.Less(t, actual, 11*time.Second)
+		}
+	})
+}
+
+func Test_statFile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "confluence.cfg.xml")
+	assert.Equal(t, fileState{}, statFile(configFile))
+
+	require.NoError(t, os.WriteFile(configFile, []byte("<confluence-configuration/>"), 0600))
+	actual := statFile(configFile)
+
+	assert.True(t, actual.exists)
+	assert.Equal(t, int64(27), actual.size)
+}
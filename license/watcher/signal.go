@@ -0,0 +1,311 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultSignalSequence terminates a process gracefully and kills it if it does not exit in time.
+const DefaultSignalSequence = "SIGTERM:60s,SIGKILL:10s"
+
+// processPollInterval is the interval in which the signal action checks whether the signalled processes exited.
+const processPollInterval = 100 * time.Millisecond
+
+var signalNames = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+// SignalStep sends a signal and waits for the processes to exit.
+type SignalStep struct {
+	// Signal is sent to all processes which did not exit yet.
+	Signal syscall.Signal
+	// Wait is the time the processes may take to exit before the next step is executed.
+	Wait time.Duration
+}
+
+func (ss SignalStep) String() string {
+	return fmt.Sprintf("%s:%s", signalName(ss.Signal), ss.Wait)
+}
+
+// ParseSignalSequence parses a comma separated list of steps in the form signal:wait, e.g. SIGTERM:60s,SIGKILL:10s.
+// A signal is given by name or by number.
+func ParseSignalSequence(sequence string) ([]SignalStep, error) {
+	var steps []SignalStep
+	for _, step := range strings.Split(sequence, ",") {
+		step = strings.TrimSpace(step)
+		if step == "" {
+			continue
+		}
+
+		name, wait, found := strings.Cut(step, ":")
+		if !found {
+			return nil, errors.Errorf("signal step '%s' must have the form signal:wait", step)
+		}
+
+		signal, err := parseSignal(name)
+		if err != nil {
+			return nil, err
+		}
+
+		duration, err := time.ParseDuration(strings.TrimSpace(wait))
+		if err != nil || duration <= 0 {
+			return nil, errors.Errorf("signal step '%s' must have a positive waiting time", step)
+		}
+
+		steps = append(steps, SignalStep{Signal: signal, Wait: duration})
+	}
+
+	if len(steps) == 0 {
+		return nil, errors.New("signal sequence must contain at least one step")
+	}
+	return steps, nil
+}
+
+func parseSignal(name string) (syscall.Signal, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if number, err := strconv.Atoi(name); err == nil && number > 0 {
+		return syscall.Signal(number), nil
+	}
+
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	signal, ok := signalNames[name]
+	if !ok {
+		return 0, errors.Errorf("unknown signal '%s'", name)
+	}
+	return signal, nil
+}
+
+func signalName(signal syscall.Signal) string {
+	for name, known := range signalNames {
+		if known == signal {
+			return name
+		}
+	}
+	return strconv.Itoa(int(signal))
+}
+
+// SignalTarget selects the processes to which the signal action sends signals. Exactly one of both fields must be
+// set.
+type SignalTarget struct {
+	// PidFile is a file which contains the process ID.
+	PidFile string
+	// CommandLinePattern is a regular expression which is matched against the command line of all processes in
+	// /proc. The arguments of a command line are separated by spaces.
+	CommandLinePattern string
+}
+
+// signalAction sends a sequence of signals to a process, e.g. to restart Confluence without a shell script.
+type signalAction struct {
+	target   SignalTarget
+	pattern  *regexp.Regexp
+	steps    []SignalStep
+	procRoot string
+}
+
+// NewSignalAction creates an action which sends the signals of the given steps to the target processes until they
+// exited. The action fails if no process is found or if a process is still running after the last step.
+func NewSignalAction(target SignalTarget, steps []SignalStep) (Action, error) {
+	if (target.PidFile == "") == (target.CommandLinePattern == "") {
+		return nil, errors.New("either a pid file or a command line pattern must be given to send signals")
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("signal sequence must contain at least one step")
+	}
+
+	action := &signalAction{target: target, steps: steps, procRoot: "/proc"}
+	if target.CommandLinePattern != "" {
+		pattern, err := regexp.Compile(target.CommandLinePattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid command line pattern '%s'", target.CommandLinePattern)
+		}
+		action.pattern = pattern
+	}
+
+	return action, nil
+}
+
+func (sa *signalAction) Name() string {
+	return "signal"
+}
+
+func (sa *signalAction) Execute(ctx context.Context, _ *Event) error {
+	pids, err := sa.findProcesses()
+	if err != nil {
+		return err
+	}
+
+	for _, step := range sa.steps {
+		log.Infof("Sending %s to processes %v", signalName(step.Signal), pids)
+		pids = sendSignal(pids, step.Signal)
+
+		pids, err = sa.waitForExit(ctx, pids, step.Wait)
+		if err != nil {
+			return err
+		}
+		if len(pids) == 0 {
+			log.Info("All signalled processes exited")
+			return nil
+		}
+	}
+
+	return errors.Errorf("processes %v are still running after signal sequence %v", pids, sa.steps)
+}
+
+func (sa *signalAction) findProcesses() ([]int, error) {
+	if sa.target.PidFile != "" {
+		pid, err := readPidFile(sa.target.PidFile)
+		if err != nil {
+			return nil, err
+		}
+		return []int{pid}, nil
+	}
+
+	pids, err := findProcessesByCommandLine(sa.procRoot, sa.pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(pids) == 0 {
+		return nil, errors.Errorf("failed to find a process with a command line matching '%s'", sa.pattern)
+	}
+	return pids, nil
+}
+
+func readPidFile(pidFile string) (int, error) {
+	content, err := os.ReadFile(pidFile)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to read pid file '%s'", pidFile)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 {
+		return 0, errors.Errorf("pid file '%s' does not contain a valid process ID", pidFile)
+	}
+	return pid, nil
+}
+
+// findProcessesByCommandLine returns the IDs of all processes but the own one whose command line matches the pattern.
+func findProcessesByCommandLine(procRoot string, pattern *regexp.Regexp) ([]int, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list processes in '%s'", procRoot)
+	}
+
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+
+		cmdline, err := os.ReadFile(filepath.Join(procRoot, entry.Name(), "cmdline"))
+		if err != nil || len(cmdline) == 0 {
+			// the process exited in the meantime or is a kernel thread
+			continue
+		}
+
+		commandLine := string(bytes.TrimRight(bytes.ReplaceAll(cmdline, []byte{0}, []byte{' '}), " "))
+		if pattern.MatchString(commandLine) {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids, nil
+}
+
+// sendSignal sends the signal to all processes and returns the processes which did not exit yet.
+func sendSignal(pids []int, signal syscall.Signal) []int {
+	var running []int
+	for _, pid := range pids {
+		process, err := os.FindProcess(pid)
+		if err == nil {
+			err = process.Signal(signal)
+		}
+		if errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH) {
+			continue
+		}
+		if err != nil {
+			log.Warningf("Failed to send %s to process %d: %s", signalName(signal), pid, err.Error())
+		}
+		running = append(running, pid)
+	}
+	return running
+}
+
+// waitForExit waits up to the given time for the processes to exit and returns the processes which are still running.
+func (sa *signalAction) waitForExit(ctx context.Context, pids []int, wait time.Duration) ([]int, error) {
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	ticker := time.NewTicker(processPollInterval)
+	defer ticker.Stop()
+
+	for {
+		pids = sa.runningProcesses(pids)
+		if len(pids) == 0 {
+			return nil, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return pids, errors.Wrapf(ctx.Err(), "stopped waiting for processes %v to exit", pids)
+		case <-timeout.C:
+			return sa.runningProcesses(pids), nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (sa *signalAction) runningProcesses(pids []int) []int {
+	var running []int
+	for _, pid := range pids {
+		if sa.isRunning(pid) {
+			running = append(running, pid)
+		}
+	}
+	return running
+}
+
+// isRunning checks whether the process exists. A zombie process which was not yet reaped by its parent counts as
+// exited.
+func (sa *signalAction) isRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err == nil {
+		err = process.Signal(syscall.Signal(0))
+	}
+	if errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH) {
+		return false
+	}
+
+	stat, err := os.ReadFile(filepath.Join(sa.procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		// the state is unknown, e.g. because there is no /proc, but the process could be signalled
+		return true
+	}
+	return !isZombie(string(stat))
+}
+
+// isZombie checks the state in the content of /proc/<pid>/stat. The state follows the command name in parentheses,
+// which may contain spaces and parentheses itself.
+func isZombie(stat string) bool {
+	index := strings.LastIndex(stat, ")")
+	if index < 0 {
+		return false
+	}
+
+	fields := strings.Fields(stat[index+1:])
+	return len(fields) > 0 && fields[0] == "Z"
+}
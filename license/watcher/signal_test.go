@@ -0,0 +1,162 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseSignalSequence(t *testing.T) {
+	t.Run("should parse signals by name and number", func(t *testing.T) {
+		actual, err := ParseSignalSequence("SIGTERM:30s, kill:5s,10:1m")
+
+		require.NoError(t, err)
+		expected := []SignalStep{
+			{Signal: syscall.SIGTERM, Wait: 30 * time.Second},
+			{Signal: syscall.SIGKILL, Wait: 5 * time.Second},
+			{Signal: syscall.Signal(10), Wait: time.Minute},
+		}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should parse default sequence", func(t *testing.T) {
+		_, err := ParseSignalSequence(DefaultSignalSequence)
+
+		require.NoError(t, err)
+	})
+	t.Run("should fail on invalid sequences", func(t *testing.T) {
+		for sequence, message := range map[string]string{
+			"":             "at least one step",
+			"SIGTERM":      "must have the form signal:wait",
+			"SIGFOO:1s":    "unknown signal 'SIGFOO'",
+			"SIGTERM:soon": "must have a positive waiting time",
+			"SIGTERM:0s":   "must have a positive waiting time",
+		} {
+			_, err := ParseSignalSequence(sequence)
+
+			require.Error(t, err, sequence)
+			assert.Contains(t, err.Error(), message, sequence)
+		}
+	})
+}
+
+func TestNewSignalAction(t *testing.T) {
+	steps := []SignalStep{{Signal: syscall.SIGTERM, Wait: time.Second}}
+
+	_, err := NewSignalAction(SignalTarget{}, steps)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "either a pid file or a command line pattern must be given")
+
+	_, err = NewSignalAction(SignalTarget{PidFile: "confluence.pid", CommandLinePattern: "java"}, steps)
+	require.Error(t, err)
+
+	_, err = NewSignalAction(SignalTarget{CommandLinePattern: "java("}, steps)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid command line pattern")
+
+	_, err = NewSignalAction(SignalTarget{PidFile: "confluence.pid"}, nil)
+	require.Error(t, err)
+}
+
+func Test_signalAction_Execute(t *testing.T) {
+	t.Run("should terminate process from pid file", func(t *testing.T) {
+		// given
+		process := startThrowawayProcess(t, "sleep 30")
+		pidFile := filepath.Join(t.TempDir(), "confluence.pid")
+		require.NoError(t, os.WriteFile(pidFile, []byte(strconv.Itoa(process.Pid)+"\n"), 0644))
+		sut, err := NewSignalAction(SignalTarget{PidFile: pidFile}, []SignalStep{{Signal: syscall.SIGTERM, Wait: 5 * time.Second}})
+		require.NoError(t, err)
+
+		// when
+		err = sut.Execute(context.Background(), &Event{})
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "signal", sut.Name())
+	})
+	t.Run("should kill process found by command line which ignores SIGTERM", func(t *testing.T) {
+		// given
+		marker := fmt.Sprintf("marker-%d", time.Now().UnixNano())
+		startThrowawayProcess(t, "trap '' TERM; while true; do sleep 0.05; done # "+marker)
+		steps := []SignalStep{{Signal: syscall.SIGTERM, Wait: 300 * time.Millisecond}, {Signal: syscall.SIGKILL, Wait: 5 * time.Second}}
+		sut, err := NewSignalAction(SignalTarget{CommandLinePattern: marker}, steps)
+		require.NoError(t, err)
+
+		// when
+		start := time.Now()
+		err = sut.Execute(context.Background(), &Event{})
+
+		// then
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	})
+	t.Run("should fail if process is still running after the last step", func(t *testing.T) {
+		// given
+		marker := fmt.Sprintf("marker-%d", time.Now().UnixNano())
+		startThrowawayProcess(t, "trap '' TERM; while true; do sleep 0.05; done # "+marker)
+		sut, err := NewSignalAction(SignalTarget{CommandLinePattern: marker}, []SignalStep{{Signal: syscall.SIGTERM, Wait: 200 * time.Millisecond}})
+		require.NoError(t, err)
+
+		// when
+		err = sut.Execute(context.Background(), &Event{})
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "are still running after signal sequence [SIGTERM:200ms]")
+	})
+	t.Run("should fail if no process matches", func(t *testing.T) {
+		sut, err := NewSignalAction(SignalTarget{CommandLinePattern: "no-such-process-[0-9]{20}"}, []SignalStep{{Signal: syscall.SIGTERM, Wait: time.Second}})
+		require.NoError(t, err)
+
+		err = sut.Execute(context.Background(), &Event{})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to find a process with a command line matching")
+	})
+	t.Run("should fail on missing pid file", func(t *testing.T) {
+		sut, err := NewSignalAction(SignalTarget{PidFile: "/does/not/exist.pid"}, []SignalStep{{Signal: syscall.SIGTERM, Wait: time.Second}})
+		require.NoError(t, err)
+
+		err = sut.Execute(context.Background(), &Event{})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read pid file '/does/not/exist.pid'")
+	})
+}
+
+func Test_isZombie(t *testing.T) {
+	assert.True(t, isZombie("4242 (java) Z 1 4242"))
+	assert.True(t, isZombie("4242 (my (odd) cmd) Z 1 4242"))
+	assert.False(t, isZombie("4242 (java) S 1 4242"))
+	assert.False(t, isZombie("garbage"))
+}
+
+// test util stuff
+
+// startThrowawayProcess starts a shell running the given script. The process is killed and reaped after the test.
+func startThrowawayProcess(t *testing.T, script string) *os.Process {
+	t.Helper()
+
+	cmd := exec.Command("/bin/sh", "-c", script)
+	require.NoError(t, cmd.Start())
+	done := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		<-done
+	})
+
+	// give the shell time to install its signal traps
+	time.Sleep(100 * time.Millisecond)
+	return cmd.Process
+}
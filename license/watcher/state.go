@@ -0,0 +1,174 @@
+package watcher
+
+import (
+	"encoding/json"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// State is what the watcher knows about its runs. It is written to the state file after each check, so that it
+// survives restarts. Counters are summed up over all runs.
+type State struct {
+	// StartedAt is the point in time when the watcher started the last time.
+	StartedAt time.Time `json:"startedAt,omitzero"`
+	// LastCheck is the point in time of the last license check.
+	LastCheck time.Time `json:"lastCheck,omitzero"`
+	// LastSuccessfulCheck is the point in time of the last license check which could read the config file.
+	LastSuccessfulCheck time.Time `json:"lastSuccessfulCheck,omitzero"`
+	// LastChange is the point in time when the last license change was detected.
+	LastChange time.Time `json:"lastChange,omitzero"`
+	// License is the redacted license which was seen in the last successful check. It is empty if no license was
+	// configured.
+	License string `json:"license,omitempty"`
+	// LicenseSHA256 is the fingerprint of the license which was seen in the last successful check. It is empty if
+	// no license was configured.
+	LicenseSHA256 string `json:"licenseSha256,omitempty"`
+	// Checks is the number of license checks.
+	Checks int `json:"checks"`
+	// FailedChecks is the number of license checks which could not read the config file.
+	FailedChecks int `json:"failedChecks"`
+	// Changes is the number of detected license changes.
+	Changes int `json:"changes"`
+	// LastEvent is the type of the last detected license change.
+	LastEvent EventType `json:"lastEvent,omitempty"`
+	// LastAction is the result of the last event which was handled by an action.
+	LastAction *ActionResult `json:"lastAction,omitempty"`
+}
+
+// ActionResult describes how the actions handled an event.
+type ActionResult struct {
+	// Event is the type of the handled event.
+	Event EventType `json:"event"`
+	// Time is the point in time when the actions finished.
+	Time time.Time `json:"time"`
+	// Success is true if all actions succeeded.
+	Success bool `json:"success"`
+	// Error describes why an action failed. It is empty on success.
+	Error string `json:"error,omitempty"`
+}
+
+// ReadState reads the state from the given file. The returned error wraps os.ErrNotExist if the file does not exist.
+func ReadState(stateFile string) (*State, error) {
+	content, err := os.ReadFile(stateFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read state file '%s'", stateFile)
+	}
+
+	state := &State{}
+	err = json.Unmarshal(content, state)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse state file '%s'", stateFile)
+	}
+	return state, nil
+}
+
+// WriteState writes the state to the given file. The file is replaced atomically, so that readers never see a
+// partly written state. Missing directories are created.
+func WriteState(stateFile string, state *State) error {
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode state")
+	}
+
+	dir := filepath.Dir(stateFile)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create directory of state file '%s'", stateFile)
+	}
+
+	temp, err := os.CreateTemp(dir, filepath.Base(stateFile)+".*.tmp")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create state file '%s'", stateFile)
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.Write(append(content, '\n'))
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to write state file '%s'", stateFile)
+	}
+
+	err = os.Rename(temp.Name(), stateFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to replace state file '%s'", stateFile)
+	}
+	return nil
+}
+
+// RecordCheck counts a license check. The license is only recorded if the check succeeded. It returns whether the
+// license differs from the license of the last successful check. The first successful check is no change.
+func (s *State) RecordCheck(at time.Time, license string, checkErr error) (changed bool) {
+	s.Checks++
+	s.LastCheck = at
+	if checkErr != nil {
+		s.FailedChecks++
+		return false
+	}
+
+	fingerprint := atlassian.Fingerprint(license)
+	changed = !s.LastSuccessfulCheck.IsZero() && fingerprint != s.LicenseSHA256
+	s.LastSuccessfulCheck = at
+	s.LicenseSHA256 = fingerprint
+	// the state file outlives the process, so the license is redacted even if licenses are shown for debugging
+	s.License = atlassian.Abbreviate(license)
+	return changed
+}
+
+func (s *State) recordChange(event *Event) {
+	s.Changes++
+	s.LastChange = event.Time
+	s.LastEvent = event.Type
+}
+
+func (s *State) recordAction(event *Event, actionErr error) {
+	result := &ActionResult{Event: event.Type, Time: time.Now(), Success: actionErr == nil}
+	if actionErr != nil {
+		result.Error = actionErr.Error()
+	}
+	s.LastAction = result
+}
+
+// initState continues the state of the state file. A state which cannot be read is replaced by a new one.
+func (dw *defaultWatcher) initState(now time.Time) {
+	if dw.args.StateFile != "" {
+		state, err := ReadState(dw.args.StateFile)
+		switch {
+		case err == nil:
+			dw.state = *state
+		case !errors.Is(err, os.ErrNotExist):
+			log.Warningf("Starting with a new state: %s", err.Error())
+		}
+	}
+	dw.state.StartedAt = now
+	dw.saveState()
+}
+
+// saveState writes the state to the state file and shares it with the monitor. A failure is only logged because the
+// state is not needed to watch. It is logged as warning once, e.g. because the state directory is not writable in a
+// container, and on level debug on each further check.
+func (dw *defaultWatcher) saveState() {
+	dw.publishStatus()
+	if dw.args.StateFile == "" {
+		return
+	}
+
+	err := WriteState(dw.args.StateFile, &dw.state)
+	switch {
+	case err != nil && !dw.stateWriteFailed:
+		log.Warningf("Failed to save state, further failures are only logged on level debug: %s", err.Error())
+		dw.stateWriteFailed = true
+	case err != nil:
+		log.Debugf("Failed to save state: %s", err.Error())
+	case dw.stateWriteFailed:
+		log.Infof("Saved state to '%s' again.", dw.args.StateFile)
+		dw.stateWriteFailed = false
+	}
+}
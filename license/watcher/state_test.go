@@ -0,0 +1,178 @@
+package watcher
+
+import (
+	"context"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/op/go-logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteState(t *testing.T) {
+	t.Run("should write state which can be read again", func(t *testing.T) {
+		// given
+		stateFile := filepath.Join(t.TempDir(), "state", "state.json")
+		now := time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC)
+		state := &State{
+			StartedAt:     now,
+			LastCheck:     now,
+			LicenseSHA256: atlassian.Fingerprint("license"),
+			Checks:        3,
+			LastAction:    &ActionResult{Event: EventSetupToProduction, Time: now, Error: "exit code 1"},
+		}
+
+		// when
+		err := WriteState(stateFile, state)
+
+		// then
+		require.NoError(t, err)
+		actual, err := ReadState(stateFile)
+		require.NoError(t, err)
+		assert.Equal(t, state, actual)
+		entries, err := os.ReadDir(filepath.Dir(stateFile))
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "temporary files must be removed")
+	})
+	t.Run("should omit unknown points in time", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "state.json")
+
+		err := WriteState(stateFile, &State{Checks: 1})
+
+		require.NoError(t, err)
+		content, err := os.ReadFile(stateFile)
+		require.NoError(t, err)
+		assert.Equal(t, "{\n  \"checks\": 1,\n  \"failedChecks\": 0,\n  \"changes\": 0\n}\n", string(content))
+	})
+}
+
+func TestReadState(t *testing.T) {
+	t.Run("should report missing state file", func(t *testing.T) {
+		_, err := ReadState(filepath.Join(t.TempDir(), "state.json"))
+
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+	t.Run("should fail on malformed state file", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "state.json")
+		require.NoError(t, os.WriteFile(stateFile, []byte("{"), 0600))
+
+		_, err := ReadState(stateFile)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to parse state file")
+	})
+}
+
+func TestState_RecordCheck(t *testing.T) {
+	now := time.Now()
+	sut := &State{}
+
+	first := sut.RecordCheck(now, "license", nil)
+	failed := sut.RecordCheck(now.Add(time.Second), "", assert.AnError)
+
+	assert.False(t, first)
+	assert.False(t, failed)
+	assert.Equal(t, 2, sut.Checks)
+	assert.Equal(t, 1, sut.FailedChecks)
+	assert.Equal(t, now.Add(time.Second), sut.LastCheck)
+	assert.Equal(t, now, sut.LastSuccessfulCheck)
+	assert.Equal(t, atlassian.Fingerprint("license"), sut.LicenseSHA256)
+	assert.Equal(t, atlassian.Redact("license"), sut.License)
+	assert.False(t, sut.RecordCheck(now, "license", nil))
+	assert.True(t, sut.RecordCheck(now, "renewed", nil))
+
+	t.Run("should not store the full license if licenses are shown", func(t *testing.T) {
+		atlassian.ShowLicenses(true)
+		defer atlassian.ShowLicenses(false)
+		sut := &State{}
+
+		sut.RecordCheck(now, "AAABrQ0ODAoPeNqFkV1PgjAUhu/7K5p4jWHgF0m9QHN", nil)
+
+		assert.Equal(t, "AAABrQ...0m9QHN (sha256:"+atlassian.Fingerprint("AAABrQ0ODAoPeNqFkV1PgjAUhu/7K5p4jWHgF0m9QHN")+")", sut.License)
+	})
+}
+
+func Test_defaultWatcher_state(t *testing.T) {
+	const licFile = "/var/atlassian/confluence/confluence.cfg.xml"
+	commandArgs := []string{"/opt/atlassian/confluence/bin/shutdown.sh"}
+
+	t.Run("should continue the counters of the state file", func(t *testing.T) {
+		// given
+		stateFile := filepath.Join(t.TempDir(), "state.json")
+		require.NoError(t, WriteState(stateFile, &State{Checks: 5, Changes: 1}))
+		now := time.Now()
+		sut := &defaultWatcher{args: &ProcessArgs{StateFile: stateFile}}
+
+		// when
+		sut.initState(now)
+
+		// then
+		assert.Equal(t, 5, sut.state.Checks)
+		assert.Equal(t, 1, sut.state.Changes)
+		actual, err := ReadState(stateFile)
+		require.NoError(t, err)
+		assert.True(t, now.Equal(actual.StartedAt))
+	})
+	t.Run("should save checks, changes and action results", func(t *testing.T) {
+		// given
+		stateFile := filepath.Join(t.TempDir(), "state.json")
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("production", nil)
+		mockedExecutor := new(executorMock)
+		mockedExecutor.On("execute", commandArgs).Return("", assert.AnError)
+		sut := &defaultWatcher{
+			args: &ProcessArgs{
+				CommandArgs:          commandArgs,
+				ConfluenceConfigFile: licFile,
+				SetupLicense:         "setup",
+				StateFile:            stateFile,
+			},
+			cmdExecutor:   mockedExecutor,
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  "setup",
+		}
+
+		// when
+		_, _ = sut.doWatchWork(context.Background())
+
+		// then
+		actual, err := ReadState(stateFile)
+		require.NoError(t, err)
+		assert.Equal(t, 1, actual.Checks)
+		assert.Equal(t, 1, actual.Changes)
+		assert.Equal(t, atlassian.Fingerprint("production"), actual.LicenseSHA256)
+		assert.Equal(t, EventSetupToProduction, actual.LastEvent)
+		require.NotNil(t, actual.LastAction)
+		assert.False(t, actual.LastAction.Success)
+		assert.Contains(t, actual.LastAction.Error, assert.AnError.Error())
+	})
+	t.Run("should warn only once about a state file which cannot be written", func(t *testing.T) {
+		// given
+		blocker := filepath.Join(t.TempDir(), "blocker")
+		require.NoError(t, os.WriteFile(blocker, nil, 0644))
+		sut := &defaultWatcher{args: &ProcessArgs{StateFile: filepath.Join(blocker, "state.json")}}
+		logs := logging.NewMemoryBackend(10)
+		logging.SetBackend(logs)
+		logging.SetLevel(logging.DEBUG, "")
+		defer logging.SetBackend(logging.NewLogBackend(os.Stderr, "", 0))
+
+		// when
+		sut.saveState()
+		sut.saveState()
+		sut.saveState()
+
+		// then
+		var warnings []string
+		for node := logs.Head(); node != nil; node = node.Next() {
+			if node.Record.Level == logging.WARNING {
+				warnings = append(warnings, node.Record.Message())
+			}
+		}
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "Failed to save state")
+		assert.True(t, sut.stateWriteFailed)
+	})
+}
@@ -0,0 +1,137 @@
+package watcher
+
+import (
+	"github.com/pkg/errors"
+	"time"
+)
+
+const (
+	// BackendAuto uses BackendInotify if the file system of the config file supports it, and BackendPoll otherwise.
+	BackendAuto = "auto"
+	// BackendInotify checks the config file whenever the file system reports a change.
+	BackendInotify = "inotify"
+	// BackendPoll checks the config file in the intervals of a Schedule.
+	BackendPoll = "poll"
+)
+
+// trigger tells the watcher when the config file should be checked.
+type trigger interface {
+	// C returns the channel that receives a value whenever the config file should be checked. The channel is closed
+	// when the trigger stops.
+	C() <-chan struct{}
+	// Err returns the reason why the trigger stopped on its own, or nil.
+	Err() error
+	// Stop stops the trigger and releases its resources.
+	Stop()
+}
+
+// newTrigger creates a trigger for the given backend. The schedule is only used by the polling backend.
+func newTrigger(backend string, configFile string, schedule Schedule) (trigger, error) {
+	switch backend {
+	case BackendPoll:
+		log.Debugf("Using polling backend with an interval of %s", schedule)
+		return newPollTrigger(configFile, schedule), nil
+	case BackendInotify:
+		log.Debug("Using inotify backend")
+		return newInotifyTrigger(configFile)
+	case "", BackendAuto:
+		if supported, reason := supportsInotify(configFile); !supported {
+			log.Infof("Falling back to polling backend with an interval of %s: %s", schedule, reason)
+			return newPollTrigger(configFile, schedule), nil
+		}
+
+		inotify, err := newInotifyTrigger(configFile)
+		if err != nil {
+			log.Infof("Falling back to polling backend with an interval of %s: %s", schedule, err.Error())
+			return newPollTrigger(configFile, schedule), nil
+		}
+
+		log.Debug("Using inotify backend")
+		return inotify, nil
+	default:
+		return nil, errors.Errorf("unsupported watch backend '%s'", backend)
+	}
+}
+
+// ValidateBackend checks if the given backend is supported.
+func ValidateBackend(backend string) error {
+	switch backend {
+	case "", BackendAuto, BackendInotify, BackendPoll:
+		return nil
+	default:
+		return errors.Errorf("unsupported watch backend '%s'", backend)
+	}
+}
+
+type pollTrigger struct {
+	configFile string
+	schedule   Schedule
+	c          chan struct{}
+	done       chan struct{}
+}
+
+func newPollTrigger(configFile string, schedule Schedule) *pollTrigger {
+	pt := &pollTrigger{
+		configFile: configFile,
+		schedule:   schedule,
+		c:          make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go pt.run()
+
+	return pt
+}
+
+func (pt *pollTrigger) run() {
+	defer close(pt.c)
+
+	unchangedChecks := 0
+	lastState := pt.fileState()
+	for {
+		timer := time.NewTimer(pt.schedule.next(unchangedChecks))
+		select {
+		case <-timer.C:
+		case <-pt.done:
+			timer.Stop()
+			return
+		}
+
+		state := pt.fileState()
+		if state == lastState {
+			unchangedChecks++
+		} else {
+			unchangedChecks = 0
+			lastState = state
+		}
+
+		select {
+		case pt.c <- struct{}{}:
+		case <-pt.done:
+			return
+		}
+	}
+}
+
+// fileState returns the state of the config file if the interval is adaptive. Otherwise, the file is not inspected.
+func (pt *pollTrigger) fileState() fileState {
+	if !pt.schedule.isAdaptive() {
+		return fileState{}
+	}
+	return statFile(pt.configFile)
+}
+
+func (pt *pollTrigger) C() <-chan struct{} {
+	return pt.c
+}
+
+func (pt *pollTrigger) Err() error {
+	return nil
+}
+
+func (pt *pollTrigger) Stop() {
+	select {
+	case <-pt.done:
+	default:
+		close(pt.done)
+	}
+}
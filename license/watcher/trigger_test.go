@@ -0,0 +1,71 @@
+package watcher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_newTrigger(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "confluence.cfg.xml")
+
+	t.Run("should create poll trigger", func(t *testing.T) {
+		actual, err := newTrigger(BackendPoll, configFile, Schedule{Interval: time.Second})
+
+		require.NoError(t, err)
+		defer actual.Stop()
+		assert.IsType(t, &pollTrigger{}, actual)
+	})
+	t.Run("should create a trigger in auto mode", func(t *testing.T) {
+		actual, err := newTrigger(BackendAuto, configFile, Schedule{Interval: time.Second})
+
+		require.NoError(t, err)
+		defer actual.Stop()
+		assert.NotNil(t, actual)
+	})
+	t.Run("should fail on unknown backend", func(t *testing.T) {
+		_, err := newTrigger("fanotify", configFile, Schedule{Interval: time.Second})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported watch backend 'fanotify'")
+	})
+}
+
+func TestValidateBackend(t *testing.T) {
+	assert.NoError(t, ValidateBackend(""))
+	assert.NoError(t, ValidateBackend(BackendAuto))
+	assert.NoError(t, ValidateBackend(BackendInotify))
+	assert.NoError(t, ValidateBackend(BackendPoll))
+	assert.Error(t, ValidateBackend("fanotify"))
+}
+
+func Test_pollTrigger(t *testing.T) {
+	t.Run("should fire in interval", func(t *testing.T) {
+		sut := newPollTrigger("confluence.cfg.xml", Schedule{Interval: 10 * time.Millisecond})
+		defer sut.Stop()
+
+		for i := 0; i < 2; i++ {
+			select {
+			case <-sut.C():
+			case <-time.After(time.Second):
+				t.Fatal("poll trigger did not fire")
+			}
+		}
+		assert.NoError(t, sut.Err())
+	})
+	t.Run("should close channel on stop", func(t *testing.T) {
+		sut := newPollTrigger("confluence.cfg.xml", Schedule{Interval: time.Hour})
+
+		sut.Stop()
+		sut.Stop()
+
+		select {
+		case _, open := <-sut.C():
+			assert.False(t, open)
+		case <-time.After(time.Second):
+			t.Fatal("poll trigger channel was not closed")
+		}
+	})
+}
@@ -1,9 +1,14 @@
 package watcher
 
 import (
+	"context"
+	"fmt"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
 	"github.com/cloudogu/confluence-license-checker/license/tester"
 	"github.com/op/go-logging"
 	"github.com/pkg/errors"
+	"os"
+	"sync"
 	"time"
 )
 
@@ -12,55 +17,181 @@ var log = logging.MustGetLogger("watcher")
 // Watcher detects a change from setup license to a valid production license in a given Confluence configuration.
 // A given action must be executed if a license change is detected.
 type Watcher interface {
-	// Watch watches for license changes.
-	Watch() error
+	// Watch watches for license changes until a change was handled or the context is done. If the context is done
+	// while an action is running, the action may finish within the grace period. The returned error wraps the
+	// context's error if the watcher stopped because the context is done.
+	Watch(ctx context.Context) error
 }
 
 // ProcessArgs contain necessary arguments
 type ProcessArgs struct {
 	// CommandArgs is a shell call and necessary arguments that will be executed if a license change is detected.
-	// The first argument is the actual command to be executed, and must be present. Any further arguments are optional
-	// and depend on the command.
+	// The first argument is the actual command to be executed. Any further arguments are optional and depend on the
+	// command. No command is executed if empty, e.g. because only built-in actions handle license changes.
 	//
 	// Example:
 	// 	[]string{ "/bin/echo", "-n", "hello world" }
 	CommandArgs []string
-	// WatchIntervalInSecs is the interval in seconds in which the config file is inspected for a license change.
-	WatchIntervalInSecs int
+	// Schedule configures the intervals in which the polling backend inspects the config file for a license change.
+	Schedule Schedule
 	// ConfluenceConfigFile is the file which accommodates the license to be watched.
 	ConfluenceConfigFile string
-	// SetupLicense is the license with which the setup should be executed.
+	// GracePeriod is the time an already running action may take to finish after the watcher was stopped. The action
+	// is aborted afterwards.
+	GracePeriod time.Duration
+	// WatchBackend selects how changes of the config file are noticed, one of BackendAuto, BackendInotify or
+	// BackendPoll. An empty backend is treated as BackendAuto.
+	WatchBackend string
+	// SetupLicense is the license with which the setup should be executed. It may be empty if the detection does
+	// not require a setup license. In this case the license configured at the start of the watcher is watched.
 	SetupLicense string
+	// Detection configures how a setup license is recognized.
+	Detection tester.Detection
+	// RetryPolicy configures timeouts and retries of the command.
+	RetryPolicy RetryPolicy
+	// CommandOutput configures what happens with the output of the command.
+	CommandOutput OutputOptions
+	// CommandEnvironment selects the environment variables of the watcher which are passed to the command.
+	CommandEnvironment EnvironmentPolicy
+	// CommandEvents are the event types on which CommandArgs is executed. If empty, DefaultCommandEventTypes is used.
+	CommandEvents []EventType
+	// Actions are further actions which are executed after the command on the events they subscribed to.
+	Actions []Subscription
+	// ExpiryWarningDays are the numbers of days before the expiry of the configured license on which
+	// EventLicenseExpiring is emitted, e.g. 30, 7 and 1. No expiry warnings are emitted if empty.
+	ExpiryWarningDays []int
+	// Readiness configures whether the watcher waits for the Confluence setup to complete before it compares licenses.
+	Readiness Readiness
+	// ErrorPolicy configures how often a license check may fail before the watcher gives up.
+	ErrorPolicy ErrorPolicy
+	// StateFile is the file in which the watcher keeps its State. No state is written if empty.
+	StateFile string
+	// Monitor shares the state of the watcher with the HTTP endpoints. Nothing is shared if nil.
+	Monitor *Monitor
+	// Metrics counts license checks and action invocations. Nothing is counted if nil.
+	Metrics *Metrics
+	// Continuous keeps the watcher running after a license change. The changed license becomes the known license
+	// and the action is executed again on each further change. A failed action does not stop the watcher.
+	Continuous bool
+}
+
+// String describes the arguments for log messages. The setup license is redacted.
+func (args *ProcessArgs) String() string {
+	// the conversion drops the String method, which would be called recursively otherwise
+	type plainArgs ProcessArgs
+	redacted := plainArgs(*args)
+	redacted.SetupLicense = atlassian.Redact(args.SetupLicense)
+	return fmt.Sprintf("%+v", redacted)
 }
 
 // New creates a new Watcher instance.
 func New(args *ProcessArgs) Watcher {
 	log.Debugf("Found these arguments: %v", args)
 
-	executor := newExecutor()
-	licenseChecker := tester.New()
+	executor := newExecutor(args.RetryPolicy, args.CommandOutput, args.CommandEnvironment)
+	licenseChecker := tester.NewWithDetection(args.Detection)
 
 	return &defaultWatcher{
 		args:          args,
 		cmdExecutor:   executor,
 		licenseTester: licenseChecker,
+		createTrigger: newTrigger,
 	}
 }
 
 type defaultWatcher struct {
-	args          *ProcessArgs
-	cmdExecutor   executor
-	licenseTester tester.Tester
+	args              *ProcessArgs
+	cmdExecutor       executor
+	licenseTester     tester.Tester
+	knownLicense      string
+	lastExpiryWarning expiryWarning
+	failedChecks      int
+	// licenseRemoved is set while the license is missing. The known license is kept in the meantime, so that a
+	// reappeared license is compared with the license before the removal.
+	licenseRemoved   bool
+	state            State
+	stateWriteFailed bool
+	waitingForSetup  bool
+	configParsed     bool
+	createTrigger    func(backend string, configFile string, schedule Schedule) (trigger, error)
 }
 
-// Watch watches in a fixed interval for license changes.
-func (dw *defaultWatcher) Watch() error {
-	duration := time.Duration(dw.args.WatchIntervalInSecs) * time.Second
-	log.Debugf("Start License check using %d seconds", dw.args.WatchIntervalInSecs)
+// Watch watches for license changes whenever the trigger of the configured backend fires.
+func (dw *defaultWatcher) Watch(ctx context.Context) error {
+	// the actions are closed on every return, so that no action keeps running in the background
+	defer dw.closeActions(ctx)
+	dw.initState(time.Now())
+
+	if dw.args.Readiness.Wait {
+		err := dw.waitUntilReady(ctx)
+		if err != nil {
+			return errors.Wrap(err, "exiting watcher because Confluence is not ready")
+		}
+	}
+
+	err := dw.initKnownLicense()
+	if err != nil {
+		return errors.Wrap(err, "exiting watcher because the license to watch cannot be determined")
+	}
+
+	log.Debugf("Start License check using backend '%s' and an interval of %s", dw.args.WatchBackend, dw.args.Schedule)
+
+	checkTrigger, err := dw.createTrigger(dw.args.WatchBackend, dw.args.ConfluenceConfigFile, dw.args.Schedule)
+	if err != nil {
+		return errors.Wrap(err, "exiting watcher because the watch backend cannot be started")
+	}
+	defer checkTrigger.Stop()
+
+	var expiryCheck <-chan time.Time
+	if len(dw.args.ExpiryWarningDays) > 0 {
+		expiryTicker := time.NewTicker(expiryCheckInterval)
+		defer expiryTicker.Stop()
+		expiryCheck = expiryTicker.C
+		dw.checkExpiry(ctx)
+	}
+
+	var livenessCheck <-chan time.Time
+	if dw.args.Monitor != nil {
+		// inotify only fires on changes of the config file, but /healthz expects regular successful checks
+		livenessTicker := time.NewTicker(dw.args.Schedule.longestInterval())
+		defer livenessTicker.Stop()
+		livenessCheck = livenessTicker.C
+	}
+
+	var retryCheck <-chan time.Time
+	// the license may have changed before the watcher started, so it is checked right away
+	checkNow := ctx.Err() == nil
+	for {
+		if !checkNow {
+			select {
+			case <-ctx.Done():
+				log.Info("Stopping watcher")
+				return errors.Wrap(ctx.Err(), "exiting watcher because it was stopped")
+			case <-expiryCheck:
+				dw.checkExpiry(ctx)
+				continue
+			case <-retryCheck:
+				retryCheck = nil
+			case <-livenessCheck:
+				if retryCheck != nil || time.Since(dw.state.LastCheck) < dw.args.Schedule.longestInterval() {
+					continue
+				}
+			case _, open := <-checkTrigger.C():
+				if !open {
+					return errors.Wrap(checkTrigger.Err(), "exiting watcher because the watch backend stopped")
+				}
+				if retryCheck != nil {
+					// the failed check is repeated after the backoff anyway
+					continue
+				}
+			}
+		}
+		checkNow = false
 
-	//lint:ignore SA1015 (don't warn about leaking ticker)
-	for range time.Tick(duration) {
-		done, err := dw.doWatchWork()
+		done, err := dw.doWatchWork(ctx)
+		if err != nil && ctx.Err() != nil {
+			return errors.Wrapf(ctx.Err(), "exiting watcher because it was stopped while an action was running: %s", err.Error())
+		}
 		if err != nil {
 			return errors.Wrap(err, "exiting watcher because an error occurred")
 		}
@@ -68,26 +199,195 @@ func (dw *defaultWatcher) Watch() error {
 		if done {
 			return nil
 		}
+		if delay := dw.retryDelay(); delay > 0 {
+			retryCheck = time.After(delay)
+		}
+		if expiryCheck != nil {
+			dw.checkExpiry(ctx)
+		}
+	}
+}
+
+// initKnownLicense uses the setup license as known license. If no setup license is given, the license which is
+// currently configured is used instead. This requires a detection without setup license or the continuous mode.
+func (dw *defaultWatcher) initKnownLicense() error {
+	if dw.args.SetupLicense != "" {
+		dw.knownLicense = dw.args.SetupLicense
+		return nil
+	}
+
+	if dw.args.Detection.RequiresSetupLicense() && !dw.args.Continuous {
+		return errors.Errorf("a setup license is required for detection mode '%s'", dw.args.Detection.Mode)
 	}
 
+	log.Debug("No setup license given. Watching the currently configured license instead.")
+	license, err := dw.readCurrentLicense()
+	if errors.Is(err, os.ErrNotExist) {
+		log.Debugf("No config file exists yet: %s", err.Error())
+		license, err = "", nil
+	}
+	if err != nil {
+		return err
+	}
+
+	dw.knownLicense = license
 	return nil
 }
 
-func (dw *defaultWatcher) doWatchWork() (done bool, err error) {
+// readCurrentLicense reads the configured license. A license which is missing in the config file is returned as
+// empty string. A missing config file is returned as error, because Confluence may replace the file in the moment it
+// is read, so that it counts as failed check.
+func (dw *defaultWatcher) readCurrentLicense() (string, error) {
+	license, err := dw.licenseTester.ReadLicense(dw.args.ConfluenceConfigFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err == nil || tester.IsLicenseMissing(err) {
+		dw.configParsed = true
+	}
+	if tester.IsLicenseMissing(err) {
+		log.Debugf("No license configured: %s", err.Error())
+		return "", nil
+	}
+
+	return license, err
+}
+
+func (dw *defaultWatcher) doWatchWork(ctx context.Context) (done bool, err error) {
 	log.Debugf("License check time: %s", time.Now().Format(time.RFC3339))
 
 	log.Debug("Checking for license change.")
-	changed, err := dw.licenseTester.HasLicenseChanged(dw.args.ConfluenceConfigFile, dw.args.SetupLicense)
+	defer dw.saveState()
+	start := time.Now()
+	license, err := dw.readCurrentLicense()
+	dw.observeCheck(start, license, err)
+	dw.state.RecordCheck(time.Now(), license, err)
 	if err != nil {
+		return dw.handleFailedCheck(ctx, err)
+	}
+	if dw.failedChecks > 0 {
+		log.Infof("License check succeeded again after %d failed checks.", dw.failedChecks)
+		dw.failedChecks = 0
+	}
+
+	if license != "" && dw.licenseRemoved {
+		// a reappeared license is compared with the license which was configured before the removal
+		log.Info("License is configured again after it was removed.")
+		dw.licenseRemoved = false
+	}
+	if license == dw.knownLicense || (license == "" && dw.licenseRemoved) {
+		log.Debug("No change found. Waiting for the next check.")
+		return false, nil
+	}
+
+	event := dw.newEvent(dw.knownLicense, license)
+	log.Infof("Found license change '%s'.", event.Type)
+	dw.state.recordChange(event)
+	actionCtx, cancel := withGracePeriod(ctx, dw.args.GracePeriod)
+	defer cancel()
+
+	handled, err := dw.dispatch(actionCtx, dw.subscriptions(), event)
+	if handled {
+		dw.state.recordAction(event, err)
+	}
+	if !dw.args.Continuous && handled {
 		return true, err
 	}
+	if !handled {
+		log.Infof("No action subscribed to license change '%s'.", event.Type)
+	}
 
-	if changed {
-		log.Debug("Found change.")
-		_, err = dw.cmdExecutor.execute(dw.args.CommandArgs)
+	if license == "" {
+		dw.licenseRemoved = true
+	} else {
+		dw.knownLicense = license
+	}
+	if err != nil && ctx.Err() != nil {
 		return true, err
 	}
+	if err != nil {
+		log.Errorf("Action failed on license change, continuing to watch: %+s", err)
+	}
+
+	log.Debug("Watching the changed license from now on.")
+	return false, nil
+}
+
+func (dw *defaultWatcher) newEvent(oldLicense string, newLicense string) *Event {
+	now := time.Now()
+	oldIsSetup := oldLicense != "" && dw.args.Detection.IsSetupLicense(oldLicense, dw.args.SetupLicense, now)
+	newIsSetup := newLicense != "" && dw.args.Detection.IsSetupLicense(newLicense, dw.args.SetupLicense, now)
+
+	return &Event{
+		Type:       classifyTransition(oldLicense, oldIsSetup, newLicense, newIsSetup),
+		Time:       now,
+		ConfigFile: dw.args.ConfluenceConfigFile,
+		OldLicense: oldLicense,
+		NewLicense: newLicense,
+		Old:        decodeOrNil(oldLicense),
+		New:        decodeOrNil(newLicense),
+	}
+}
+
+// subscriptions returns the command subscription followed by the subscriptions of further actions. There is no
+// command subscription if no command is given.
+func (dw *defaultWatcher) subscriptions() []Subscription {
+	if len(dw.args.CommandArgs) == 0 {
+		return dw.args.Actions
+	}
 
-	log.Debugf("No change found. Checking again in %d seconds.", dw.args.WatchIntervalInSecs)
-	return
+	commandEvents := dw.args.CommandEvents
+	if len(commandEvents) == 0 {
+		commandEvents = DefaultCommandEventTypes
+	}
+
+	command := Subscription{
+		Action: &commandAction{cmdExecutor: dw.cmdExecutor, commandArgs: dw.args.CommandArgs},
+		Events: commandEvents,
+	}
+
+	return append([]Subscription{command}, dw.args.Actions...)
+}
+
+// closeActions closes all actions which hold resources. The actions may take the grace period to close.
+func (dw *defaultWatcher) closeActions(ctx context.Context) {
+	closeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), dw.args.GracePeriod)
+	defer cancel()
+
+	for _, subscription := range dw.args.Actions {
+		closer, ok := subscription.Action.(Closer)
+		if !ok {
+			continue
+		}
+
+		err := closer.Close(closeCtx)
+		if err != nil {
+			log.Errorf("Failed to close action '%s': %s", subscription.Action.Name(), err.Error())
+		}
+	}
+}
+
+// withGracePeriod returns a context that is cancelled the grace period after the given context is done. This lets an
+// action which already runs finish when the watcher is stopped.
+func withGracePeriod(ctx context.Context, gracePeriod time.Duration) (context.Context, context.CancelFunc) {
+	graceCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	var mutex sync.Mutex
+	var timer *time.Timer
+	stop := context.AfterFunc(ctx, func() {
+		log.Infof("Watcher was stopped. Waiting up to %s for the running action to finish.", gracePeriod)
+		mutex.Lock()
+		defer mutex.Unlock()
+		timer = time.AfterFunc(gracePeriod, cancel)
+	})
+
+	return graceCtx, func() {
+		stop()
+		mutex.Lock()
+		if timer != nil {
+			timer.Stop()
+		}
+		mutex.Unlock()
+		cancel()
+	}
 }
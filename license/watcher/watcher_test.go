@@ -1,10 +1,18 @@
 package watcher
 
 import (
+	"context"
+	"fmt"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/cloudogu/confluence-license-checker/license/tester"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func Test_defaultWatcher_doWatchWork(t *testing.T) {
@@ -16,13 +24,12 @@ func Test_defaultWatcher_doWatchWork(t *testing.T) {
 		// given
 		args := &ProcessArgs{
 			CommandArgs:          commandArgs,
-			WatchIntervalInSecs:  30,
+			Schedule:             Schedule{Interval: 30 * time.Second},
 			ConfluenceConfigFile: licFile,
 			SetupLicense:         license,
 		}
-		const licenseHasChanged = true
 		mockedLicenseChecker := new(licenseTesterMock)
-		mockedLicenseChecker.On("HasLicenseChanged", licFile, license).Return(licenseHasChanged, nil)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("changedLicense", nil)
 
 		mockedExecutor := new(executorMock)
 		mockedExecutor.On("execute", commandArgs).Return("", nil)
@@ -31,10 +38,11 @@ func Test_defaultWatcher_doWatchWork(t *testing.T) {
 			args:          args,
 			cmdExecutor:   mockedExecutor,
 			licenseTester: mockedLicenseChecker,
+			knownLicense:  license,
 		}
 
 		// when
-		finishWatcher, err := sut.doWatchWork()
+		finishWatcher, err := sut.doWatchWork(context.Background())
 
 		// then
 		require.NoError(t, err)
@@ -46,13 +54,12 @@ func Test_defaultWatcher_doWatchWork(t *testing.T) {
 		// given
 		args := &ProcessArgs{
 			CommandArgs:          commandArgs,
-			WatchIntervalInSecs:  30,
+			Schedule:             Schedule{Interval: 30 * time.Second},
 			ConfluenceConfigFile: licFile,
 			SetupLicense:         license,
 		}
-		const licenseHasNotChanged = false
 		mockedLicenseChecker := new(licenseTesterMock)
-		mockedLicenseChecker.On("HasLicenseChanged", licFile, license).Return(licenseHasNotChanged, nil)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return(license, nil)
 
 		mockedExecutor := new(executorMock)
 		// no cmdExecutor modelling -> cmdExecutor will not be called
@@ -61,10 +68,11 @@ func Test_defaultWatcher_doWatchWork(t *testing.T) {
 			args:          args,
 			cmdExecutor:   mockedExecutor,
 			licenseTester: mockedLicenseChecker,
+			knownLicense:  license,
 		}
 
 		// when
-		finishWatcher, err := sut.doWatchWork()
+		finishWatcher, err := sut.doWatchWork(context.Background())
 
 		// then
 		require.NoError(t, err)
@@ -76,23 +84,24 @@ func Test_defaultWatcher_doWatchWork(t *testing.T) {
 		// given
 		args := &ProcessArgs{
 			CommandArgs:          commandArgs,
-			WatchIntervalInSecs:  30,
+			Schedule:             Schedule{Interval: 30 * time.Second},
 			ConfluenceConfigFile: licFile,
 			SetupLicense:         license,
 		}
 		anError := assert.AnError
 		mockedLicenseChecker := new(licenseTesterMock)
-		mockedLicenseChecker.On("HasLicenseChanged", licFile, license).Return(false, anError)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("", anError)
 		mockedExecutor := new(executorMock)
 
 		sut := defaultWatcher{
 			args:          args,
 			cmdExecutor:   mockedExecutor,
 			licenseTester: mockedLicenseChecker,
+			knownLicense:  license,
 		}
 
 		// when
-		finishWatcher, err := sut.doWatchWork()
+		finishWatcher, err := sut.doWatchWork(context.Background())
 
 		// then
 		require.Error(t, err)
@@ -102,16 +111,335 @@ func Test_defaultWatcher_doWatchWork(t *testing.T) {
 	})
 }
 
+func Test_defaultWatcher_doWatchWork_continuous(t *testing.T) {
+	const licFile = "/var/atlassian/confluence/confluence.cfg.xml"
+	commandArgs := []string{"/opt/atlassian/confluence/bin/shutdown.sh"}
+
+	t.Run("should rebase known license and keep watching", func(t *testing.T) {
+		// given
+		args := &ProcessArgs{CommandArgs: commandArgs, ConfluenceConfigFile: licFile, Continuous: true}
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("renewed", nil)
+		mockedExecutor := new(executorMock)
+		mockedExecutor.On("execute", commandArgs).Return("", nil)
+
+		sut := defaultWatcher{
+			args:          args,
+			cmdExecutor:   mockedExecutor,
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  "production",
+		}
+
+		// when
+		finishWatcher, err := sut.doWatchWork(context.Background())
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, finishWatcher)
+		assert.Equal(t, "renewed", sut.knownLicense)
+		mockedLicenseChecker.AssertExpectations(t)
+		mockedExecutor.AssertExpectations(t)
+	})
+	t.Run("should keep watching on failed action", func(t *testing.T) {
+		// given
+		args := &ProcessArgs{CommandArgs: commandArgs, ConfluenceConfigFile: licFile, Continuous: true}
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("renewed", nil)
+		mockedExecutor := new(executorMock)
+		mockedExecutor.On("execute", commandArgs).Return("", assert.AnError)
+
+		sut := defaultWatcher{
+			args:          args,
+			cmdExecutor:   mockedExecutor,
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  "production",
+		}
+
+		// when
+		finishWatcher, err := sut.doWatchWork(context.Background())
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, finishWatcher)
+		assert.Equal(t, "renewed", sut.knownLicense)
+		mockedExecutor.AssertExpectations(t)
+	})
+	t.Run("should run the action for each transition", func(t *testing.T) {
+		// given
+		args := &ProcessArgs{CommandArgs: commandArgs, ConfluenceConfigFile: licFile, SetupLicense: "setup", Continuous: true}
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("production", nil).Twice()
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("renewed", nil).Once()
+		mockedExecutor := new(executorMock)
+		mockedExecutor.On("execute", commandArgs).Return("", nil).Twice()
+
+		sut := defaultWatcher{
+			args:          args,
+			cmdExecutor:   mockedExecutor,
+			licenseTester: mockedLicenseChecker,
+			createTrigger: func(string, string, Schedule) (trigger, error) {
+				return newFakeTrigger(2), nil
+			},
+		}
+
+		// when
+		err := sut.Watch(context.Background())
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "renewed", sut.knownLicense)
+		mockedLicenseChecker.AssertExpectations(t)
+		mockedExecutor.AssertExpectations(t)
+	})
+}
+
+func Test_defaultWatcher_doWatchWork_events(t *testing.T) {
+	const licFile = "/var/atlassian/confluence/confluence.cfg.xml"
+	commandArgs := []string{"/opt/atlassian/confluence/bin/shutdown.sh"}
+	production, err := atlassian.Encode(map[string]string{"SEN": "SEN-L1", "LicenseExpiryDate": "2099-01-01"})
+	require.NoError(t, err)
+
+	t.Run("should emit setup-to-production event with decoded licenses", func(t *testing.T) {
+		// given
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return(production, nil)
+		subscribed := newActionMock("notify")
+		subscribed.On("Execute", mock.MatchedBy(func(event *Event) bool {
+			return event.Type == EventSetupToProduction && event.OldLicense == "setup" && event.Old == nil &&
+				event.NewLicense == production && event.New.SEN == "SEN-L1" && event.ConfigFile == licFile
+		})).Return(nil)
+
+		sut := defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: licFile,
+				SetupLicense:         "setup",
+				CommandEvents:        []EventType{EventProductionToSetup},
+				Actions:              []Subscription{{Action: subscribed, Events: []EventType{EventSetupToProduction}}},
+			},
+			cmdExecutor:   new(executorMock),
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  "setup",
+		}
+
+		// when
+		finishWatcher, err := sut.doWatchWork(context.Background())
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, finishWatcher)
+		subscribed.AssertExpectations(t)
+	})
+	t.Run("should keep watching on an event without subscription", func(t *testing.T) {
+		// given
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return(production, nil)
+
+		sut := defaultWatcher{
+			args: &ProcessArgs{
+				CommandArgs:          commandArgs,
+				ConfluenceConfigFile: licFile,
+				SetupLicense:         "setup",
+				CommandEvents:        []EventType{EventProductionToSetup},
+			},
+			cmdExecutor:   new(executorMock),
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  "setup",
+		}
+
+		// when
+		finishWatcher, err := sut.doWatchWork(context.Background())
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, finishWatcher)
+		assert.Equal(t, production, sut.knownLicense)
+	})
+	t.Run("should not execute command by default on removed license", func(t *testing.T) {
+		// given
+		mockedLicenseChecker := new(licenseTesterMock)
+		missingErr := readMissingLicense(t)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("", missingErr).Once()
+		mockedLicenseChecker.On("ReadLicense", licFile).Return(production, nil).Once()
+		mockedExecutor := new(executorMock)
+		mockedExecutor.On("execute", commandArgs).Return("", nil).Once()
+
+		sut := defaultWatcher{
+			args:          &ProcessArgs{CommandArgs: commandArgs, ConfluenceConfigFile: licFile, SetupLicense: "setup"},
+			cmdExecutor:   mockedExecutor,
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  "setup",
+		}
+
+		// when
+		removedDone, removedErr := sut.doWatchWork(context.Background())
+		reappearedDone, reappearedErr := sut.doWatchWork(context.Background())
+
+		// then
+		require.NoError(t, removedErr)
+		assert.False(t, removedDone)
+		require.NoError(t, reappearedErr)
+		assert.True(t, reappearedDone)
+		mockedExecutor.AssertExpectations(t)
+	})
+	t.Run("should emit license-removed once and ignore the same license when it reappears", func(t *testing.T) {
+		// given
+		mockedLicenseChecker := new(licenseTesterMock)
+		missingErr := readMissingLicense(t)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("", missingErr).Twice()
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("setup", nil).Once()
+		mockedExecutor := new(executorMock)
+		removed := newActionMock("notify")
+		removed.On("Execute", mock.MatchedBy(func(event *Event) bool {
+			return event.Type == EventLicenseRemoved && event.OldLicense == "setup"
+		})).Return(nil).Once()
+
+		sut := defaultWatcher{
+			args: &ProcessArgs{
+				CommandArgs:          commandArgs,
+				ConfluenceConfigFile: licFile,
+				SetupLicense:         "setup",
+				Actions:              []Subscription{{Action: removed, Events: []EventType{EventLicenseRemoved}}},
+				Continuous:           true,
+			},
+			cmdExecutor:   mockedExecutor,
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  "setup",
+		}
+
+		// when
+		for i := 0; i < 3; i++ {
+			done, err := sut.doWatchWork(context.Background())
+			require.NoError(t, err)
+			assert.False(t, done)
+		}
+
+		// then
+		assert.Equal(t, "setup", sut.knownLicense)
+		assert.False(t, sut.licenseRemoved)
+		removed.AssertExpectations(t)
+		mockedExecutor.AssertExpectations(t)
+		mockedLicenseChecker.AssertExpectations(t)
+	})
+	t.Run("should not execute command when the same setup license reappears", func(t *testing.T) {
+		// given
+		mockedLicenseChecker := new(licenseTesterMock)
+		missingErr := readMissingLicense(t)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("", missingErr).Once()
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("setup", nil).Once()
+		mockedExecutor := new(executorMock)
+
+		sut := defaultWatcher{
+			args:          &ProcessArgs{CommandArgs: commandArgs, ConfluenceConfigFile: licFile, SetupLicense: "setup"},
+			cmdExecutor:   mockedExecutor,
+			licenseTester: mockedLicenseChecker,
+			knownLicense:  "setup",
+		}
+
+		// when
+		removedDone, removedErr := sut.doWatchWork(context.Background())
+		reappearedDone, reappearedErr := sut.doWatchWork(context.Background())
+
+		// then
+		require.NoError(t, removedErr)
+		assert.False(t, removedDone)
+		require.NoError(t, reappearedErr)
+		assert.False(t, reappearedDone)
+		assert.Equal(t, "setup", sut.knownLicense)
+		mockedExecutor.AssertExpectations(t)
+	})
+}
+
+func Test_defaultWatcher_subscriptions(t *testing.T) {
+	action := newActionMock("signal")
+
+	t.Run("should subscribe command before further actions", func(t *testing.T) {
+		sut := defaultWatcher{args: &ProcessArgs{CommandArgs: []string{"/bin/true"}, Actions: []Subscription{{Action: action}}}}
+
+		actual := sut.subscriptions()
+
+		require.Len(t, actual, 2)
+		assert.Equal(t, "command", actual[0].Action.Name())
+		assert.Equal(t, DefaultCommandEventTypes, actual[0].Events)
+		assert.Equal(t, action, actual[1].Action)
+	})
+	t.Run("should not subscribe command without command arguments", func(t *testing.T) {
+		sut := defaultWatcher{args: &ProcessArgs{Actions: []Subscription{{Action: action}}}}
+
+		actual := sut.subscriptions()
+
+		assert.Equal(t, []Subscription{{Action: action}}, actual)
+	})
+}
+
+// readMissingLicense returns the error of the tester for a config file without license.
+func readMissingLicense(t *testing.T) error {
+	t.Helper()
+
+	configFile := filepath.Join(t.TempDir(), "confluence.cfg.xml")
+	content := `<confluence-configuration><properties><property name="attachments.dir">attachments</property></properties></confluence-configuration>`
+	require.NoError(t, os.WriteFile(configFile, []byte(content), 0644))
+
+	_, err := tester.New().ReadLicense(configFile)
+	require.True(t, tester.IsLicenseMissing(err))
+	require.False(t, errors.Is(err, os.ErrNotExist))
+	return err
+}
+
+// readMissingConfigFile returns the error of the tester for a missing config file.
+func readMissingConfigFile(t *testing.T) error {
+	t.Helper()
+
+	_, err := tester.New().ReadLicense("/does/not/exist/confluence.cfg.xml")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	return err
+}
+
 // test util stuff
 type executorMock struct {
 	mock.Mock
 }
 
-func (e *executorMock) execute(shellCommandArgs []string) (string, error) {
+func (e *executorMock) execute(_ context.Context, shellCommandArgs []string, _ []string) (string, error) {
 	args := e.Called(shellCommandArgs)
 	return args.String(0), args.Error(1)
 }
 
+type funcExecutor struct {
+	fn func(ctx context.Context) error
+}
+
+func (f *funcExecutor) execute(ctx context.Context, _ []string, _ []string) (string, error) {
+	return "", f.fn(ctx)
+}
+
+// fakeTrigger fires a fixed number of times and closes its channel afterwards.
+type fakeTrigger struct {
+	c       chan struct{}
+	err     error
+	stopped bool
+}
+
+func newFakeTrigger(fires int) *fakeTrigger {
+	c := make(chan struct{}, fires)
+	for i := 0; i < fires; i++ {
+		c <- struct{}{}
+	}
+	close(c)
+	return &fakeTrigger{c: c}
+}
+
+func (f *fakeTrigger) C() <-chan struct{} {
+	return f.c
+}
+
+func (f *fakeTrigger) Err() error {
+	return f.err
+}
+
+func (f *fakeTrigger) Stop() {
+	f.stopped = true
+}
+
 type licenseTesterMock struct {
 	mock.Mock
 }
@@ -121,16 +449,411 @@ func (l *licenseTesterMock) HasLicenseChanged(configFile string, knownLicense st
 	return args.Bool(0), args.Error(1)
 }
 
-func (l *licenseTesterMock) HasSetupLicense(configFile string, setupLicense string) (unchanged bool, err error) {
+func (l *licenseTesterMock) CheckSetupLicense(configFile string, setupLicense string) (*tester.Result, error) {
 	args := l.Called(configFile, setupLicense)
-	return args.Bool(0), args.Error(1)
+	result, _ := args.Get(0).(*tester.Result)
+	return result, args.Error(1)
+}
+
+func (l *licenseTesterMock) ReadLicense(configFile string) (license string, err error) {
+	args := l.Called(configFile)
+	return args.String(0), args.Error(1)
+}
+
+func (l *licenseTesterMock) ReadSetupStep(configFile string) (setupStep string, err error) {
+	args := l.Called(configFile)
+	return args.String(0), args.Error(1)
+}
+
+func Test_defaultWatcher_initKnownLicense(t *testing.T) {
+	const licFile = "/var/atlassian/confluence/confluence.cfg.xml"
+
+	t.Run("should use setup license", func(t *testing.T) {
+		mockedLicenseChecker := new(licenseTesterMock)
+		sut := defaultWatcher{
+			args:          &ProcessArgs{ConfluenceConfigFile: licFile, SetupLicense: "setup"},
+			licenseTester: mockedLicenseChecker,
+		}
+
+		// when
+		err := sut.initKnownLicense()
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "setup", sut.knownLicense)
+		mockedLicenseChecker.AssertExpectations(t)
+	})
+	t.Run("should use configured license without setup license in decoded mode", func(t *testing.T) {
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("configured", nil)
+		sut := defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: licFile,
+				Detection:            tester.Detection{Mode: tester.DetectionModeDecoded},
+			},
+			licenseTester: mockedLicenseChecker,
+		}
+
+		// when
+		err := sut.initKnownLicense()
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "configured", sut.knownLicense)
+		mockedLicenseChecker.AssertExpectations(t)
+	})
+	t.Run("should use configured license with setup license fingerprints", func(t *testing.T) {
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("configured", nil)
+		sut := defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: licFile,
+				Detection:            tester.Detection{SetupFingerprints: []string{atlassian.Fingerprint("configured")}},
+			},
+			licenseTester: mockedLicenseChecker,
+		}
+
+		// when
+		err := sut.initKnownLicense()
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "configured", sut.knownLicense)
+		mockedLicenseChecker.AssertExpectations(t)
+	})
+	t.Run("should use configured license without setup license in continuous mode", func(t *testing.T) {
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("configured", nil)
+		sut := defaultWatcher{
+			args:          &ProcessArgs{ConfluenceConfigFile: licFile, Continuous: true},
+			licenseTester: mockedLicenseChecker,
+		}
+
+		// when
+		err := sut.initKnownLicense()
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "configured", sut.knownLicense)
+		mockedLicenseChecker.AssertExpectations(t)
+	})
+	t.Run("should fail without setup license in exact mode", func(t *testing.T) {
+		mockedLicenseChecker := new(licenseTesterMock)
+		sut := defaultWatcher{
+			args:          &ProcessArgs{ConfluenceConfigFile: licFile},
+			licenseTester: mockedLicenseChecker,
+		}
+
+		// when
+		err := sut.initKnownLicense()
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "a setup license is required")
+		mockedLicenseChecker.AssertExpectations(t)
+	})
+	t.Run("should watch for a license if the config file does not exist yet", func(t *testing.T) {
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("", readMissingConfigFile(t))
+		sut := defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: licFile,
+				Detection:            tester.Detection{Mode: tester.DetectionModeDecoded},
+			},
+			licenseTester: mockedLicenseChecker,
+		}
+
+		// when
+		err := sut.initKnownLicense()
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, sut.knownLicense)
+		mockedLicenseChecker.AssertExpectations(t)
+	})
+	t.Run("should fail on read error", func(t *testing.T) {
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("", assert.AnError)
+		sut := defaultWatcher{
+			args: &ProcessArgs{
+				ConfluenceConfigFile: licFile,
+				Detection:            tester.Detection{Mode: tester.DetectionModeDecoded},
+			},
+			licenseTester: mockedLicenseChecker,
+		}
+
+		// when
+		err := sut.initKnownLicense()
+
+		// then
+		require.ErrorIs(t, err, assert.AnError)
+		mockedLicenseChecker.AssertExpectations(t)
+	})
+}
+
+func Test_withGracePeriod(t *testing.T) {
+	t.Run("should cancel the grace period after the parent context", func(t *testing.T) {
+		parent, cancelParent := context.WithCancel(context.Background())
+		sut, cancel := withGracePeriod(parent, 20*time.Millisecond)
+		defer cancel()
+
+		// when
+		cancelParent()
+
+		// then
+		assert.NoError(t, sut.Err())
+		select {
+		case <-sut.Done():
+		case <-time.After(time.Second):
+			t.Fatal("grace period context was not cancelled")
+		}
+	})
+	t.Run("should cancel on cancel func", func(t *testing.T) {
+		sut, cancel := withGracePeriod(context.Background(), time.Hour)
+
+		cancel()
+
+		assert.ErrorIs(t, sut.Err(), context.Canceled)
+	})
 }
 
 func Test_defaultWatcher_Watch(t *testing.T) {
+	const licFile = "/var/atlassian/confluence/confluence.cfg.xml"
+	const license = "AAAB/testLicense+=okBf"
+	commandArgs := []string{"/opt/atlassian/confluence/bin/shutdown.sh"}
+
+	t.Run("should check on each trigger until the license changes", func(t *testing.T) {
+		// given
+		args := &ProcessArgs{
+			CommandArgs:          commandArgs,
+			Schedule:             Schedule{Interval: 30 * time.Second},
+			WatchBackend:         BackendInotify,
+			ConfluenceConfigFile: licFile,
+			SetupLicense:         license,
+		}
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return(license, nil).Once()
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("changedLicense", nil).Once()
+		mockedExecutor := new(executorMock)
+		mockedExecutor.On("execute", commandArgs).Return("", nil)
+		fake := newFakeTrigger(2)
+
+		sut := defaultWatcher{
+			args:          args,
+			cmdExecutor:   mockedExecutor,
+			licenseTester: mockedLicenseChecker,
+			createTrigger: func(backend string, configFile string, schedule Schedule) (trigger, error) {
+				assert.Equal(t, BackendInotify, backend)
+				assert.Equal(t, licFile, configFile)
+				assert.Equal(t, Schedule{Interval: 30 * time.Second}, schedule)
+				return fake, nil
+			},
+		}
+
+		// when
+		err := sut.Watch(context.Background())
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, fake.stopped)
+		mockedLicenseChecker.AssertExpectations(t)
+		mockedExecutor.AssertExpectations(t)
+	})
+	t.Run("should stop when the context is done", func(t *testing.T) {
+		// given
+		args := &ProcessArgs{CommandArgs: commandArgs, ConfluenceConfigFile: licFile, SetupLicense: license}
+		fake := &fakeTrigger{c: make(chan struct{})}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		sut := defaultWatcher{
+			args:          args,
+			cmdExecutor:   new(executorMock),
+			licenseTester: new(licenseTesterMock),
+			createTrigger: func(string, string, Schedule) (trigger, error) {
+				return fake, nil
+			},
+		}
+
+		// when
+		err := sut.Watch(ctx)
+
+		// then
+		require.ErrorIs(t, err, context.Canceled)
+		assert.True(t, fake.stopped)
+	})
+	t.Run("should let a running action finish within the grace period", func(t *testing.T) {
+		// given
+		args := &ProcessArgs{CommandArgs: commandArgs, ConfluenceConfigFile: licFile, SetupLicense: license, GracePeriod: time.Second}
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("changedLicense", nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		actionExecutor := &funcExecutor{fn: func(actionCtx context.Context) error {
+			cancel()
+			select {
+			case <-actionCtx.Done():
+				return actionCtx.Err()
+			case <-time.After(50 * time.Millisecond):
+				return nil
+			}
+		}}
+
+		sut := defaultWatcher{
+			args:          args,
+			cmdExecutor:   actionExecutor,
+			licenseTester: mockedLicenseChecker,
+			createTrigger: func(string, string, Schedule) (trigger, error) {
+				return newFakeTrigger(1), nil
+			},
+		}
+
+		// when
+		err := sut.Watch(ctx)
+
+		// then
+		require.NoError(t, err)
+	})
+	t.Run("should abort a running action after the grace period", func(t *testing.T) {
+		// given
+		args := &ProcessArgs{CommandArgs: commandArgs, ConfluenceConfigFile: licFile, SetupLicense: license, GracePeriod: 10 * time.Millisecond}
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("changedLicense", nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		actionExecutor := &funcExecutor{fn: func(actionCtx context.Context) error {
+			cancel()
+			<-actionCtx.Done()
+			return actionCtx.Err()
+		}}
+
+		sut := defaultWatcher{
+			args:          args,
+			cmdExecutor:   actionExecutor,
+			licenseTester: mockedLicenseChecker,
+			createTrigger: func(string, string, Schedule) (trigger, error) {
+				return newFakeTrigger(1), nil
+			},
+		}
+
+		// when
+		err := sut.Watch(ctx)
+
+		// then
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Contains(t, err.Error(), "stopped while an action was running")
+	})
+	t.Run("should fail when the trigger stops with an error", func(t *testing.T) {
+		// given
+		args := &ProcessArgs{CommandArgs: commandArgs, ConfluenceConfigFile: licFile, SetupLicense: license}
+		fake := newFakeTrigger(0)
+		fake.err = assert.AnError
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return(license, nil)
+
+		sut := defaultWatcher{
+			args:          args,
+			cmdExecutor:   new(executorMock),
+			licenseTester: mockedLicenseChecker,
+			createTrigger: func(string, string, Schedule) (trigger, error) {
+				return fake, nil
+			},
+		}
+
+		// when
+		err := sut.Watch(context.Background())
+
+		// then
+		require.ErrorIs(t, err, assert.AnError)
+		assert.Contains(t, err.Error(), "the watch backend stopped")
+	})
+	t.Run("should fail when the trigger cannot be created", func(t *testing.T) {
+		// given
+		args := &ProcessArgs{CommandArgs: commandArgs, ConfluenceConfigFile: licFile, SetupLicense: license}
+
+		sut := defaultWatcher{
+			args:          args,
+			cmdExecutor:   new(executorMock),
+			licenseTester: new(licenseTesterMock),
+			createTrigger: func(string, string, Schedule) (trigger, error) {
+				return nil, assert.AnError
+			},
+		}
+
+		// when
+		err := sut.Watch(context.Background())
+
+		// then
+		require.ErrorIs(t, err, assert.AnError)
+		assert.Contains(t, err.Error(), "the watch backend cannot be started")
+	})
+	t.Run("should repeat a failed check after the backoff", func(t *testing.T) {
+		// given
+		args := &ProcessArgs{
+			CommandArgs:          commandArgs,
+			ConfluenceConfigFile: licFile,
+			SetupLicense:         license,
+			ErrorPolicy:          ErrorPolicy{GiveUpAfter: 3, Backoff: Backoff{Initial: 10 * time.Millisecond}},
+		}
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("", assert.AnError).Twice()
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("changedLicense", nil).Once()
+		mockedExecutor := new(executorMock)
+		mockedExecutor.On("execute", commandArgs).Return("", nil)
+		fake := &fakeTrigger{c: make(chan struct{}, 1)}
+		fake.c <- struct{}{}
+
+		sut := defaultWatcher{
+			args:          args,
+			cmdExecutor:   mockedExecutor,
+			licenseTester: mockedLicenseChecker,
+			createTrigger: func(string, string, Schedule) (trigger, error) {
+				return fake, nil
+			},
+		}
+
+		// when
+		err := sut.Watch(context.Background())
+
+		// then
+		require.NoError(t, err)
+		assert.Zero(t, sut.failedChecks)
+		mockedLicenseChecker.AssertExpectations(t)
+		mockedExecutor.AssertExpectations(t)
+	})
+	t.Run("should check right away after waiting for the setup", func(t *testing.T) {
+		// given
+		args := &ProcessArgs{
+			CommandArgs:          commandArgs,
+			ConfluenceConfigFile: licFile,
+			SetupLicense:         license,
+			Readiness:            Readiness{Wait: true},
+		}
+		mockedLicenseChecker := new(licenseTesterMock)
+		mockedLicenseChecker.On("ReadSetupStep", licFile).Return("complete", nil)
+		mockedLicenseChecker.On("ReadLicense", licFile).Return("changedLicense", nil)
+		mockedExecutor := new(executorMock)
+		mockedExecutor.On("execute", commandArgs).Return("", nil)
+
+		sut := defaultWatcher{
+			args:          args,
+			cmdExecutor:   mockedExecutor,
+			licenseTester: mockedLicenseChecker,
+			createTrigger: func(string, string, Schedule) (trigger, error) {
+				return &fakeTrigger{c: make(chan struct{})}, nil
+			},
+		}
+
+		// when
+		err := sut.Watch(context.Background())
+
+		// then
+		require.NoError(t, err)
+		mockedLicenseChecker.AssertExpectations(t)
+		mockedExecutor.AssertExpectations(t)
+	})
 	t.Run("should create instance from defaultWatcher", func(t *testing.T) {
 		args := &ProcessArgs{
 			CommandArgs:          []string{},
-			WatchIntervalInSecs:  30,
+			Schedule:             Schedule{Interval: 30 * time.Second},
 			ConfluenceConfigFile: "licFile",
 			SetupLicense:         "license",
 		}
@@ -142,3 +865,15 @@ func Test_defaultWatcher_Watch(t *testing.T) {
 		require.IsType(t, &defaultWatcher{}, sut)
 	})
 }
+
+func TestProcessArgs_String(t *testing.T) {
+	const setupLicense = "AAABrQ0ODAoPeNqFkV1PgjAUhu/7K5p4jWHgF0m9QHNhvmcBc0gEX02"
+	args := &ProcessArgs{CommandArgs: []string{"/bin/true"}, SetupLicense: setupLicense}
+
+	actual := fmt.Sprintf("%v", args)
+
+	assert.Contains(t, actual, "SetupLicense:"+atlassian.Redact(setupLicense))
+	assert.Contains(t, actual, "CommandArgs:[/bin/true]")
+	assert.NotContains(t, actual, setupLicense)
+	assert.Equal(t, setupLicense, args.SetupLicense)
+}
@@ -0,0 +1,186 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// Payload formats of the webhook action.
+const (
+	// WebhookFormatJSON posts the Notification as JSON document.
+	WebhookFormatJSON = "json"
+	// WebhookFormatSlack posts a message for Slack incoming webhooks.
+	WebhookFormatSlack = "slack"
+	// WebhookFormatMattermost posts a message for Mattermost incoming webhooks.
+	WebhookFormatMattermost = "mattermost"
+)
+
+// WebhookSignatureHeader contains the HMAC-SHA256 signature of the request body in the form sha256=<hex digest>.
+const WebhookSignatureHeader = "X-License-Checker-Signature"
+
+// webhookUsername is the name under which messages appear in chat systems.
+const webhookUsername = "license-checker"
+
+// webhookResponseLimit limits how much of an error response is added to the error message.
+const webhookResponseLimit = 512
+
+// webhookMessageTemplate is the text of chat messages.
+var webhookMessageTemplate = template.Must(template.New("message").Parse(
+	"Confluence license event `{{.Event}}` on {{.Host}} ({{.ConfigFile}})" +
+		"{{if .Error}}: {{.Error}}{{else}}{{with .NewLicense}}{{if .Expiry}}, the license expires on {{.Expiry}}{{end}}{{end}}{{end}}"))
+
+// WebhookOptions configures the webhook action.
+type WebhookOptions struct {
+	// URL is the address to which the payload is posted.
+	URL string
+	// Headers are added to each request, e.g. for authorization.
+	Headers http.Header
+	// Secret signs the request body with HMAC-SHA256 in the WebhookSignatureHeader. No signature is sent if empty.
+	Secret string
+	// Format is one of WebhookFormatJSON, WebhookFormatSlack or WebhookFormatMattermost. An empty format is treated
+	// as WebhookFormatJSON. It is ignored if a template is given.
+	Format string
+	// Template is a Go template which renders the request body from the Notification. The function json encodes a
+	// value as JSON, e.g. {{json .Host}}.
+	Template string
+	// Retry configures the timeout of a single request and the retries. Its success exit codes are ignored.
+	Retry RetryPolicy
+}
+
+// webhookAction posts license events to an HTTP endpoint.
+type webhookAction struct {
+	options  WebhookOptions
+	template *template.Template
+	client   *http.Client
+	host     string
+}
+
+// NewWebhookAction creates an action which posts license events to the URL of the given options.
+func NewWebhookAction(options WebhookOptions) (Action, error) {
+	if options.URL == "" {
+		return nil, errors.New("a webhook URL must be given")
+	}
+	if !strings.HasPrefix(options.URL, "http://") && !strings.HasPrefix(options.URL, "https://") {
+		return nil, errors.Errorf("webhook URL '%s' must use http or https", options.URL)
+	}
+
+	action := &webhookAction{options: options, client: &http.Client{}}
+	switch {
+	case options.Template != "":
+		bodyTemplate, err := template.New("webhook").Funcs(template.FuncMap{"json": toJSON}).Parse(options.Template)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse webhook template")
+		}
+		action.template = bodyTemplate
+	case options.Format == "", options.Format == WebhookFormatJSON, options.Format == WebhookFormatSlack,
+		options.Format == WebhookFormatMattermost:
+	default:
+		return nil, errors.Errorf("unknown webhook format '%s'", options.Format)
+	}
+
+	action.host = hostname()
+	return action, nil
+}
+
+func (wa *webhookAction) Name() string {
+	return "webhook"
+}
+
+func (wa *webhookAction) Execute(ctx context.Context, event *Event) error {
+	body, err := wa.render(newNotification(event, wa.host))
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("posting event '%s' to webhook", event.Type)
+	return retry(ctx, wa.options.Retry, description, func(ctx context.Context) error {
+		return wa.post(ctx, body)
+	})
+}
+
+func (wa *webhookAction) render(payload *Notification) ([]byte, error) {
+	if wa.template != nil {
+		var body bytes.Buffer
+		err := wa.template.Execute(&body, payload)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to render webhook template")
+		}
+		return body.Bytes(), nil
+	}
+
+	switch wa.options.Format {
+	case WebhookFormatSlack, WebhookFormatMattermost:
+		var text strings.Builder
+		err := webhookMessageTemplate.Execute(&text, payload)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to render webhook message")
+		}
+
+		message := map[string]string{"text": text.String()}
+		if wa.options.Format == WebhookFormatMattermost {
+			message["username"] = webhookUsername
+		}
+		return json.Marshal(message)
+	default:
+		return json.Marshal(payload)
+	}
+}
+
+func (wa *webhookAction) post(ctx context.Context, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, wa.options.URL, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{cause: errors.Wrap(err, "failed to create webhook request")}
+	}
+
+	for name, values := range wa.options.Headers {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+	if request.Header.Get("Content-Type") == "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if wa.options.Secret != "" {
+		request.Header.Set(WebhookSignatureHeader, "sha256="+sign(body, wa.options.Secret))
+	}
+
+	response, err := wa.client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "failed to send webhook request")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		log.Infof("Webhook accepted the event with status %d", response.StatusCode)
+		return nil
+	}
+
+	responseBody, _ := io.ReadAll(io.LimitReader(response.Body, webhookResponseLimit))
+	err = errors.Errorf("webhook responded with status %d: %s", response.StatusCode, strings.TrimSpace(string(responseBody)))
+	if isPermanentStatus(response.StatusCode) {
+		return &permanentError{cause: err}
+	}
+	return err
+}
+
+// isPermanentStatus checks whether a request failed with a client error which a retry would not fix.
+func isPermanentStatus(statusCode int) bool {
+	return statusCode >= 400 && statusCode < 500 && statusCode != http.StatusRequestTimeout &&
+		statusCode != http.StatusTooManyRequests
+}
+
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,195 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewWebhookAction(t *testing.T) {
+	_, err := NewWebhookAction(WebhookOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a webhook URL must be given")
+
+	_, err = NewWebhookAction(WebhookOptions{URL: "ftp://example.com"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must use http or https")
+
+	_, err = NewWebhookAction(WebhookOptions{URL: "https://example.com", Format: "teams"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown webhook format 'teams'")
+
+	_, err = NewWebhookAction(WebhookOptions{URL: "https://example.com", Template: "{{.Event"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse webhook template")
+}
+
+func Test_webhookAction_Execute(t *testing.T) {
+	newLicense, err := atlassian.Encode(map[string]string{"LicenseExpiryDate": "2027-03-31"})
+	require.NoError(t, err)
+	event := &Event{
+		Type:       EventSetupToProduction,
+		Time:       time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC),
+		ConfigFile: "confluence.cfg.xml",
+		OldLicense: "setup",
+		NewLicense: newLicense,
+		New:        decodeOrNil(newLicense),
+	}
+
+	t.Run("should post signed JSON payload with headers", func(t *testing.T) {
+		// given
+		server := newWebhookServer(t, http.StatusNoContent)
+		sut := newTestWebhookAction(t, WebhookOptions{
+			URL:     server.URL,
+			Headers: http.Header{"Authorization": []string{"Bearer token"}},
+			Secret:  "secret",
+		})
+
+		// when
+		err := sut.Execute(context.Background(), event)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, server.requests, 1)
+		request := server.requests[0]
+		assert.Equal(t, "Bearer token", request.header.Get("Authorization"))
+		assert.Equal(t, "application/json", request.header.Get("Content-Type"))
+		assert.Equal(t, "sha256="+sign(request.body, "secret"), request.header.Get(WebhookSignatureHeader))
+
+		daysLeft := event.New.DaysLeft(event.Time)
+		var payload Notification
+		require.NoError(t, json.Unmarshal(request.body, &payload))
+		expected := Notification{
+			Event:      EventSetupToProduction,
+			Time:       event.Time,
+			Host:       "dogu-host",
+			ConfigFile: "confluence.cfg.xml",
+			OldLicense: &LicenseSummary{SHA256: atlassian.Fingerprint("setup")},
+			NewLicense: &LicenseSummary{SHA256: atlassian.Fingerprint(newLicense), Expiry: "2027-03-31", DaysLeft: &daysLeft},
+		}
+		assert.Equal(t, expected, payload)
+		assert.NotContains(t, string(request.body), newLicense)
+	})
+	t.Run("should post Mattermost message", func(t *testing.T) {
+		server := newWebhookServer(t, http.StatusOK)
+		sut := newTestWebhookAction(t, WebhookOptions{URL: server.URL, Format: WebhookFormatMattermost})
+
+		err := sut.Execute(context.Background(), event)
+
+		require.NoError(t, err)
+		require.Len(t, server.requests, 1)
+		expected := `{"text":"Confluence license event ` + "`setup-to-production`" +
+			` on dogu-host (confluence.cfg.xml), the license expires on 2027-03-31","username":"license-checker"}`
+		assert.JSONEq(t, expected, string(server.requests[0].body))
+	})
+	t.Run("should post Slack message", func(t *testing.T) {
+		server := newWebhookServer(t, http.StatusOK)
+		sut := newTestWebhookAction(t, WebhookOptions{URL: server.URL, Format: WebhookFormatSlack})
+
+		err := sut.Execute(context.Background(), &Event{Type: EventLicenseRemoved, ConfigFile: "confluence.cfg.xml", OldLicense: "setup"})
+
+		require.NoError(t, err)
+		expected := `{"text":"Confluence license event ` + "`license-removed`" + ` on dogu-host (confluence.cfg.xml)"}`
+		assert.JSONEq(t, expected, string(server.requests[0].body))
+	})
+	t.Run("should render custom template", func(t *testing.T) {
+		server := newWebhookServer(t, http.StatusOK)
+		sut := newTestWebhookAction(t, WebhookOptions{URL: server.URL, Template: `{"summary": {{json .Event}}, "expiry": {{json .NewLicense.Expiry}}}`})
+
+		err := sut.Execute(context.Background(), event)
+
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"summary": "setup-to-production", "expiry": "2027-03-31"}`, string(server.requests[0].body))
+	})
+	t.Run("should retry on server errors", func(t *testing.T) {
+		server := newWebhookServer(t, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK)
+		sut := newTestWebhookAction(t, WebhookOptions{URL: server.URL, Retry: RetryPolicy{Retries: 3, Backoff: Backoff{Initial: time.Millisecond}}})
+
+		err := sut.Execute(context.Background(), event)
+
+		require.NoError(t, err)
+		assert.Len(t, server.requests, 3)
+	})
+	t.Run("should not retry on client errors", func(t *testing.T) {
+		server := newWebhookServer(t, http.StatusUnauthorized)
+		sut := newTestWebhookAction(t, WebhookOptions{URL: server.URL, Retry: RetryPolicy{Retries: 3, Backoff: Backoff{Initial: time.Millisecond}}})
+
+		err := sut.Execute(context.Background(), event)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "webhook responded with status 401: denied")
+		assert.Len(t, server.requests, 1)
+	})
+	t.Run("should time out slow requests", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			select {
+			case <-request.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}))
+		defer server.Close()
+		sut := newTestWebhookAction(t, WebhookOptions{URL: server.URL, Retry: RetryPolicy{Timeout: 50 * time.Millisecond}})
+
+		err := sut.Execute(context.Background(), event)
+
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, "webhook", sut.Name())
+	})
+}
+
+// test util stuff
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+type webhookServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests []webhookRequest
+}
+
+// newWebhookServer records all requests and responds with the given status codes in turn. The last status code is
+// repeated.
+func newWebhookServer(t *testing.T, statusCodes ...int) *webhookServer {
+	t.Helper()
+
+	server := &webhookServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+
+		server.mutex.Lock()
+		server.requests = append(server.requests, webhookRequest{header: request.Header, body: body})
+		index := len(server.requests) - 1
+		server.mutex.Unlock()
+
+		if index >= len(statusCodes) {
+			index = len(statusCodes) - 1
+		}
+		writer.WriteHeader(statusCodes[index])
+		if statusCodes[index] == http.StatusUnauthorized {
+			_, _ = writer.Write([]byte("denied\n"))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestWebhookAction(t *testing.T, options WebhookOptions) *webhookAction {
+	t.Helper()
+
+	action, err := NewWebhookAction(options)
+	require.NoError(t, err)
+	webhook := action.(*webhookAction)
+	webhook.host = "dogu-host"
+	return webhook
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedLogFileLayout is appended to the name of a rotated log file.
+const rotatedLogFileLayout = "20060102-150405.000"
+
+// rotatingFile is a log file which is rotated when it exceeds a maximum size or is older than the rotation interval.
+// A rotated file is renamed with the time of the rotation as suffix, and only the newest backups are kept.
+type rotatingFile struct {
+	mutex    sync.Mutex
+	path     string
+	maxSize  int64
+	interval time.Duration
+	backups  int
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	now      func() time.Time
+}
+
+// openRotatingFile opens the log file for appending. A zero size or interval disables the rotation by size or time.
+func openRotatingFile(path string, maxSize int64, interval time.Duration, backups int) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, maxSize: maxSize, interval: interval, backups: backups, now: time.Now}
+	err := rf.open()
+	if err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	err := os.MkdirAll(filepath.Dir(rf.path), 0755)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create directory of log file '%s'", rf.path)
+	}
+
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open log file '%s'", rf.path)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return errors.Wrapf(err, "failed to inspect log file '%s'", rf.path)
+	}
+
+	rf.file = file
+	rf.size = info.Size()
+	rf.openedAt = rf.now()
+	if rf.size > 0 {
+		rf.openedAt = rf.lastRotation(info.ModTime())
+	}
+	return nil
+}
+
+// lastRotation returns the time at which the existing log file was started, so that the rotation interval continues
+// across restarts. This is the time of the newest rotation or, if the file was never rotated, its modification time.
+func (rf *rotatingFile) lastRotation(modTime time.Time) time.Time {
+	backups := rf.backupFiles()
+	if len(backups) == 0 {
+		return modTime
+	}
+
+	newest := backups[len(backups)-1]
+	rotatedAt, err := time.ParseInLocation(rotatedLogFileLayout, strings.TrimPrefix(newest, rf.path+"."), time.Local)
+	if err != nil || rotatedAt.After(modTime) {
+		return modTime
+	}
+	return rotatedAt
+}
+
+// Write appends to the log file and rotates it beforehand if necessary. If the rotation fails, the line is still
+// written to the current log file and the error of the rotation is returned.
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+
+	var rotateErr error
+	if rf.file != nil && rf.needsRotation(int64(len(p))) {
+		rotateErr = rf.rotate()
+	}
+	if rf.file == nil {
+		err := rf.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+func (rf *rotatingFile) needsRotation(writeSize int64) bool {
+	if rf.size == 0 {
+		return false
+	}
+	if rf.maxSize > 0 && rf.size+writeSize > rf.maxSize {
+		return true
+	}
+	return rf.interval > 0 && rf.now().Sub(rf.openedAt) >= rf.interval
+}
+
+// rotate closes the log file and renames it. The caller opens a new log file afterwards, or the current one again if
+// the rename failed.
+func (rf *rotatingFile) rotate() error {
+	err := rf.file.Close()
+	rf.file = nil
+	if err != nil {
+		return errors.Wrapf(err, "failed to close log file '%s'", rf.path)
+	}
+
+	err = os.Rename(rf.path, rf.path+"."+rf.now().Format(rotatedLogFileLayout))
+	if err != nil {
+		return errors.Wrapf(err, "failed to rotate log file '%s'", rf.path)
+	}
+
+	rf.removeOldBackups()
+	return nil
+}
+
+// backupFiles returns the rotated files from oldest to newest. Other files which start with the name of the log file
+// are ignored.
+func (rf *rotatingFile) backupFiles() []string {
+	candidates, err := filepath.Glob(rf.path + ".*")
+	if err != nil {
+		return nil
+	}
+
+	var backups []string
+	for _, candidate := range candidates {
+		_, err := time.Parse(rotatedLogFileLayout, strings.TrimPrefix(candidate, rf.path+"."))
+		if err == nil {
+			backups = append(backups, candidate)
+		}
+	}
+
+	// the suffix sorts chronologically
+	sort.Strings(backups)
+	return backups
+}
+
+// removeOldBackups removes all rotated files except the newest ones. A failure does not stop logging.
+func (rf *rotatingFile) removeOldBackups() {
+	backups := rf.backupFiles()
+	if len(backups) <= rf.backups {
+		return
+	}
+
+	for _, backup := range backups[:len(backups)-rf.backups] {
+		_ = os.Remove(backup)
+	}
+}
+
+// Close closes the current log file.
+func (rf *rotatingFile) Close() error {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+
+	if rf.file == nil {
+		return nil
+	}
+	return rf.file.Close()
+}
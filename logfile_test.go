@@ -0,0 +1,164 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_rotatingFile(t *testing.T) {
+	t.Run("should append to an existing file", func(t *testing.T) {
+		// given
+		path := filepath.Join(t.TempDir(), "license-checker.log")
+		require.NoError(t, os.WriteFile(path, []byte("first\n"), 0644))
+		sut, err := openRotatingFile(path, 0, 0, 1)
+		require.NoError(t, err)
+		defer sut.Close()
+
+		// when
+		_, err = sut.Write([]byte("second\n"))
+
+		// then
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "first\nsecond\n", string(content))
+	})
+	t.Run("should rotate by size and keep the newest backups", func(t *testing.T) {
+		// given
+		path := filepath.Join(t.TempDir(), "license-checker.log")
+		sut, err := openRotatingFile(path, 10, 0, 2)
+		require.NoError(t, err)
+		defer sut.Close()
+		now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+		sut.now = func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		}
+
+		// when
+		for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+			_, err = sut.Write([]byte(line))
+			require.NoError(t, err)
+		}
+
+		// then
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "line 4\n", string(content))
+		backups, err := filepath.Glob(path + ".*")
+		require.NoError(t, err)
+		require.Len(t, backups, 2)
+		content, err = os.ReadFile(backups[1])
+		require.NoError(t, err)
+		assert.Equal(t, "line 3\n", string(content))
+	})
+	t.Run("should rotate by time", func(t *testing.T) {
+		// given
+		path := filepath.Join(t.TempDir(), "license-checker.log")
+		sut, err := openRotatingFile(path, 0, time.Hour, 5)
+		require.NoError(t, err)
+		defer sut.Close()
+		start := sut.openedAt
+		_, err = sut.Write([]byte("old\n"))
+		require.NoError(t, err)
+
+		// when
+		sut.now = func() time.Time { return start.Add(time.Hour) }
+		_, err = sut.Write([]byte("new\n"))
+
+		// then
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "new\n", string(content))
+		assert.FileExists(t, path+"."+start.Add(time.Hour).Format(rotatedLogFileLayout))
+	})
+	t.Run("should continue the rotation interval after a restart", func(t *testing.T) {
+		// given
+		path := filepath.Join(t.TempDir(), "license-checker.log")
+		require.NoError(t, os.WriteFile(path, []byte("before restart\n"), 0644))
+		startedAt := time.Now().Add(-2 * time.Hour)
+		require.NoError(t, os.Chtimes(path, startedAt, startedAt))
+		sut, err := openRotatingFile(path, 0, time.Hour, 5)
+		require.NoError(t, err)
+		defer sut.Close()
+
+		// when
+		_, err = sut.Write([]byte("after restart\n"))
+
+		// then
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "after restart\n", string(content))
+	})
+	t.Run("should take the start of the log file from the newest backup", func(t *testing.T) {
+		// given
+		path := filepath.Join(t.TempDir(), "license-checker.log")
+		rotatedAt := time.Now().Add(-30 * time.Minute).Truncate(time.Millisecond)
+		require.NoError(t, os.WriteFile(path+"."+rotatedAt.Format(rotatedLogFileLayout), []byte("old\n"), 0644))
+		require.NoError(t, os.WriteFile(path, []byte("current\n"), 0644))
+
+		// when
+		sut, err := openRotatingFile(path, 0, time.Hour, 5)
+
+		// then
+		require.NoError(t, err)
+		defer sut.Close()
+		assert.True(t, rotatedAt.Equal(sut.openedAt))
+	})
+	t.Run("should keep writing to the log file if it cannot be renamed", func(t *testing.T) {
+		// given
+		path := filepath.Join(t.TempDir(), "license-checker.log")
+		sut, err := openRotatingFile(path, 10, 0, 5)
+		require.NoError(t, err)
+		defer sut.Close()
+		now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+		sut.now = func() time.Time { return now }
+		// a non-empty directory with the name of the backup lets the rename fail
+		blocked := path + "." + now.Format(rotatedLogFileLayout)
+		require.NoError(t, os.MkdirAll(filepath.Join(blocked, "keep"), 0755))
+		_, err = sut.Write([]byte("line 1\n"))
+		require.NoError(t, err)
+
+		// when
+		_, rotateErr := sut.Write([]byte("line 2\n"))
+		_, err = sut.Write([]byte("line 3\n"))
+
+		// then
+		require.Error(t, rotateErr)
+		assert.Contains(t, rotateErr.Error(), "failed to rotate log file")
+		require.Error(t, err)
+		content, readErr := os.ReadFile(path)
+		require.NoError(t, readErr)
+		assert.Equal(t, "line 1\nline 2\nline 3\n", string(content))
+	})
+	t.Run("should not remove unrelated files when removing old backups", func(t *testing.T) {
+		// given
+		path := filepath.Join(t.TempDir(), "license-checker.log")
+		unrelated := path + ".conf"
+		require.NoError(t, os.WriteFile(unrelated, []byte("keep\n"), 0644))
+		sut, err := openRotatingFile(path, 10, 0, 1)
+		require.NoError(t, err)
+		defer sut.Close()
+		now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+		sut.now = func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		}
+
+		// when
+		for _, line := range []string{"line 1\n", "line 2\n", "line 3\n"} {
+			_, err = sut.Write([]byte(line))
+			require.NoError(t, err)
+		}
+
+		// then
+		assert.FileExists(t, unrelated)
+		assert.Len(t, sut.backupFiles(), 1)
+	})
+}
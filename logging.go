@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/cloudogu/confluence-license-checker/license/watcher"
+	"github.com/op/go-logging"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"io"
+	"os"
+)
+
+const (
+	logLevelFlagName       = "log-level"
+	logFormatFlagName      = "log-format"
+	logFormatEnvVarName    = "LOG_FORMAT"
+	logOutputFlagName      = "log-output"
+	logOutputEnvVarName    = "LOG_OUTPUT"
+	logFileFlagName        = "log-file"
+	logFileEnvVarName      = "LOG_FILE"
+	logFileMaxSizeFlagName = "log-file-max-size"
+	logFileMaxSizeEnvVar   = "LOG_FILE_MAX_SIZE"
+	logFileIntervalFlag    = "log-file-rotate-interval"
+	logFileIntervalEnvVar  = "LOG_FILE_ROTATE_INTERVAL"
+	logFileBackupsFlagName = "log-file-backups"
+	logFileBackupsEnvVar   = "LOG_FILE_BACKUPS"
+
+	logFormatText = "text"
+	logFormatJSON = "json"
+
+	logOutputStdout   = "stdout"
+	logOutputFile     = "file"
+	logOutputSyslog   = "syslog"
+	logOutputJournald = "journald"
+
+	// logIdentifier is the name under which log lines appear in syslog and the journal.
+	logIdentifier = "license-checker"
+	// logTimeLayout is RFC 3339 with milliseconds.
+	logTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// textLogFormat is the format of log lines in the text format.
+var textLogFormat = logging.MustStringFormatter(
+	`%{time:` + logTimeLayout + `} %{module} %{shortfunc} ▶ %{level:.4s} %{id:03x} %{message}`,
+)
+
+func createLoggingFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  logLevelFlagName,
+			Usage: "define log level",
+			Value: "warning",
+		},
+		&cli.StringFlag{
+			Name:    logFormatFlagName,
+			Usage:   "the format of log lines, one of text or json",
+			EnvVars: []string{logFormatEnvVarName},
+			Value:   logFormatText,
+		},
+		&cli.StringFlag{
+			Name:    logOutputFlagName,
+			Usage:   "where log lines are written to, one of stdout, file, syslog or journald",
+			EnvVars: []string{logOutputEnvVarName},
+			Value:   logOutputStdout,
+		},
+		&cli.StringFlag{
+			Name:    logFileFlagName,
+			Usage:   "the log file if the log output is file",
+			EnvVars: []string{logFileEnvVarName},
+		},
+		&cli.IntFlag{
+			Name:    logFileMaxSizeFlagName,
+			Usage:   "rotate the log file when it exceeds this size in megabytes; 0 disables rotation by size",
+			EnvVars: []string{logFileMaxSizeEnvVar},
+			Value:   10,
+		},
+		&cli.DurationFlag{
+			Name:    logFileIntervalFlag,
+			Usage:   "rotate the log file after this duration, e.g. 24h; 0 disables rotation by time",
+			EnvVars: []string{logFileIntervalEnvVar},
+		},
+		&cli.IntFlag{
+			Name:    logFileBackupsFlagName,
+			Usage:   "the number of rotated log files which are kept",
+			EnvVars: []string{logFileBackupsEnvVar},
+			Value:   5,
+		},
+	}
+}
+
+func configureLogging(c *cli.Context) error {
+	logLevel, err := logging.LogLevel(c.String(logLevelFlagName))
+	if err != nil {
+		fmt.Println("invalid log level specified, please use critical, error, warning, notice, info or debug")
+		return errors.Wrap(err, "failed to configure logging")
+	}
+
+	backend, err := createLogBackend(c, confluenceConfigFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to configure logging")
+	}
+
+	logging.SetBackend(backend)
+	logging.SetLevel(logLevel, "")
+	return nil
+}
+
+// createLogBackend creates the backend for the configured log output and format. All backends add the config file
+// to the structured fields of each log line.
+func createLogBackend(c *cli.Context, configFile string) (logging.Backend, error) {
+	var formatter logging.Formatter
+	switch format := c.String(logFormatFlagName); format {
+	case logFormatText:
+		formatter = &textLogFormatter{configFile: configFile}
+	case logFormatJSON:
+		formatter = &jsonLogFormatter{configFile: configFile}
+	default:
+		return nil, errors.Errorf("unsupported log format '%s', use %s or %s", format, logFormatText, logFormatJSON)
+	}
+
+	switch output := c.String(logOutputFlagName); output {
+	case logOutputStdout:
+		return logging.NewBackendFormatter(logging.NewLogBackend(os.Stdout, "", 0), formatter), nil
+	case logOutputFile:
+		file, err := createRotatingFile(c)
+		if err != nil {
+			return nil, err
+		}
+		return logging.NewBackendFormatter(logging.NewLogBackend(file, "", 0), formatter), nil
+	case logOutputSyslog:
+		backend, err := logging.NewSyslogBackend(logIdentifier)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to connect to syslog")
+		}
+		return logging.NewBackendFormatter(backend, formatter), nil
+	case logOutputJournald:
+		backend, err := newJournaldBackend(configFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to connect to journald")
+		}
+		return backend, nil
+	default:
+		return nil, errors.Errorf("unsupported log output '%s', use %s, %s, %s or %s",
+			output, logOutputStdout, logOutputFile, logOutputSyslog, logOutputJournald)
+	}
+}
+
+func createRotatingFile(c *cli.Context) (io.Writer, error) {
+	path := c.String(logFileFlagName)
+	if path == "" {
+		return nil, errors.Errorf("flag '--%s' is required for log output '%s'", logFileFlagName, logOutputFile)
+	}
+
+	maxSize := c.Int(logFileMaxSizeFlagName)
+	interval := c.Duration(logFileIntervalFlag)
+	backups := c.Int(logFileBackupsFlagName)
+	if maxSize < 0 || interval < 0 || backups < 0 {
+		return nil, errors.Errorf("values for flags '--%s', '--%s' and '--%s' must not be negative",
+			logFileMaxSizeFlagName, logFileIntervalFlag, logFileBackupsFlagName)
+	}
+
+	return openRotatingFile(path, int64(maxSize)*1024*1024, interval, backups)
+}
+
+// logFields are the structured fields of a log line.
+type logFields struct {
+	// Component is the module of the logger, e.g. watcher.
+	Component string
+	// ConfigFile is the watched Confluence config file.
+	ConfigFile string
+	// EventType is the type of the license event which the log line is about. It is empty if the line is not about an
+	// event.
+	EventType string
+}
+
+// fieldsOf collects the structured fields of a log record. The event type is taken from the first argument of the
+// record which is an event type.
+func fieldsOf(record *logging.Record, configFile string) logFields {
+	fields := logFields{Component: record.Module, ConfigFile: configFile}
+	for _, arg := range record.Args {
+		if eventType, ok := arg.(watcher.EventType); ok {
+			fields.EventType = string(eventType)
+			break
+		}
+	}
+	return fields
+}
+
+// textLogFormatter formats each log record with textLogFormat and appends the config file and the event as
+// config=... and event=... if they are set.
+type textLogFormatter struct {
+	configFile string
+}
+
+func (f *textLogFormatter) Format(calldepth int, record *logging.Record, output io.Writer) error {
+	fields := fieldsOf(record, f.configFile)
+	line := &bytes.Buffer{}
+	err := textLogFormat.Format(calldepth+1, record, line)
+	if err != nil {
+		return err
+	}
+
+	if fields.ConfigFile != "" {
+		_, _ = fmt.Fprintf(line, " config=%s", fields.ConfigFile)
+	}
+	if fields.EventType != "" {
+		_, _ = fmt.Fprintf(line, " event=%s", fields.EventType)
+	}
+	_, err = output.Write(line.Bytes())
+	return err
+}
+
+// jsonLogRecord is a log line in the JSON format.
+type jsonLogRecord struct {
+	Time       string `json:"time"`
+	Level      string `json:"level"`
+	Component  string `json:"component"`
+	ConfigFile string `json:"configFile,omitempty"`
+	Event      string `json:"event,omitempty"`
+	Message    string `json:"message"`
+}
+
+// jsonLogFormatter formats each log record as a JSON object on a single line.
+type jsonLogFormatter struct {
+	configFile string
+}
+
+func (f *jsonLogFormatter) Format(_ int, record *logging.Record, output io.Writer) error {
+	fields := fieldsOf(record, f.configFile)
+	line := &bytes.Buffer{}
+	encoder := json.NewEncoder(line)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(jsonLogRecord{
+		Time:       record.Time.Format(logTimeLayout),
+		Level:      record.Level.String(),
+		Component:  fields.Component,
+		ConfigFile: fields.ConfigFile,
+		Event:      fields.EventType,
+		Message:    record.Message(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = output.Write(bytes.TrimSuffix(line.Bytes(), []byte("\n")))
+	return err
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/cloudogu/confluence-license-checker/license/watcher"
+	"github.com/op/go-logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_createLogBackend(t *testing.T) {
+	t.Run("should log to stdout by default", func(t *testing.T) {
+		var actual logging.Backend
+		err := runWithFlags(createLoggingFlags(), []string{}, func(c *cli.Context) (err error) {
+			actual, err = createLogBackend(c, confluenceConfigFile)
+			return err
+		})
+
+		require.NoError(t, err)
+		assert.NotNil(t, actual)
+	})
+	t.Run("should log to file", func(t *testing.T) {
+		logFile := filepath.Join(t.TempDir(), "logs", "license-checker.log")
+		args := []string{"--log-output", "file", "--log-file", logFile, "--log-format", "json"}
+		err := runWithFlags(createLoggingFlags(), args, func(c *cli.Context) error {
+			backend, err := createLogBackend(c, confluenceConfigFile)
+			if err != nil {
+				return err
+			}
+			return backend.Log(logging.INFO, 0, newTestLogRecord("watcher", "started"))
+		})
+
+		require.NoError(t, err)
+		content, err := os.ReadFile(logFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), `"message":"started"`)
+	})
+	t.Run("should fail on file output without file", func(t *testing.T) {
+		err := runWithFlags(createLoggingFlags(), []string{"--log-output", "file"}, func(c *cli.Context) error {
+			_, err := createLogBackend(c, confluenceConfigFile)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "flag '--log-file' is required")
+	})
+	t.Run("should read the rotation of the log file from the environment", func(t *testing.T) {
+		t.Setenv("LOG_FILE_BACKUPS", "-1")
+		args := []string{"--log-output", "file", "--log-file", filepath.Join(t.TempDir(), "license-checker.log")}
+		err := runWithFlags(createLoggingFlags(), args, func(c *cli.Context) error {
+			_, err := createLogBackend(c, confluenceConfigFile)
+			return err
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must not be negative")
+	})
+	t.Run("should fail on unsupported format and output", func(t *testing.T) {
+		for _, args := range [][]string{{"--log-format", "xml"}, {"--log-output", "kafka"}} {
+			err := runWithFlags(createLoggingFlags(), args, func(c *cli.Context) error {
+				_, err := createLogBackend(c, confluenceConfigFile)
+				return err
+			})
+
+			require.Error(t, err, args)
+			assert.Contains(t, err.Error(), "unsupported log", args)
+		}
+	})
+}
+
+func Test_jsonLogFormatter_Format(t *testing.T) {
+	t.Run("should write structured fields", func(t *testing.T) {
+		// given
+		record := newTestLogRecord("watcher", "Found license change '%s'.", watcher.EventSetupToProduction)
+		sut := &jsonLogFormatter{configFile: "/confluence.cfg.xml"}
+		buffer := &bytes.Buffer{}
+
+		// when
+		err := sut.Format(0, record, buffer)
+
+		// then
+		require.NoError(t, err)
+		actual := map[string]string{}
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &actual))
+		expected := map[string]string{
+			"time":       "2026-10-18T14:30:00.000+02:00",
+			"level":      "INFO",
+			"component":  "watcher",
+			"configFile": "/confluence.cfg.xml",
+			"event":      "setup-to-production",
+			"message":    "Found license change 'setup-to-production'.",
+		}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should omit event if the line is not about an event", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+
+		err := (&jsonLogFormatter{}).Format(0, newTestLogRecord("main", "line\nbreak"), buffer)
+
+		require.NoError(t, err)
+		assert.NotContains(t, buffer.String(), `"event"`)
+		assert.NotContains(t, buffer.String(), "\n")
+	})
+}
+
+func Test_textLogFormatter_Format(t *testing.T) {
+	t.Run("should append config file and event", func(t *testing.T) {
+		// given
+		record := newTestLogRecord("watcher", "Found license change '%s'.", watcher.EventSetupToProduction)
+		sut := &textLogFormatter{configFile: "/confluence.cfg.xml"}
+		buffer := &bytes.Buffer{}
+
+		// when
+		err := sut.Format(0, record, buffer)
+
+		// then
+		require.NoError(t, err)
+		assert.Regexp(t, `^2026-10-18T14:30:00\.000\+02:00 watcher .* ▶ INFO .* Found license change 'setup-to-production'\. `+
+			`config=/confluence\.cfg\.xml event=setup-to-production$`, buffer.String())
+	})
+	t.Run("should omit fields which are not set", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+
+		err := (&textLogFormatter{}).Format(0, newTestLogRecord("tester", "hello"), buffer)
+
+		require.NoError(t, err)
+		assert.Regexp(t, `^2026-10-18T14:30:00\.000\+02:00 tester .* ▶ INFO .* hello$`, buffer.String())
+	})
+}
+
+func Test_journaldEntry(t *testing.T) {
+	t.Run("should encode fields", func(t *testing.T) {
+		record := newTestLogRecord("watcher", "Executing action on event '%s'", watcher.EventLicenseExpiring)
+
+		actual := journaldEntry(logging.WARNING, record, "/confluence.cfg.xml")
+
+		expected := "MESSAGE=Executing action on event 'license-expiring'\nPRIORITY=4\nSYSLOG_IDENTIFIER=license-checker\n" +
+			"COMPONENT=watcher\nCONFIG_FILE=/confluence.cfg.xml\nEVENT_TYPE=license-expiring\n"
+		assert.Equal(t, expected, string(actual))
+	})
+	t.Run("should encode multiline values with their length", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+
+		writeJournaldField(buffer, "MESSAGE", "a\nb")
+
+		assert.Equal(t, "MESSAGE\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n", buffer.String())
+	})
+}
+
+// test util stuff
+
+func newTestLogRecord(module string, format string, args ...interface{}) *logging.Record {
+	logger := logging.MustGetLogger(module)
+	backend := logging.NewMemoryBackend(1)
+	logger.SetBackend(logging.AddModuleLevel(backend))
+	logger.Infof(format, args...)
+
+	record := backend.Head().Record
+	record.Time = time.Date(2026, 10, 18, 14, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	return record
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// exitCodeSignalBase is added to the number of the signal which stopped the watcher, following the shell convention.
+const exitCodeSignalBase = 128
+
+// stopSignals are the signals which stop the watcher gracefully.
+var stopSignals = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+
+// signalNotifier remembers the first signal which cancelled its context.
+type signalNotifier struct {
+	mutex    sync.Mutex
+	received os.Signal
+	signals  chan os.Signal
+	stopped  chan struct{}
+}
+
+// notifyOnSignals returns a context which is cancelled when one of the given signals is received. Only the first
+// signal is caught; further signals are handled by the default handling again, e.g. terminate the process.
+func notifyOnSignals(parent context.Context, signals ...os.Signal) (context.Context, *signalNotifier) {
+	ctx, cancel := context.WithCancel(parent)
+	notifier := &signalNotifier{
+		signals: make(chan os.Signal, 1),
+		stopped: make(chan struct{}),
+	}
+	signal.Notify(notifier.signals, signals...)
+
+	go func() {
+		defer cancel()
+		select {
+		case sig := <-notifier.signals:
+			// restore the default handling, so that a second signal stops the watcher immediately
+			signal.Stop(notifier.signals)
+			log.Infof("Received signal %s; send it again to stop immediately", sig)
+			notifier.mutex.Lock()
+			notifier.received = sig
+			notifier.mutex.Unlock()
+		case <-notifier.stopped:
+		}
+	}()
+
+	return ctx, notifier
+}
+
+// Received returns the signal which cancelled the context or nil.
+func (sn *signalNotifier) Received() os.Signal {
+	sn.mutex.Lock()
+	defer sn.mutex.Unlock()
+	return sn.received
+}
+
+// Stop stops listening for signals.
+func (sn *signalNotifier) Stop() {
+	signal.Stop(sn.signals)
+	close(sn.stopped)
+}
+
+// signalExit returns an error which leads to the exit code 128 + signal number.
+func signalExit(sig os.Signal) error {
+	exitCode := exitCodeError
+	if sysSignal, ok := sig.(syscall.Signal); ok {
+		exitCode = exitCodeSignalBase + int(sysSignal)
+	}
+
+	return cli.Exit(fmt.Sprintf("Confluence license watcher stopped by signal %s.", sig), exitCode)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_notifyOnSignals(t *testing.T) {
+	t.Run("should cancel context on signal", func(t *testing.T) {
+		ctx, sut := notifyOnSignals(context.Background(), syscall.SIGUSR1)
+		defer sut.Stop()
+
+		// when
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+
+		// then
+		select {
+		case <-ctx.Done():
+		case <-time.After(2 * time.Second):
+			t.Fatal("context was not cancelled")
+		}
+		assert.Equal(t, syscall.SIGUSR1, sut.Received())
+	})
+	t.Run("should stop catching signals after the first one", func(t *testing.T) {
+		// SIGWINCH is ignored by default, so that the second signal does not terminate the test
+		ctx, sut := notifyOnSignals(context.Background(), syscall.SIGWINCH)
+		defer sut.Stop()
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGWINCH))
+		select {
+		case <-ctx.Done():
+		case <-time.After(2 * time.Second):
+			t.Fatal("context was not cancelled")
+		}
+
+		// when
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGWINCH))
+		time.Sleep(50 * time.Millisecond)
+
+		// then
+		assert.Empty(t, sut.signals)
+	})
+	t.Run("should cancel context without signal on stop", func(t *testing.T) {
+		ctx, sut := notifyOnSignals(context.Background(), syscall.SIGUSR2)
+
+		// when
+		sut.Stop()
+
+		// then
+		select {
+		case <-ctx.Done():
+		case <-time.After(2 * time.Second):
+			t.Fatal("context was not cancelled")
+		}
+		assert.Nil(t, sut.Received())
+	})
+}
+
+func Test_signalExit(t *testing.T) {
+	err := signalExit(syscall.SIGTERM)
+
+	var exitCoder cli.ExitCoder
+	require.ErrorAs(t, err, &exitCoder)
+	assert.Equal(t, 143, exitCoder.ExitCode())
+	assert.Equal(t, "Confluence license watcher stopped by signal terminated.", err.Error())
+}
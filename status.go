@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cloudogu/confluence-license-checker/license/tester"
+	"github.com/cloudogu/confluence-license-checker/license/watcher"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	stateFileFlagName           = "state-file"
+	stateFileEnvVarName         = "STATE_FILE"
+	changedSinceLastRunFlagName = "changed-since-last-run"
+	// lastRunFileSuffix is appended to the state file to get the file in which the status command keeps the license
+	// of its last run. The state file itself belongs to watch, which would overwrite changes of the status command.
+	lastRunFileSuffix = ".last-run"
+
+	// exitCodeLicenseUnchanged is the exit code of the status command if the license did not change since the last
+	// run.
+	exitCodeLicenseUnchanged = 2
+)
+
+// statusReport contains the state of the watcher in a printable form.
+type statusReport struct {
+	StateFile             string        `json:"stateFile" yaml:"stateFile"`
+	StartedAt             string        `json:"startedAt,omitempty" yaml:"startedAt,omitempty"`
+	LastCheck             string        `json:"lastCheck,omitempty" yaml:"lastCheck,omitempty"`
+	LastSuccessfulCheck   string        `json:"lastSuccessfulCheck,omitempty" yaml:"lastSuccessfulCheck,omitempty"`
+	LastChange            string        `json:"lastChange,omitempty" yaml:"lastChange,omitempty"`
+	License               string        `json:"license,omitempty" yaml:"license,omitempty"`
+	LicenseSHA256         string        `json:"licenseSha256,omitempty" yaml:"licenseSha256,omitempty"`
+	Checks                int           `json:"checks" yaml:"checks"`
+	FailedChecks          int           `json:"failedChecks" yaml:"failedChecks"`
+	Changes               int           `json:"changes" yaml:"changes"`
+	LastEvent             string        `json:"lastEvent,omitempty" yaml:"lastEvent,omitempty"`
+	LastAction            *actionReport `json:"lastAction,omitempty" yaml:"lastAction,omitempty"`
+	ChangedSinceLastRun   *bool         `json:"changedSinceLastRun,omitempty" yaml:"changedSinceLastRun,omitempty"`
+	PreviousLicenseSHA256 string        `json:"previousLicenseSha256,omitempty" yaml:"previousLicenseSha256,omitempty"`
+}
+
+// actionReport describes the last action result in a printable form.
+type actionReport struct {
+	Event   string `json:"event" yaml:"event"`
+	Time    string `json:"time" yaml:"time"`
+	Success bool   `json:"success" yaml:"success"`
+	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func createStateFileFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    stateFileFlagName,
+		Usage:   "the file in which the watcher keeps its state, e.g. /var/lib/confluence-license-checker/state.json (default: no state file)",
+		EnvVars: []string{stateFileEnvVarName},
+	}
+}
+
+func StatusCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "status",
+		Usage: "print the state of the license watcher",
+		Flags: []cli.Flag{
+			createStateFileFlag(),
+			&cli.BoolFlag{
+				Name: changedSinceLastRunFlagName,
+				Usage: fmt.Sprintf("check whether the configured license changed since the last run of status, "+
+					"record the check next to the state file in '<state file>%s' and exit with exit code %d if it did not change",
+					lastRunFileSuffix, exitCodeLicenseUnchanged),
+			},
+			&cli.StringFlag{
+				Name:    outputFlagName,
+				Aliases: []string{"o"},
+				Usage:   "the output format, one of table, json or yaml",
+				Value:   outputFormatTable,
+			},
+		},
+		Action: statusAction,
+	}
+}
+
+func statusAction(c *cli.Context) error {
+	format := c.String(outputFlagName)
+	if !isValidOutputFormat(format) {
+		return errors.Errorf("cannot print status: unsupported output format '%s'", format)
+	}
+
+	stateFile := c.String(stateFileFlagName)
+	if stateFile == "" {
+		return errors.Errorf("cannot print status: flag '--%s' must be given", stateFileFlagName)
+	}
+
+	if !c.Bool(changedSinceLastRunFlagName) {
+		state, err := watcher.ReadState(stateFile)
+		if err != nil {
+			return errors.Wrap(err, "cannot print status")
+		}
+		return printStatusReport(c.App.Writer, createStatusReport(stateFile, state), format)
+	}
+
+	report, err := checkChangedSinceLastRun(stateFile, tester.New(), time.Now())
+	if err != nil {
+		return errors.Wrap(err, "cannot check for a license change")
+	}
+
+	err = printStatusReport(c.App.Writer, report, format)
+	if err != nil {
+		return err
+	}
+	if !*report.ChangedSinceLastRun {
+		return cli.Exit("", exitCodeLicenseUnchanged)
+	}
+	return nil
+}
+
+// checkChangedSinceLastRun compares the configured license with the license of the last run and records the check in
+// the last run file next to the state file. A missing last run file is created, and the license counts as unchanged.
+// The state file of watch is never written, so that a running watcher and the status command do not overwrite each
+// other.
+func checkChangedSinceLastRun(stateFile string, licenseTester tester.Tester, now time.Time) (*statusReport, error) {
+	lastRunFile := stateFile + lastRunFileSuffix
+	state, err := watcher.ReadState(lastRunFile)
+	if errors.Is(err, os.ErrNotExist) {
+		state, err = &watcher.State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	license, err := licenseTester.ReadLicense(confluenceConfigFile)
+	if tester.IsLicenseMissing(err) {
+		license, err = "", nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	previous := state.LicenseSHA256
+	changed := state.RecordCheck(now, license, nil)
+	if changed {
+		state.Changes++
+		state.LastChange = now
+	}
+
+	err = watcher.WriteState(lastRunFile, state)
+	if err != nil {
+		return nil, err
+	}
+
+	report := createStatusReport(lastRunFile, state)
+	report.ChangedSinceLastRun = &changed
+	report.PreviousLicenseSHA256 = previous
+	return report, nil
+}
+
+func createStatusReport(stateFile string, state *watcher.State) *statusReport {
+	report := &statusReport{
+		StateFile:           stateFile,
+		StartedAt:           formatStateTime(state.StartedAt),
+		LastCheck:           formatStateTime(state.LastCheck),
+		LastSuccessfulCheck: formatStateTime(state.LastSuccessfulCheck),
+		LastChange:          formatStateTime(state.LastChange),
+		License:             state.License,
+		LicenseSHA256:       state.LicenseSHA256,
+		Checks:              state.Checks,
+		FailedChecks:        state.FailedChecks,
+		Changes:             state.Changes,
+		LastEvent:           string(state.LastEvent),
+	}
+
+	if state.LastAction != nil {
+		report.LastAction = &actionReport{
+			Event:   string(state.LastAction.Event),
+			Time:    formatStateTime(state.LastAction.Time),
+			Success: state.LastAction.Success,
+			Error:   state.LastAction.Error,
+		}
+	}
+	return report
+}
+
+func formatStateTime(at time.Time) string {
+	if at.IsZero() {
+		return ""
+	}
+	return at.Format(time.RFC3339)
+}
+
+func printStatusReport(writer io.Writer, report *statusReport, format string) error {
+	switch format {
+	case outputFormatJSON:
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return errors.Wrap(encoder.Encode(report), "failed to print status as JSON")
+	case outputFormatYAML:
+		encoder := yaml.NewEncoder(writer)
+		encoder.SetIndent(2)
+		err := encoder.Encode(report)
+		if err != nil {
+			return errors.Wrap(err, "failed to print status as YAML")
+		}
+		return errors.Wrap(encoder.Close(), "failed to print status as YAML")
+	default:
+		return printStatusTable(writer, report)
+	}
+}
+
+func printStatusTable(writer io.Writer, report *statusReport) error {
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+
+	rows := [][2]string{
+		{"State file", report.StateFile},
+		{"Started at", valueOrDefault(report.StartedAt, "-")},
+		{"Last check", valueOrDefault(report.LastCheck, "-")},
+		{"Last successful check", valueOrDefault(report.LastSuccessfulCheck, "-")},
+		{"Last change", valueOrDefault(report.LastChange, "-")},
+		{"License", valueOrDefault(report.License, "-")},
+		{"License SHA-256", valueOrDefault(report.LicenseSHA256, "-")},
+		{"Checks", fmt.Sprintf("%d", report.Checks)},
+		{"Failed checks", fmt.Sprintf("%d", report.FailedChecks)},
+		{"Changes", fmt.Sprintf("%d", report.Changes)},
+		{"Last event", valueOrDefault(report.LastEvent, "-")},
+		{"Last action", formatActionReport(report.LastAction)},
+	}
+	if report.ChangedSinceLastRun != nil {
+		rows = append(rows, [2]string{"Changed since last run", fmt.Sprintf("%t", *report.ChangedSinceLastRun)})
+	}
+	for _, row := range rows {
+		_, _ = fmt.Fprintf(table, "%s:\t%s\n", row[0], row[1])
+	}
+
+	return errors.Wrap(table.Flush(), "failed to print status table")
+}
+
+func formatActionReport(action *actionReport) string {
+	if action == nil {
+		return "-"
+	}
+	if action.Success {
+		return fmt.Sprintf("%s at %s succeeded", action.Event, action.Time)
+	}
+	return fmt.Sprintf("%s at %s failed: %s", action.Event, action.Time, action.Error)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/cloudogu/confluence-license-checker/license/atlassian"
+	"github.com/cloudogu/confluence-license-checker/license/tester"
+	"github.com/cloudogu/confluence-license-checker/license/watcher"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatusCommand(t *testing.T) {
+	actual := StatusCommand()
+
+	require.NotNil(t, actual)
+}
+
+func Test_checkChangedSinceLastRun(t *testing.T) {
+	t.Run("should create last run file and report no change on first run", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "state.json")
+
+		// when
+		actual, err := checkChangedSinceLastRun(stateFile, &stubTester{license: "AAAB"}, testNow)
+
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, actual.ChangedSinceLastRun)
+		assert.False(t, *actual.ChangedSinceLastRun)
+		assert.Equal(t, atlassian.Fingerprint("AAAB"), actual.LicenseSHA256)
+		assert.Empty(t, actual.PreviousLicenseSHA256)
+		assert.Equal(t, stateFile+".last-run", actual.StateFile)
+
+		state, err := watcher.ReadState(stateFile + ".last-run")
+		require.NoError(t, err)
+		assert.Equal(t, 1, state.Checks)
+		assert.NoFileExists(t, stateFile)
+	})
+	t.Run("should report no change for the same license", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "state.json")
+		_, err := checkChangedSinceLastRun(stateFile, &stubTester{license: "AAAB"}, testNow)
+		require.NoError(t, err)
+
+		// when
+		actual, err := checkChangedSinceLastRun(stateFile, &stubTester{license: "AAAB\n"}, testNow.Add(time.Hour))
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, *actual.ChangedSinceLastRun)
+		assert.Equal(t, 2, actual.Checks)
+		assert.Equal(t, 0, actual.Changes)
+	})
+	t.Run("should report and record a changed license", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "state.json")
+		_, err := checkChangedSinceLastRun(stateFile, &stubTester{license: "AAAB"}, testNow)
+		require.NoError(t, err)
+
+		// when
+		actual, err := checkChangedSinceLastRun(stateFile, &stubTester{license: "CCCD"}, testNow.Add(time.Hour))
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, *actual.ChangedSinceLastRun)
+		assert.Equal(t, atlassian.Fingerprint("AAAB"), actual.PreviousLicenseSHA256)
+		assert.Equal(t, atlassian.Fingerprint("CCCD"), actual.LicenseSHA256)
+		assert.Equal(t, atlassian.Redact("CCCD"), actual.License)
+
+		state, err := watcher.ReadState(stateFile + ".last-run")
+		require.NoError(t, err)
+		assert.Equal(t, 1, state.Changes)
+		assert.Equal(t, testNow.Add(time.Hour), state.LastChange.UTC())
+	})
+	t.Run("should leave the state file of watch alone", func(t *testing.T) {
+		// given
+		stateFile := filepath.Join(t.TempDir(), "state.json")
+		watchState := &watcher.State{StartedAt: testNow, Checks: 7, LicenseSHA256: atlassian.Fingerprint("AAAB")}
+		require.NoError(t, watcher.WriteState(stateFile, watchState))
+
+		// when
+		_, err := checkChangedSinceLastRun(stateFile, &stubTester{license: "CCCD"}, testNow)
+
+		// then
+		require.NoError(t, err)
+		actual, err := watcher.ReadState(stateFile)
+		require.NoError(t, err)
+		assert.Equal(t, 7, actual.Checks)
+		assert.Equal(t, atlassian.Fingerprint("AAAB"), actual.LicenseSHA256)
+	})
+	t.Run("should fail if the license cannot be read", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "state.json")
+
+		// when
+		_, err := checkChangedSinceLastRun(stateFile, &stubTester{err: errors.New("permission denied")}, testNow)
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "permission denied")
+		assert.NoFileExists(t, stateFile+".last-run")
+	})
+}
+
+func Test_createStatusReport(t *testing.T) {
+	t.Run("should format times and last action", func(t *testing.T) {
+		state := &watcher.State{
+			StartedAt:  testNow,
+			LastCheck:  testNow.Add(time.Minute),
+			Checks:     3,
+			LastEvent:  watcher.EventSetupToProduction,
+			LastAction: &watcher.ActionResult{Event: watcher.EventSetupToProduction, Time: testNow, Error: "exit status 1"},
+		}
+
+		// when
+		actual := createStatusReport("/tmp/state.json", state)
+
+		// then
+		assert.Equal(t, "/tmp/state.json", actual.StateFile)
+		assert.Equal(t, "2026-10-18T12:00:00Z", actual.StartedAt)
+		assert.Equal(t, "2026-10-18T12:01:00Z", actual.LastCheck)
+		assert.Empty(t, actual.LastSuccessfulCheck)
+		assert.Equal(t, 3, actual.Checks)
+		require.NotNil(t, actual.LastAction)
+		assert.False(t, actual.LastAction.Success)
+		assert.Equal(t, "exit status 1", actual.LastAction.Error)
+	})
+}
+
+func Test_printStatusReport(t *testing.T) {
+	changed := true
+	report := &statusReport{
+		StateFile:           "/tmp/state.json",
+		LastCheck:           "2026-10-18T12:00:00Z",
+		Checks:              2,
+		ChangedSinceLastRun: &changed,
+		LastAction:          &actionReport{Event: "setup-to-production", Time: "2026-10-18T12:00:00Z", Success: true},
+	}
+
+	t.Run("should print table", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+
+		err := printStatusReport(buffer, report, outputFormatTable)
+
+		require.NoError(t, err)
+		assert.Contains(t, buffer.String(), "Last check:")
+		assert.Contains(t, buffer.String(), "2026-10-18T12:00:00Z")
+		assert.Contains(t, buffer.String(), "setup-to-production at 2026-10-18T12:00:00Z succeeded")
+		assert.Contains(t, buffer.String(), "Changed since last run:")
+	})
+	t.Run("should print json", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+
+		err := printStatusReport(buffer, report, outputFormatJSON)
+
+		require.NoError(t, err)
+		actual := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &actual))
+		assert.Equal(t, float64(2), actual["checks"])
+		assert.Equal(t, true, actual["changedSinceLastRun"])
+	})
+}
+
+// test util stuff
+
+// stubTester returns a fixed license for every config file.
+type stubTester struct {
+	tester.Tester
+	license string
+	err     error
+}
+
+func (s *stubTester) ReadLicense(string) (string, error) {
+	return s.license, s.err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cloudogu/confluence-license-checker/license/tester"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"io"
+	"strings"
+)
+
+// Exit codes of the test-setup command. Any other failure leads to exitCodeError.
+const (
+	exitCodeSetupLicense      = 0
+	exitCodeError             = 1
+	exitCodeProductionLicense = 2
+	exitCodeLicenseMissing    = 3
+	exitCodeConfigMalformed   = 4
+
+	outputFormatEnv = "env"
+)
+
+var resultExitCodes = map[tester.State]int{
+	tester.StateSetupLicense:      exitCodeSetupLicense,
+	tester.StateProductionLicense: exitCodeProductionLicense,
+	tester.StateLicenseMissing:    exitCodeLicenseMissing,
+	tester.StateConfigMalformed:   exitCodeConfigMalformed,
+}
+
+var resultMessages = map[tester.State]string{
+	tester.StateSetupLicense:      "Found a setup license. License check may be started.",
+	tester.StateProductionLicense: "Found a non-setup license. License check must not be started.",
+	tester.StateLicenseMissing:    "Found no license. License check must not be started.",
+	tester.StateConfigMalformed:   "Found a malformed configuration file. License check must not be started.",
+}
+
+// setupResult is the machine-readable form of a setup license check.
+type setupResult struct {
+	State      tester.State `json:"state"`
+	ExitCode   int          `json:"exitCode"`
+	ConfigFile string       `json:"configFile"`
+	Reason     string       `json:"reason,omitempty"`
+}
+
+func newSetupResult(result *tester.Result) *setupResult {
+	exitCode, ok := resultExitCodes[result.State]
+	if !ok {
+		exitCode = exitCodeError
+	}
+
+	return &setupResult{
+		State:      result.State,
+		ExitCode:   exitCode,
+		ConfigFile: result.ConfigFile,
+		Reason:     result.Reason,
+	}
+}
+
+func isValidSetupOutputFormat(format string) bool {
+	return format == "" || format == outputFormatJSON || format == outputFormatEnv
+}
+
+// printSetupResult prints the result in the given format. Nothing is printed if no format is given.
+func printSetupResult(writer io.Writer, result *setupResult, format string) error {
+	switch format {
+	case outputFormatJSON:
+		return errors.Wrap(json.NewEncoder(writer).Encode(result), "failed to print result as JSON")
+	case outputFormatEnv:
+		_, err := fmt.Fprintf(writer, "LICENSE_STATE=%s\nLICENSE_EXIT_CODE=%d\nLICENSE_CONFIG_FILE=%s\nLICENSE_REASON=%s\n",
+			result.State, result.ExitCode, shellQuote(result.ConfigFile), shellQuote(result.Reason))
+		return errors.Wrap(err, "failed to print result as environment variables")
+	default:
+		return nil
+	}
+}
+
+// shellQuote quotes a value with single quotes, so that a POSIX shell does not expand anything in it when the output
+// is sourced or evaluated.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// setupResultExit returns an error carrying the exit code of the result or nil for a setup license. The error has a
+// human-readable message unless the result was already printed in a machine-readable format.
+func setupResultExit(result *setupResult, format string) error {
+	if result.ExitCode == exitCodeSetupLicense {
+		return nil
+	}
+
+	if format != "" {
+		return cli.Exit("", result.ExitCode)
+	}
+
+	message := resultMessages[result.State]
+	if result.Reason != "" {
+		message = fmt.Sprintf("%s Reason: %s", message, result.Reason)
+	}
+	return cli.Exit(message, result.ExitCode)
+}
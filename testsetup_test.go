@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/cloudogu/confluence-license-checker/license/tester"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"testing"
+)
+
+func Test_newSetupResult(t *testing.T) {
+	tests := []struct {
+		state    tester.State
+		exitCode int
+	}{
+		{tester.StateSetupLicense, 0},
+		{tester.StateProductionLicense, 2},
+		{tester.StateLicenseMissing, 3},
+		{tester.StateConfigMalformed, 4},
+		{tester.State("unknown"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.state), func(t *testing.T) {
+			actual := newSetupResult(&tester.Result{State: tt.state, ConfigFile: "confluence.cfg.xml", Reason: "reason"})
+
+			assert.Equal(t, &setupResult{State: tt.state, ExitCode: tt.exitCode, ConfigFile: "confluence.cfg.xml", Reason: "reason"}, actual)
+		})
+	}
+}
+
+func Test_printSetupResult(t *testing.T) {
+	result := &setupResult{State: tester.StateLicenseMissing, ExitCode: 3, ConfigFile: "/var/confluence.cfg.xml", Reason: "not found"}
+
+	t.Run("should print json", func(t *testing.T) {
+		var out bytes.Buffer
+
+		err := printSetupResult(&out, result, "json")
+
+		require.NoError(t, err)
+		actual := &setupResult{}
+		require.NoError(t, json.Unmarshal(out.Bytes(), actual))
+		assert.Equal(t, result, actual)
+	})
+	t.Run("should print env", func(t *testing.T) {
+		var out bytes.Buffer
+
+		err := printSetupResult(&out, result, "env")
+
+		require.NoError(t, err)
+		assert.Equal(t, "LICENSE_STATE=license-missing\nLICENSE_EXIT_CODE=3\nLICENSE_CONFIG_FILE='/var/confluence.cfg.xml'\nLICENSE_REASON='not found'\n", out.String())
+	})
+	t.Run("should quote env values for the shell", func(t *testing.T) {
+		var out bytes.Buffer
+		quoted := &setupResult{State: tester.StateConfigMalformed, ExitCode: 4, ConfigFile: "/var/$HOME/`id`/it's.xml"}
+
+		err := printSetupResult(&out, quoted, "env")
+
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "LICENSE_CONFIG_FILE='/var/$HOME/`id`/it'\\''s.xml'\n")
+		assert.Contains(t, out.String(), "LICENSE_REASON=''\n")
+	})
+	t.Run("should print nothing without format", func(t *testing.T) {
+		var out bytes.Buffer
+
+		err := printSetupResult(&out, result, "")
+
+		require.NoError(t, err)
+		assert.Empty(t, out.String())
+	})
+}
+
+func Test_setupResultExit(t *testing.T) {
+	t.Run("should return nil for setup license", func(t *testing.T) {
+		err := setupResultExit(&setupResult{State: tester.StateSetupLicense, ExitCode: 0}, "")
+
+		assert.NoError(t, err)
+	})
+	t.Run("should return exit code with message", func(t *testing.T) {
+		err := setupResultExit(&setupResult{State: tester.StateConfigMalformed, ExitCode: 4, Reason: "parse error"}, "")
+
+		require.Error(t, err)
+		var exitCoder cli.ExitCoder
+		require.ErrorAs(t, err, &exitCoder)
+		assert.Equal(t, 4, exitCoder.ExitCode())
+		assert.Equal(t, "Found a malformed configuration file. License check must not be started. Reason: parse error", err.Error())
+	})
+	t.Run("should return exit code without message for machine-readable output", func(t *testing.T) {
+		err := setupResultExit(&setupResult{State: tester.StateProductionLicense, ExitCode: 2}, "json")
+
+		require.Error(t, err)
+		var exitCoder cli.ExitCoder
+		require.ErrorAs(t, err, &exitCoder)
+		assert.Equal(t, 2, exitCoder.ExitCode())
+		assert.Empty(t, err.Error())
+	})
+}
+
+func Test_isValidSetupOutputFormat(t *testing.T) {
+	assert.True(t, isValidSetupOutputFormat(""))
+	assert.True(t, isValidSetupOutputFormat("json"))
+	assert.True(t, isValidSetupOutputFormat("env"))
+	assert.False(t, isValidSetupOutputFormat("yaml"))
+}